@@ -0,0 +1,517 @@
+// Command accounting-cli runs reports straight against the backends, for
+// hosts where the HTTP API is out of reach. It reads the same config file as
+// the service.
+//
+//	accounting-cli [-config file] [-format table|json|csv] <command> [flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/bootstrap"
+	"github.com/AlanMute/university-accounting/internal/config"
+	"github.com/AlanMute/university-accounting/internal/migrate"
+	"github.com/AlanMute/university-accounting/internal/storage"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage marks errors in the command line, which exit with exitUsage.
+var errUsage = errors.New("usage error")
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = map[string]command{
+	"attendance":  {usage: "-term TERM -start YYYY-MM-DD -end YYYY-MM-DD [-search-fields a,b] [-min-score N] [-fields a,b.c]", run: runAttendance},
+	"course":      {usage: "-year YEAR -sem SEMESTER [-fields a,b.c]", run: runCourse},
+	"group":       {usage: "-name GROUP [-fields a,b.c]", run: runGroup},
+	"groups":      {usage: "", run: runGroups},
+	"discipline":  {usage: "-id DISCIPLINE -start YYYY-MM-DD -end YYYY-MM-DD [-fields a,b.c]", run: runDiscipline},
+	"workload":    {usage: "-teacher ID -start YYYY-MM-DD -end YYYY-MM-DD [-fields a,b.c]", run: runWorkload},
+	"check":       {usage: "[backend...]", run: runCheck},
+	"consistency": {usage: "[-checks a,b] [-repair]", run: runConsistency},
+	"migrate":     {usage: "status | up [-to VERSION] | down [-steps N]", run: runMigrate},
+	"bootstrap":   {usage: "apply | verify", run: runBootstrap},
+}
+
+var commandOrder = []string{"attendance", "course", "group", "groups", "discipline", "workload", "check", "consistency", "migrate", "bootstrap"}
+
+// env is what the commands share: the config, the output and the lazily
+// opened backends.
+type env struct {
+	cfg    *config.Config
+	out    io.Writer
+	errOut io.Writer
+	format string
+
+	clients *storage.Clients
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("accounting-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", os.Getenv("CONFIG_PATH"), "path to the JSON config file")
+	format := flags.String("format", formatTable, "output format: table, json or csv")
+	flags.Usage = func() { printUsage(stderr) }
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", flags.Arg(0))
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load config: %v\n", err)
+		return exitError
+	}
+
+	ctx := context.Background()
+	e := &env{cfg: cfg, out: stdout, errOut: stderr, format: *format}
+	defer e.close(ctx)
+
+	if err := cmd.run(ctx, e, flags.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			if err != errUsage {
+				fmt.Fprintf(stderr, "%s: %v\n", flags.Arg(0), err)
+			}
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "%s: %v\n", flags.Arg(0), err)
+		return exitError
+	}
+	return exitOK
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: accounting-cli [-config file] [-format table|json|csv] <command> [flags]")
+	fmt.Fprintln(w, "commands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// backends opens the clients on first use and fails when one of them is
+// unreachable, so that a report never runs half connected.
+func (e *env) backends(ctx context.Context) (*storage.Clients, error) {
+	if e.clients != nil {
+		return e.clients, nil
+	}
+	clients, err := storage.Open(ctx, e.cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.clients = clients
+	for _, backend := range storage.Backends {
+		if err := clients.Ping(ctx, backend); err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", storage.Title(backend), err)
+		}
+	}
+	return clients, nil
+}
+
+// connect opens the clients but only needs the given backends to answer, for
+// the commands that manage their schema.
+func (e *env) connect(ctx context.Context, backends ...string) (*storage.Clients, error) {
+	if e.clients == nil {
+		clients, err := storage.Open(ctx, e.cfg)
+		if err != nil {
+			return nil, err
+		}
+		e.clients = clients
+	}
+	for _, backend := range backends {
+		if err := e.clients.Ping(ctx, backend); err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %v", storage.Title(backend), err)
+		}
+	}
+	return e.clients, nil
+}
+
+func (e *env) close(ctx context.Context) {
+	if e.clients != nil {
+		e.clients.Close(ctx)
+	}
+}
+
+// report runs the report with the service's own parameter validation.
+func (e *env) report(ctx context.Context, reportType string, params map[string]string) error {
+	if err := accounting.ValidateReportParams(reportType, params); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	clients, err := e.backends(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := clients.Accounting(e.cfg)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.RunReportContext(ctx, accounting.Scope{}, reportType, params, nil)
+	if err != nil {
+		return err
+	}
+	return write(e.out, e.format, result)
+}
+
+// parse parses the flags of a command, printing usage errors itself.
+func parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		return errUsage
+	}
+	return nil
+}
+
+func (e *env) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.errOut)
+	return flags
+}
+
+func runAttendance(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("attendance")
+	term := flags.String("term", "", "term to search lectures and materials for")
+	start := flags.String("start", "", "start of the period, YYYY-MM-DD")
+	end := flags.String("end", "", "end of the period, YYYY-MM-DD")
+	searchFields := flags.String("search-fields", "", "comma-separated fields to search the term in")
+	minScore := flags.String("min-score", "", "minimal relevance score of a match")
+	fields := flags.String("fields", "", "comma-separated fields of the report to output")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	return e.report(ctx, accounting.ReportAttendance, nonEmpty(map[string]string{
+		"term":         *term,
+		"startDate":    *start,
+		"endDate":      *end,
+		"searchFields": *searchFields,
+		"minScore":     *minScore,
+		"fields":       *fields,
+	}))
+}
+
+func runCourse(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("course")
+	year := flags.String("year", "", "year of study")
+	sem := flags.String("sem", "", "semester")
+	fields := flags.String("fields", "", "comma-separated fields of the report to output")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	return e.report(ctx, accounting.ReportCourse, nonEmpty(map[string]string{
+		"year":   *year,
+		"sem":    *sem,
+		"fields": *fields,
+	}))
+}
+
+func runGroup(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("group")
+	name := flags.String("name", "", "group name")
+	fields := flags.String("fields", "", "comma-separated fields of the report to output")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	return e.report(ctx, accounting.ReportGroup, nonEmpty(map[string]string{
+		"group":  *name,
+		"fields": *fields,
+	}))
+}
+
+func runGroups(ctx context.Context, e *env, args []string) error {
+	if err := parse(e.flagSet("groups"), args); err != nil {
+		return err
+	}
+	return e.report(ctx, accounting.ReportGroupList, map[string]string{})
+}
+
+func runDiscipline(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("discipline")
+	id := flags.String("id", "", "discipline ID")
+	start := flags.String("start", "", "start of the period, YYYY-MM-DD")
+	end := flags.String("end", "", "end of the period, YYYY-MM-DD")
+	fields := flags.String("fields", "", "comma-separated fields of the report to output")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	return e.report(ctx, accounting.ReportDiscipline, nonEmpty(map[string]string{
+		"discipline": *id,
+		"startDate":  *start,
+		"endDate":    *end,
+		"fields":     *fields,
+	}))
+}
+
+func runWorkload(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("workload")
+	teacher := flags.String("teacher", "", "teacher ID")
+	start := flags.String("start", "", "start of the period, YYYY-MM-DD")
+	end := flags.String("end", "", "end of the period, YYYY-MM-DD")
+	fields := flags.String("fields", "", "comma-separated fields of the report to output")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+
+	return e.report(ctx, accounting.ReportWorkload, nonEmpty(map[string]string{
+		"teacher":   *teacher,
+		"startDate": *start,
+		"endDate":   *end,
+		"fields":    *fields,
+	}))
+}
+
+type backendStatus struct {
+	Backend   string `json:"backend"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// runCheck pings every backend, or the named ones, and fails if any of them
+// is unreachable.
+func runCheck(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("check")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	backends := flags.Args()
+	if len(backends) == 0 {
+		backends = storage.Backends
+	}
+	for _, backend := range backends {
+		if storage.Title(backend) == backend {
+			fmt.Fprintf(flags.Output(), "unknown backend %q, expected one of %s\n", backend, strings.Join(storage.Backends, ", "))
+			return errUsage
+		}
+	}
+
+	clients, err := storage.Open(ctx, e.cfg)
+	if err != nil {
+		return err
+	}
+	e.clients = clients
+
+	var failed []string
+	statuses := make([]backendStatus, 0, len(backends))
+	for _, backend := range backends {
+		started := time.Now()
+		status := backendStatus{Backend: backend, Status: "ok"}
+		if err := clients.Ping(ctx, backend); err != nil {
+			status.Status = "failed"
+			status.Error = err.Error()
+			failed = append(failed, backend)
+		}
+		status.LatencyMs = time.Since(started).Milliseconds()
+		statuses = append(statuses, status)
+	}
+
+	if err := write(e.out, e.format, statuses); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unreachable: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runConsistency lists the inconsistencies between the stores, repairing them
+// with -repair. It fails when any are left, so it can guard a cron job.
+func runConsistency(ctx context.Context, e *env, args []string) error {
+	flags := e.flagSet("consistency")
+	list := flags.String("checks", "", "comma-separated checks to run: "+strings.Join(accounting.ConsistencyChecks, ", "))
+	repair := flags.Bool("repair", false, "repair the inconsistencies that can be repaired")
+	if err := parse(flags, args); err != nil {
+		return err
+	}
+	checks, err := accounting.ParseConsistencyChecks(*list)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	clients, err := e.backends(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := clients.Accounting(e.cfg)
+	if err != nil {
+		return err
+	}
+	report, err := client.CheckConsistency(ctx, checks, *repair)
+	if err != nil {
+		return err
+	}
+
+	// Tables and CSV list one issue per row; JSON keeps the summary too.
+	var out interface{} = report.Issues
+	if e.format == formatJSON {
+		out = report
+	}
+	if err := write(e.out, e.format, out); err != nil {
+		return err
+	}
+	for _, summary := range report.Checks {
+		fmt.Fprintf(e.errOut, "%s: %d issues, %d repaired\n", summary.Check, summary.Issues, summary.Repaired)
+	}
+	if n := report.Unresolved(); n > 0 {
+		return fmt.Errorf("%d inconsistencies left", n)
+	}
+	return nil
+}
+
+type migrationRow struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+}
+
+func runMigrate(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(e.errOut, "usage: migrate status | up [-to VERSION] | down [-steps N]")
+		return errUsage
+	}
+	flags := e.flagSet("migrate " + args[0])
+	var to, steps *int
+	switch args[0] {
+	case "status":
+	case "up":
+		to = flags.Int("to", 0, "version to migrate up to, the latest by default")
+	case "down":
+		steps = flags.Int("steps", 1, "number of migrations to revert")
+	default:
+		fmt.Fprintf(e.errOut, "unknown migrate command %q\n", args[0])
+		return errUsage
+	}
+	if err := parse(flags, args[1:]); err != nil {
+		return err
+	}
+	if steps != nil && *steps <= 0 {
+		fmt.Fprintln(e.errOut, "-steps must be positive")
+		return errUsage
+	}
+
+	clients, err := e.connect(ctx, storage.BackendPostgres)
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.NewMigrator(clients.Postgres)
+	if err != nil {
+		return err
+	}
+
+	var done []migrate.Migration
+	state := ""
+	switch args[0] {
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.errOut, "schema version %d, latest %d\n", status.Current, status.Latest)
+		done, state = status.Pending, "pending"
+	case "up":
+		done, err = migrator.Up(ctx, *to)
+		state = "applied"
+	case "down":
+		done, err = migrator.Down(ctx, *steps)
+		state = "reverted"
+	}
+
+	rows := make([]migrationRow, 0, len(done))
+	for _, migration := range done {
+		rows = append(rows, migrationRow{Version: migration.Version, Name: migration.Name, State: state})
+	}
+	if writeErr := write(e.out, e.format, rows); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+type bootstrapRow struct {
+	Action string `json:"action"`
+}
+
+func runBootstrap(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(e.errOut, "usage: bootstrap apply | verify")
+		return errUsage
+	}
+	if args[0] != "apply" && args[0] != "verify" {
+		fmt.Fprintf(e.errOut, "unknown bootstrap command %q\n", args[0])
+		return errUsage
+	}
+	if err := parse(e.flagSet("bootstrap "+args[0]), args[1:]); err != nil {
+		return err
+	}
+
+	clients, err := e.connect(ctx, storage.BackendElastic, storage.BackendNeo4j)
+	if err != nil {
+		return err
+	}
+	bootstrapper := bootstrap.NewBootstrapper(clients.Elastic, clients.Neo4j)
+
+	if args[0] == "verify" {
+		if err := bootstrapper.Verify(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.errOut, "indices and constraints are up to date")
+		return nil
+	}
+
+	actions, err := bootstrapper.Apply(ctx)
+	rows := make([]bootstrapRow, 0, len(actions))
+	for _, action := range actions {
+		rows = append(rows, bootstrapRow{Action: action})
+	}
+	if writeErr := write(e.out, e.format, rows); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func nonEmpty(params map[string]string) map[string]string {
+	for name, value := range params {
+		if value == "" {
+			delete(params, name)
+		}
+	}
+	return params
+}
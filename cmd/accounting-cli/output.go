@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatCSV
+}
+
+func write(w io.Writer, format string, v interface{}) error {
+	if format == formatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	value, err := decodeOrdered(decoder)
+	if err != nil {
+		return fmt.Errorf("failed to decode result: %v", err)
+	}
+
+	columns, rows := flatten(value)
+	if len(columns) == 0 {
+		return nil
+	}
+	if format == formatCSV {
+		return writeCSV(w, columns, rows)
+	}
+	return writeTable(w, columns, rows)
+}
+
+func writeTable(w io.Writer, columns []string, rows []map[string]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(row[column])
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, columns []string, rows []map[string]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = row[column]
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// object is a JSON object that keeps the order of its keys, so columns come
+// out in the order of the report's fields.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		var obj object
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{key: key.(string), value: value})
+		}
+		_, err := decoder.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+// flatten turns a report into rows. A list becomes one row per element and
+// nested objects become dotted columns. A record's only list of objects is
+// expanded into rows that repeat the record's own columns; when a record has
+// several, each of them is shown as a count, and the fields flag can select
+// the one to expand. Lists of plain values are joined with "; ".
+func flatten(v interface{}) ([]string, []map[string]string) {
+	var columns, empty []string
+	seen := make(map[string]bool)
+	addColumn := func(column string, value interface{}) {
+		if isEmpty(value) {
+			empty = append(empty, column)
+			return
+		}
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	var rows []map[string]string
+	var records []interface{}
+	if list, ok := v.([]interface{}); ok {
+		records = list
+	} else {
+		records = []interface{}{v}
+	}
+	for _, record := range records {
+		rows = append(rows, flattenRecord(record, "", addColumn)...)
+	}
+
+	// An empty value gets a column of its own only if no other record had
+	// something under the same name, since an empty list of objects looks
+	// just like an empty list of plain values.
+	for _, column := range empty {
+		if seen[column] || hasColumnUnder(columns, column) {
+			continue
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+	return columns, rows
+}
+
+func hasColumnUnder(columns []string, prefix string) bool {
+	for _, column := range columns {
+		if strings.HasPrefix(column, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v interface{}) bool {
+	list, ok := v.([]interface{})
+	return v == nil || ok && len(list) == 0
+}
+
+func flattenRecord(v interface{}, prefix string, addColumn func(string, interface{})) []map[string]string {
+	obj, ok := v.(object)
+	if !ok {
+		column := strings.TrimSuffix(prefix, ".")
+		if column == "" {
+			column = "value"
+		}
+		addColumn(column, v)
+		return []map[string]string{{column: cell(v)}}
+	}
+
+	var nested []member
+	for _, m := range obj {
+		if isObjectList(m.value) {
+			nested = append(nested, m)
+		}
+	}
+
+	row := make(map[string]string)
+	var expand *member
+	for i, m := range obj {
+		column := prefix + m.key
+		switch {
+		case isObjectList(m.value) && len(nested) == 1:
+			expand = &obj[i]
+		case isObjectList(m.value):
+			addColumn(column, m.value)
+			row[column] = fmt.Sprint(len(m.value.([]interface{})))
+		default:
+			if child, ok := m.value.(object); ok {
+				for _, childRow := range flattenRecord(child, column+".", addColumn) {
+					for k, v := range childRow {
+						row[k] = v
+					}
+				}
+				continue
+			}
+			addColumn(column, m.value)
+			row[column] = cell(m.value)
+		}
+	}
+
+	if expand == nil {
+		return []map[string]string{row}
+	}
+	elements := expand.value.([]interface{})
+	if len(elements) == 0 {
+		return []map[string]string{row}
+	}
+	var rows []map[string]string
+	for _, element := range elements {
+		for _, childRow := range flattenRecord(element, prefix+expand.key+".", addColumn) {
+			merged := make(map[string]string, len(row)+len(childRow))
+			for k, v := range row {
+				merged[k] = v
+			}
+			for k, v := range childRow {
+				merged[k] = v
+			}
+			rows = append(rows, merged)
+		}
+	}
+	return rows
+}
+
+func isObjectList(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	_, ok = list[0].(object)
+	return ok
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		cells := make([]string, len(v))
+		for i, item := range v {
+			cells[i] = cell(item)
+		}
+		return strings.Join(cells, "; ")
+	case object:
+		raw, _ := json.Marshal(v.toMap())
+		return string(raw)
+	}
+	return fmt.Sprint(v)
+}
+
+func (o object) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(o))
+	for _, member := range o {
+		if child, ok := member.value.(object); ok {
+			m[member.key] = child.toMap()
+		} else {
+			m[member.key] = member.value
+		}
+	}
+	return m
+}
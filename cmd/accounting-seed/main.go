@@ -0,0 +1,111 @@
+// Command accounting-seed fills the stores of the service with a synthetic
+// university. The same flags always produce the same data.
+//
+//	accounting-seed [-config file] [-reset] [-dry-run] [generator flags]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/bootstrap"
+	"github.com/AlanMute/university-accounting/internal/config"
+	"github.com/AlanMute/university-accounting/internal/seed"
+	"github.com/AlanMute/university-accounting/internal/storage"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg := seed.DefaultConfig()
+	flags := flag.NewFlagSet("accounting-seed", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", os.Getenv("CONFIG_PATH"), "path to the JSON config file")
+	reset := flags.Bool("reset", false, "remove previously seeded data from every store first")
+	dryRun := flags.Bool("dry-run", false, "generate the data and print its size without writing it")
+	flags.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	flags.IntVar(&cfg.Departments, "departments", cfg.Departments, "number of departments")
+	flags.IntVar(&cfg.GroupsPerDepartment, "groups", cfg.GroupsPerDepartment, "groups per department")
+	flags.IntVar(&cfg.StudentsPerGroup, "students", cfg.StudentsPerGroup, "students per group")
+	flags.IntVar(&cfg.TeachersPerDepartment, "teachers", cfg.TeachersPerDepartment, "teachers per department")
+	flags.IntVar(&cfg.Disciplines, "disciplines", cfg.Disciplines, "number of disciplines")
+	flags.IntVar(&cfg.DisciplinesPerGroup, "group-disciplines", cfg.DisciplinesPerGroup, "disciplines each group studies over the year")
+	flags.IntVar(&cfg.LessonsPerDiscipline, "lessons", cfg.LessonsPerDiscipline, "lessons per discipline, one a week")
+	flags.IntVar(&cfg.MaterialsPerLesson, "materials", cfg.MaterialsPerLesson, "materials per lesson")
+	flags.Float64Var(&cfg.SpecialShare, "special-share", cfg.SpecialShare, "share of special disciplines")
+	flags.Float64Var(&cfg.AtRiskShare, "at-risk-share", cfg.AtRiskShare, "share of students with low attendance")
+	flags.IntVar(&cfg.Year, "year", cfg.Year, "first year of the academic year to schedule")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %v\n", flags.Args())
+		return 2
+	}
+	if err := seed.ValidateConfig(cfg); err != nil {
+		fmt.Fprintf(stderr, "invalid generator flags: %v\n", err)
+		return 2
+	}
+
+	data := seed.Generate(cfg)
+	printSummary(stdout, data)
+	if *dryRun {
+		return 0
+	}
+
+	if err := write(*configPath, data, *reset); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "seeded Postgres, Redis, Elasticsearch, Neo4j and MongoDB")
+	return 0
+}
+
+func write(configPath string, data *seed.Dataset, reset bool) error {
+	appConfig, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	ctx := context.Background()
+	clients, err := storage.Open(ctx, appConfig)
+	if err != nil {
+		return err
+	}
+	defer clients.Close(ctx)
+	for _, backend := range storage.Backends {
+		if err := clients.Ping(ctx, backend); err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", storage.Title(backend), err)
+		}
+	}
+
+	// Indices created by the bulk writes would get dynamic mappings and
+	// take the names of the aliases.
+	if _, err := bootstrap.NewBootstrapper(clients.Elastic, clients.Neo4j).Apply(ctx); err != nil {
+		return fmt.Errorf("failed to bootstrap indices and constraints: %v", err)
+	}
+
+	writer := seed.NewWriter(clients, appConfig.Mongo.Database)
+	if reset {
+		if err := writer.Reset(ctx); err != nil {
+			return err
+		}
+	}
+	return writer.Write(ctx, data)
+}
+
+func printSummary(w io.Writer, data *seed.Dataset) {
+	fmt.Fprintf(w, "departments: %d\n", len(data.Departments))
+	fmt.Fprintf(w, "groups:      %d\n", len(data.Groups))
+	fmt.Fprintf(w, "students:    %d\n", len(data.Students))
+	fmt.Fprintf(w, "teachers:    %d\n", len(data.Teachers))
+	fmt.Fprintf(w, "disciplines: %d\n", len(data.Disciplines))
+	fmt.Fprintf(w, "lessons:     %d\n", len(data.Lessons))
+	fmt.Fprintf(w, "materials:   %d\n", len(data.Materials))
+	fmt.Fprintf(w, "schedule:    %d\n", len(data.Schedule))
+	fmt.Fprintf(w, "attendance:  %d\n", len(data.Attendance))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/go-redis/redis/v8"
@@ -22,47 +23,69 @@ type Client struct {
 	neoClient   neo4j.Driver
 	pgdbClient  *sql.DB
 	esClient    *elasticsearch.Client
+
+	riskRules     []RiskRule
+	mongoDatabase string
 }
 
 func NewClient(redisClient *redis.Client, mongoClient *mongo.Client, neoClient neo4j.Driver, pgdbClient *sql.DB, esClient *elasticsearch.Client) *Client {
 	return &Client{
-		redisClient: redisClient,
-		mongoClient: mongoClient,
-		neoClient:   neoClient,
-		pgdbClient:  pgdbClient,
-		esClient:    esClient,
+		redisClient:   redisClient,
+		mongoClient:   mongoClient,
+		neoClient:     neoClient,
+		pgdbClient:    pgdbClient,
+		esClient:      esClient,
+		riskRules:     DefaultRiskRules(),
+		mongoDatabase: defaultMongoDatabase,
 	}
 }
 
 type StudentReport struct {
-	StudentID       string  `json:"student_id"`
-	Name            string  `json:"name"`
-	Group           string  `json:"group"`
-	Course          int     `json:"course"`
-	Department      string  `json:"department"`
-	Email           string  `json:"email"`
-	Birth           string  `json:"birth"`
-	AttendanceRate  float64 `json:"attendance_rate"`
-	ReportingPeriod string  `json:"reporting_period"`
-	MatchedTerm     string  `json:"matched_term"`
+	StudentID       string            `json:"student_id"`
+	Name            string            `json:"name"`
+	Group           string            `json:"group"`
+	Course          int               `json:"course"`
+	Department      string            `json:"department"`
+	Email           string            `json:"email"`
+	Birth           string            `json:"birth"`
+	AttendanceRate  float64           `json:"attendance_rate"`
+	ReportingPeriod string            `json:"reporting_period"`
+	MatchedTerm     string            `json:"matched_term"`
+	Materials       []MatchedMaterial `json:"materials"`
+	Lessons         []MatchedLesson   `json:"lessons"`
 }
 
-func (c *Client) GenerateAttendanceReport(term string, startDate, endDate string) ([]StudentReport, error) {
-	ctx := context.Background()
+type MatchedMaterial struct {
+	MaterialID int    `json:"material_id"`
+	Title      string `json:"title"`
+	Highlight  string `json:"highlight"`
+}
+
+type MatchedLesson struct {
+	LessonID    int64  `json:"lesson_id"`
+	Topic       string `json:"topic"`
+	Date        string `json:"date"`
+	Missed      bool   `json:"missed"`
+	MaterialIDs []int  `json:"material_ids"`
+}
+
+// GenerateAttendanceReport returns the students in scope with the lowest
+// attendance of the lectures matching the search. Student profiles and
+// lessons are only loaded when fields selects them.
+func (c *Client) GenerateAttendanceReport(scope Scope, fields FieldSet, search *TermSearch, startDate, endDate string) ([]StudentReport, error) {
+	return c.generateAttendanceReport(context.Background(), scope, fields, search, startDate, endDate, nil)
+}
+
+func (c *Client) generateAttendanceReport(ctx context.Context, scope Scope, fields FieldSet, search *TermSearch, startDate, endDate string, progress ReportProgress) ([]StudentReport, error) {
+	needProfile := fields.HasAny(nil, "name", "group", "course", "department", "email", "birth")
+	needLessons := fields.HasAny(nil, "lessons", "materials")
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match_phrase": map[string]interface{}{
-				"content": term,
-			},
-		},
-	}
 	var esResult map[string]interface{}
 
 	esRes, err := c.esClient.Search(
 		c.esClient.Search.WithContext(ctx),
 		c.esClient.Search.WithIndex("materials"),
-		c.esClient.Search.WithBody(strings.NewReader(mustJSON(query))),
+		c.esClient.Search.WithBody(strings.NewReader(mustJSON(search.esQuery()))),
 		c.esClient.Search.WithPretty(),
 	)
 	if err != nil {
@@ -74,37 +97,100 @@ func (c *Client) GenerateAttendanceReport(term string, startDate, endDate string
 		return nil, fmt.Errorf("failed to decode ElasticSearch response: %v", err)
 	}
 
-	matchingMaterials := extractMatchingMaterialIDs(esResult)
-	matchingLectures, err := c.getLecturesByMaterials(matchingMaterials)
+	matchingMaterials := extractMatchingMaterials(esResult)
+	materialIDs := make([]int, 0, len(matchingMaterials))
+	materialsByID := make(map[int]MatchedMaterial, len(matchingMaterials))
+	for _, material := range matchingMaterials {
+		materialIDs = append(materialIDs, material.MaterialID)
+		materialsByID[material.MaterialID] = material
+	}
+
+	materialsByLecture, err := c.getLecturesByMaterials(materialIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get lectures by materials: %v", err)
 	}
+	matchingLectures := make([]int64, 0, len(materialsByLecture))
+	for lectureID := range materialsByLecture {
+		matchingLectures = append(matchingLectures, lectureID)
+	}
 
-	attendanceData, err := c.getAttendanceData(matchingLectures, startDate, endDate)
+	attendanceData, err := c.getAttendanceData(scope, matchingLectures, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get attendance data: %v", err)
 	}
 
+	studentIDs := make([]string, 0, len(attendanceData))
+	for studentID := range attendanceData {
+		studentIDs = append(studentIDs, studentID)
+	}
+	// Students without a profile are left out of the report whatever the
+	// fields, so the projection only changes the columns, never the rows.
+	// Without profile fields only the existence of the keys is checked.
+	var profiled map[string]bool
+	if !needProfile {
+		profiled, err = c.studentsWithProfiles(ctx, studentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check student profiles: %v", err)
+		}
+	}
+	var lessonsByStudent map[string][]MatchedLesson
+	if needLessons {
+		lessonsByStudent, err = c.getStudentLessons(studentIDs, matchingLectures, startDate, endDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get student lessons: %v", err)
+		}
+	}
+
 	var reports []StudentReport
+	done := 0
 	for studentID, attendanceRate := range attendanceData {
-		student, err := c.getStudentDetails(ctx, studentID)
-		if err != nil {
-			logrus.Errorf("failed to get student details for ID %s: %v", studentID, err)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		progress.report(done, len(attendanceData))
+		done++
+		if !needProfile && !profiled[studentID] {
 			continue
 		}
 
-		reports = append(reports, StudentReport{
+		report := StudentReport{
 			StudentID:       studentID,
-			Name:            student["name"].(string),
-			Group:           student["group"].(string),
-			Course:          int(student["course"].(float64)),
-			Department:      student["department-name"].(string),
-			Email:           student["email"].(string),
-			Birth:           student["birth"].(string),
 			AttendanceRate:  attendanceRate,
 			ReportingPeriod: fmt.Sprintf("%s to %s", startDate, endDate),
-			MatchedTerm:     term,
-		})
+			MatchedTerm:     search.Query,
+		}
+
+		if needProfile {
+			student, err := c.getStudentDetails(ctx, studentID)
+			if err != nil {
+				logrus.Errorf("failed to get student details for ID %s: %v", studentID, err)
+				continue
+			}
+			report.Name = student["name"].(string)
+			report.Group = student["group"].(string)
+			report.Course = int(student["course"].(float64))
+			report.Department = student["department-name"].(string)
+			report.Email = student["email"].(string)
+			report.Birth = student["birth"].(string)
+		}
+
+		lessons := lessonsByStudent[studentID]
+		var materials []MatchedMaterial
+		seenMaterials := make(map[int]struct{})
+		for i, lesson := range lessons {
+			lessons[i].MaterialIDs = materialsByLecture[lesson.LessonID]
+			for _, materialID := range lessons[i].MaterialIDs {
+				if _, ok := seenMaterials[materialID]; ok {
+					continue
+				}
+				seenMaterials[materialID] = struct{}{}
+				materials = append(materials, materialsByID[materialID])
+			}
+		}
+
+		report.Materials = materials
+		report.Lessons = lessons
+		reports = append(reports, report)
 	}
 
 	sort.Slice(reports, func(i, j int) bool {
@@ -118,8 +204,9 @@ func (c *Client) GenerateAttendanceReport(term string, startDate, endDate string
 	return reports, nil
 }
 
-func (c *Client) getAttendanceData(lectureIDs []int64, startDate, endDate string) (map[string]float64, error) {
-	rows, err := c.pgdbClient.Query(getAttendanceDataQuery, pq.Array(lectureIDs), startDate, endDate)
+func (c *Client) getAttendanceData(scope Scope, lectureIDs []int64, startDate, endDate string) (map[string]float64, error) {
+	args := append([]interface{}{pq.Array(lectureIDs), startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getAttendanceDataQuery, scopeStudentFilter("s", 4)), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to getAttendanceDataQuery PostgreSQL: %v", err)
 	}
@@ -137,6 +224,27 @@ func (c *Client) getAttendanceData(lectureIDs []int64, startDate, endDate string
 	return attendanceData, nil
 }
 
+// studentsWithProfiles returns which of the students have a student:<card_id>
+// key in Redis.
+func (c *Client) studentsWithProfiles(ctx context.Context, studentIDs []string) (map[string]bool, error) {
+	pipe := c.redisClient.Pipeline()
+	exists := make([]*redis.IntCmd, 0, len(studentIDs))
+	for _, studentID := range studentIDs {
+		exists = append(exists, pipe.Exists(ctx, fmt.Sprintf("student:%s", studentID)))
+	}
+	if len(exists) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	profiled := make(map[string]bool, len(studentIDs))
+	for i, cmd := range exists {
+		profiled[studentIDs[i]] = cmd.Val() > 0
+	}
+	return profiled, nil
+}
+
 func (c *Client) getStudentDetails(ctx context.Context, studentID string) (map[string]interface{}, error) {
 	data, err := c.redisClient.Get(ctx, fmt.Sprintf("student:%s", studentID)).Result()
 	if err != nil {
@@ -150,14 +258,38 @@ func (c *Client) getStudentDetails(ctx context.Context, studentID string) (map[s
 	return student, nil
 }
 
-func (c *Client) getLecturesByMaterials(materialIDs []int) ([]int64, error) {
+func (c *Client) getStudentLessons(studentIDs []string, lectureIDs []int64, startDate, endDate string) (map[string][]MatchedLesson, error) {
+	rows, err := c.pgdbClient.Query(getStudentLessonsQuery, pq.Array(studentIDs), pq.Array(lectureIDs), startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query student lessons: %v", err)
+	}
+	defer rows.Close()
+
+	lessons := make(map[string][]MatchedLesson)
+	for rows.Next() {
+		var studentID string
+		var lesson MatchedLesson
+		var attended bool
+		if err := rows.Scan(&studentID, &lesson.LessonID, &lesson.Topic, &lesson.Date, &attended); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		lesson.Missed = !attended
+		lessons[studentID] = append(lessons[studentID], lesson)
+	}
+
+	return lessons, nil
+}
+
+// getLecturesByMaterials returns the IDs of the materials linked to each
+// lesson through MAT_LES.
+func (c *Client) getLecturesByMaterials(materialIDs []int) (map[int64][]int, error) {
 	session := c.neoClient.NewSession(neo4j.SessionConfig{})
 	defer session.Close()
 
 	query :=
 		`MATCH (m:Material)-[:MAT_LES]->(l:Lesson)
 	WHERE m.id IN $materialIDs
-	RETURN l.id AS lessonID`
+	RETURN l.id AS lessonID, m.id AS materialID`
 
 	params := map[string]interface{}{
 		"materialIDs": materialIDs,
@@ -168,26 +300,55 @@ func (c *Client) getLecturesByMaterials(materialIDs []int) ([]int64, error) {
 		return nil, fmt.Errorf("failed to query Neo4j: %v", err)
 	}
 
-	var lectureIDs []int64
+	materialsByLecture := make(map[int64][]int)
 	for result.Next() {
-		lectureIDs = append(lectureIDs, result.Record().GetByIndex(0).(int64))
+		record := result.Record()
+		lectureID := record.GetByIndex(0).(int64)
+		materialID := int(record.GetByIndex(1).(int64))
+		materialsByLecture[lectureID] = append(materialsByLecture[lectureID], materialID)
 	}
 
-	return lectureIDs, nil
+	return materialsByLecture, nil
 }
 
-func extractMatchingMaterialIDs(esResult map[string]interface{}) []int {
-	var materialIDs []int
+func extractMatchingMaterials(esResult map[string]interface{}) []MatchedMaterial {
+	var materials []MatchedMaterial
 	hits := esResult["hits"].(map[string]interface{})["hits"].([]interface{})
 	for _, hit := range hits {
-		source := hit.(map[string]interface{})["_source"].(map[string]interface{})
-		if materialIDStr, ok := source["material_id"].(string); ok {
-			if materialID, err := strconv.Atoi(materialIDStr); err == nil {
-				materialIDs = append(materialIDs, materialID)
-			}
+		hitMap := hit.(map[string]interface{})
+		source := hitMap["_source"].(map[string]interface{})
+		materialIDStr, ok := source["material_id"].(string)
+		if !ok {
+			continue
+		}
+		materialID, err := strconv.Atoi(materialIDStr)
+		if err != nil {
+			continue
+		}
+
+		material := MatchedMaterial{MaterialID: materialID}
+		material.Title, _ = source["title"].(string)
+		if highlight, ok := hitMap["highlight"].(map[string]interface{}); ok {
+			material.Highlight = firstHighlight(highlight)
 		}
+		materials = append(materials, material)
 	}
-	return materialIDs
+	return materials
+}
+
+// firstHighlight picks the snippet from the most descriptive field that
+// produced one.
+func firstHighlight(highlight map[string]interface{}) string {
+	for _, field := range []string{"content", "title", "tags"} {
+		fragments, ok := highlight[field].([]interface{})
+		if !ok || len(fragments) == 0 {
+			continue
+		}
+		if fragment, ok := fragments[0].(string); ok {
+			return fragment
+		}
+	}
+	return ""
 }
 
 func mustJSON(v interface{}) string {
@@ -209,7 +370,14 @@ type LectureInfo struct {
 	TechEquipments []string `json:"tech_equipments"`
 }
 
-func (c *Client) GenerateCourseReport(year, semester int) ([]CourseReport, error) {
+// GenerateCourseReport lists the lectures of the semester. A restricted scope
+// keeps only lessons scheduled for groups in it. Lectures are only loaded when
+// fields selects them.
+func (c *Client) GenerateCourseReport(scope Scope, fields FieldSet, year, semester int) ([]CourseReport, error) {
+	return c.generateCourseReport(context.Background(), scope, fields, year, semester, nil)
+}
+
+func (c *Client) generateCourseReport(ctx context.Context, scope Scope, fields FieldSet, year, semester int, progress ReportProgress) ([]CourseReport, error) {
 	var reports []CourseReport = make([]CourseReport, 0)
 	var startDate, endDate string
 	if semester == 1 {
@@ -220,7 +388,7 @@ func (c *Client) GenerateCourseReport(year, semester int) ([]CourseReport, error
 		endDate = fmt.Sprintf("%d-08-31", year+1)
 	}
 
-	disciplineIDs, err := c.getDisciplinesForDateRange(startDate, endDate)
+	disciplineIDs, err := c.getDisciplinesForDateRange(scope, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get disciplines: %v", err)
 	}
@@ -234,13 +402,18 @@ func (c *Client) GenerateCourseReport(year, semester int) ([]CourseReport, error
 		return nil, fmt.Errorf("failed to get discipline details: %v", err)
 	}
 
-	for _, discipline := range disciplineData {
+	for i, discipline := range disciplineData {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		disciplineID := discipline["discipline_id"].(string)
 
-		lectures, err := c.getLecturesWithDetails(disciplineID, startDate, endDate)
-
-		if err != nil {
-			return nil, fmt.Errorf("failed to get lectures for discipline %d: %v", disciplineID, err)
+		var lectures []LectureInfo
+		if fields.Has("lectures") {
+			lectures, err = c.getLecturesWithDetails(scope, disciplineID, startDate, endDate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get lectures for discipline %s: %v", disciplineID, err)
+			}
 		}
 
 		reports = append(reports, CourseReport{
@@ -248,13 +421,15 @@ func (c *Client) GenerateCourseReport(year, semester int) ([]CourseReport, error
 			DisciplineDescription: discipline["description"].(string),
 			Lectures:              lectures,
 		})
+		progress.report(i+1, len(disciplineData))
 	}
 
 	return reports, nil
 }
 
-func (c *Client) getDisciplinesForDateRange(startDate, endDate string) ([]int, error) {
-	rows, err := c.pgdbClient.Query(getDisciplinesForDateQuery, startDate, endDate)
+func (c *Client) getDisciplinesForDateRange(scope Scope, startDate, endDate string) ([]int, error) {
+	args := append([]interface{}{startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getDisciplinesForDateQuery, scopeGroupFilter("sch.group_id", 3)), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query disciplines: %v", err)
 	}
@@ -319,8 +494,9 @@ func (c *Client) getDisciplinesFromElastic(disciplineIDs []int) ([]map[string]in
 	return disciplines, nil
 }
 
-func (c *Client) getLecturesWithDetails(disciplineID, startDate, endDate string) ([]LectureInfo, error) {
-	rows, err := c.pgdbClient.Query(getLecturesWithDetailsQuery, disciplineID, startDate, endDate)
+func (c *Client) getLecturesWithDetails(scope Scope, disciplineID, startDate, endDate string) ([]LectureInfo, error) {
+	args := append([]interface{}{disciplineID, startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getLecturesWithDetailsQuery, scopeGroupFilter("sch.group_id", 4)), args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query lectures: %v", err)
 	}
@@ -377,42 +553,89 @@ type DisciplineReport struct {
 	AttendedHours int    `json:"attended_hours"`
 }
 
-func (c *Client) GenerateGroupReport(groupName string) (*GroupReport, error) {
-	groupID, studentIDs, err := c.getGroupAndStudentsByName(groupName)
+// GenerateGroupReport returns ErrOutOfScope for a group outside a restricted
+// scope. Within the group, only students in scope are listed. Student
+// profiles, discipline descriptions and hours are only loaded when fields
+// selects them.
+func (c *Client) GenerateGroupReport(scope Scope, fields FieldSet, groupName string) (*GroupReport, error) {
+	return c.generateGroupReport(context.Background(), scope, fields, groupName, nil)
+}
+
+func (c *Client) generateGroupReport(ctx context.Context, scope Scope, fields FieldSet, groupName string, progress ReportProgress) (*GroupReport, error) {
+	studentPath := []string{"students"}
+	disciplinePath := []string{"students", "disciplines"}
+	needProfile := fields.HasAny(studentPath, "name", "group", "course", "email", "birth")
+	needDisciplines := fields.Has(disciplinePath...)
+	needCatalog := fields.HasAny(disciplinePath, "name", "description")
+	needPlanned := fields.HasAny(disciplinePath, "planned_hours")
+	needAttended := fields.HasAny(disciplinePath, "attended_hours")
+
+	inScope, err := c.groupInScope(scope, groupName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get group and students: %v", err)
+		return nil, err
+	}
+	if !inScope {
+		return nil, ErrOutOfScope
 	}
 
-	students, err := c.getStudentsInfoFromRedis(studentIDs)
+	groupID, studentIDs, err := c.getGroupAndStudentsByName(scope, groupName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get students info from Redis: %v", err)
+		return nil, fmt.Errorf("failed to get group and students: %v", err)
 	}
 
-	disciplineIDs, err := c.getSpecialDisciplinesForGroup(groupID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get special disciplines: %v", err)
+	var students []StudentInfo
+	if needProfile {
+		students, err = c.getStudentsInfoFromRedis(studentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get students info from Redis: %v", err)
+		}
+	} else {
+		students = make([]StudentInfo, 0, len(studentIDs))
+		for _, studentID := range studentIDs {
+			students = append(students, StudentInfo{StudentID: studentID})
+		}
 	}
 
+	var disciplineIDs []int
+	if needDisciplines {
+		disciplineIDs, err = c.getSpecialDisciplinesForGroup(groupID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get special disciplines: %v", err)
+		}
+	}
+
+	// The catalog entry of a discipline is the same for every student.
+	catalog := make(map[int]map[string]interface{}, len(disciplineIDs))
 	for i, student := range students {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		for _, disciplineID := range disciplineIDs {
-			discipline, err := c.getDisciplineFromElastic(disciplineID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get discipline description: %v", err)
+			var report DisciplineReport
+			if needCatalog {
+				discipline, ok := catalog[disciplineID]
+				if !ok {
+					discipline, err = c.getDisciplineFromElastic(disciplineID)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get discipline description: %v", err)
+					}
+					catalog[disciplineID] = discipline
+				}
+				report.Name = discipline["name"].(string)
+				report.Description = discipline["description"].(string)
 			}
 
-			plannedHours, attendedHours, err := c.calculateHours(groupID, student.StudentID, disciplineID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to calculate hours: %v", err)
+			if needPlanned || needAttended {
+				report.PlannedHours, report.AttendedHours, err = c.calculateHours(groupID, student.StudentID, disciplineID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to calculate hours: %v", err)
+				}
 			}
 
-			student.Disciplines = append(student.Disciplines, DisciplineReport{
-				Name:          discipline["name"].(string),
-				Description:   discipline["description"].(string),
-				PlannedHours:  plannedHours,
-				AttendedHours: attendedHours,
-			})
+			student.Disciplines = append(student.Disciplines, report)
 		}
 		students[i] = student
+		progress.report(i+1, len(students))
 	}
 
 	return &GroupReport{
@@ -454,6 +677,9 @@ func (c *Client) getStudentsInfoFromRedis(studentIDs []string) ([]StudentInfo, e
 		if err := json.Unmarshal([]byte(data), &student); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal student data: %v", err)
 		}
+		if student.StudentID == "" {
+			student.StudentID = studentID
+		}
 
 		students = append(students, student)
 	}
@@ -461,8 +687,9 @@ func (c *Client) getStudentsInfoFromRedis(studentIDs []string) ([]StudentInfo, e
 	return students, nil
 }
 
-func (c *Client) getGroupAndStudentsByName(groupName string) (int, []string, error) {
-	rows, err := c.pgdbClient.Query(getGroupAndStudentsByNameQuery, groupName)
+func (c *Client) getGroupAndStudentsByName(scope Scope, groupName string) (int, []string, error) {
+	args := append([]interface{}{groupName}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getGroupAndStudentsByNameQuery, scopeStudentFilter("s", 2)), args...)
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to query group and students: %v", err)
 	}
@@ -527,8 +754,9 @@ func (c *Client) calculateHours(groupID int, studentID string, disciplineID int)
 	return plannedHours, attendedHours, nil
 }
 
-func (c *Client) GetAllGroups() ([]string, error) {
-	rows, err := c.pgdbClient.Query(getAllGroupsQuery)
+// GetAllGroups returns the names of the groups visible in the scope.
+func (c *Client) GetAllGroups(scope Scope) ([]string, error) {
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getAllGroupsQuery, scopeGroupFilter("g.group_id", 1)), scope.args()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query group and students: %v", err)
 	}
@@ -545,3 +773,16 @@ func (c *Client) GetAllGroups() ([]string, error) {
 
 	return groups, nil
 }
+
+// GetGroupCurator returns the curator email of the group, or an empty string
+// when the group has no curator assigned.
+func (c *Client) GetGroupCurator(groupName string) (string, error) {
+	var email string
+	if err := c.pgdbClient.QueryRow(getGroupCuratorQuery, groupName).Scan(&email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get group curator: %v", err)
+	}
+	return email, nil
+}
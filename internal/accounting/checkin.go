@@ -0,0 +1,57 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrNotEnrolled = errors.New("student is not in the group of the scheduled lesson")
+
+// ScheduledLesson is one session of a lesson for a group.
+type ScheduledLesson struct {
+	ScheduleID   int64  `json:"schedule_id"`
+	LessonID     int64  `json:"lesson_id"`
+	DisciplineID int    `json:"discipline_id"`
+	Group        string `json:"group"`
+	Topic        string `json:"topic"`
+	Type         string `json:"type"`
+	Date         string `json:"date"`
+	// Today is whether the session is scheduled for the current date.
+	Today bool `json:"-"`
+}
+
+// GetScheduledLesson fails with ErrScheduleNotFound for an unknown session
+// and with ErrOutOfScope for a group the scope does not cover or, for a
+// teacher, a session someone else teaches.
+func (c *Client) GetScheduledLesson(ctx context.Context, scope Scope, scheduleID int64) (*ScheduledLesson, error) {
+	lesson := &ScheduledLesson{}
+	var lessonType int
+	var inScope bool
+	args := append([]interface{}{scheduleID}, scope.args()...)
+	err := c.pgdbClient.QueryRowContext(ctx, fmt.Sprintf(getScheduledLessonQuery, scopeGroupFilter("sch.group_id", 2), scopeSessionFilter("sch", "l", 2)), args...).
+		Scan(&lesson.ScheduleID, &lesson.LessonID, &lesson.DisciplineID, &lesson.Group, &lesson.Topic, &lessonType, &lesson.Date, &lesson.Today, &inScope)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrScheduleNotFound, scheduleID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled lesson: %v", err)
+	}
+	if !inScope {
+		return nil, ErrOutOfScope
+	}
+	lesson.Type = typeToStringLesson[lessonType]
+	return lesson, nil
+}
+
+// MarkAttended records the student as present at the session, overwriting
+// an absence marked earlier. It fails with ErrNotEnrolled when the student
+// is not in the session's group.
+func (c *Client) MarkAttended(ctx context.Context, scheduleID int64, cardID string) error {
+	res, err := c.pgdbClient.ExecContext(ctx, markAttendedQuery, scheduleID, cardID)
+	if err != nil {
+		return fmt.Errorf("failed to record attendance: %v", err)
+	}
+	return expectAffected(res, ErrNotEnrolled)
+}
@@ -0,0 +1,462 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Consistency checks compare what one store references with what another
+// holds. The reports skip such gaps quietly, so they are easy to miss.
+const (
+	// CheckMissingProfile finds students in Postgres without a Redis
+	// profile. The name and contacts only live in the profile, so there is
+	// no repair.
+	CheckMissingProfile = "missing_profile"
+	// CheckOrphanLessonNode finds Neo4j Lesson nodes whose ID is not in the
+	// Postgres lesson table. Repair deletes the nodes with their edges.
+	CheckOrphanLessonNode = "orphan_lesson_node"
+	// CheckDisciplineWithoutDocument finds disciplines used in Postgres
+	// without an Elasticsearch catalog entry. Their name and description
+	// only live in the catalog, so there is no repair.
+	CheckDisciplineWithoutDocument = "discipline_without_document"
+	// CheckMaterialWithoutLinks finds indexed materials with no MAT_LES
+	// edge. Repair links them to the lessons listed in the document's
+	// lesson_ids, when it has any.
+	CheckMaterialWithoutLinks = "material_without_links"
+)
+
+var ConsistencyChecks = []string{CheckMissingProfile, CheckOrphanLessonNode, CheckDisciplineWithoutDocument, CheckMaterialWithoutLinks}
+
+// consistencyBatch bounds the keys sent to a store in one request.
+const consistencyBatch = 1000
+
+type ConsistencyReport struct {
+	CheckedAt time.Time            `json:"checked_at"`
+	Repair    bool                 `json:"repair"`
+	Checks    []ConsistencySummary `json:"checks"`
+	Issues    []ConsistencyIssue   `json:"issues"`
+}
+
+type ConsistencySummary struct {
+	Check    string `json:"check"`
+	Issues   int    `json:"issues"`
+	Repaired int    `json:"repaired"`
+}
+
+type ConsistencyIssue struct {
+	Check    string `json:"check"`
+	Key      string `json:"key"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+	// RepairError explains why a repair was not made.
+	RepairError string `json:"repair_error,omitempty"`
+}
+
+// Unresolved counts the issues left after the run.
+func (r *ConsistencyReport) Unresolved() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// ParseConsistencyChecks parses a comma-separated list of checks, all of them
+// when the list is empty.
+func ParseConsistencyChecks(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return ConsistencyChecks, nil
+	}
+	known := make(map[string]bool, len(ConsistencyChecks))
+	for _, check := range ConsistencyChecks {
+		known[check] = true
+	}
+
+	var checks []string
+	for _, check := range strings.Split(list, ",") {
+		check = strings.TrimSpace(check)
+		if !known[check] {
+			return nil, fmt.Errorf("unknown check %q, expected one of %s", check, strings.Join(ConsistencyChecks, ", "))
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// CheckConsistency runs the checks and, with repair set, fixes what can be
+// fixed without inventing data.
+func (c *Client) CheckConsistency(ctx context.Context, checks []string, repair bool) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{CheckedAt: time.Now().UTC(), Repair: repair, Issues: []ConsistencyIssue{}}
+
+	run := map[string]func(context.Context, bool) ([]ConsistencyIssue, error){
+		CheckMissingProfile:            c.checkMissingProfiles,
+		CheckOrphanLessonNode:          c.checkOrphanLessonNodes,
+		CheckDisciplineWithoutDocument: c.checkDisciplineDocuments,
+		CheckMaterialWithoutLinks:      c.checkMaterialLinks,
+	}
+	for _, check := range checks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		issues, err := run[check](ctx, repair)
+		if err != nil {
+			return nil, fmt.Errorf("check %s failed: %v", check, err)
+		}
+
+		summary := ConsistencySummary{Check: check, Issues: len(issues)}
+		for _, issue := range issues {
+			if issue.Repaired {
+				summary.Repaired++
+			}
+		}
+		report.Checks = append(report.Checks, summary)
+		report.Issues = append(report.Issues, issues...)
+	}
+	return report, nil
+}
+
+func (c *Client) checkMissingProfiles(ctx context.Context, _ bool) ([]ConsistencyIssue, error) {
+	rows, err := c.pgdbClient.QueryContext(ctx, getStudentProfileSourcesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query students: %v", err)
+	}
+	defer rows.Close()
+
+	var students []StudentProfile
+	var cardIDs []string
+	for rows.Next() {
+		var cardID string
+		var profile StudentProfile
+		if err := rows.Scan(&cardID, &profile.Group, &profile.Department); err != nil {
+			return nil, fmt.Errorf("failed to scan student: %v", err)
+		}
+		cardIDs = append(cardIDs, cardID)
+		students = append(students, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var issues []ConsistencyIssue
+	for start := 0; start < len(cardIDs); start += consistencyBatch {
+		end := min(start+consistencyBatch, len(cardIDs))
+		pipe := c.redisClient.Pipeline()
+		exists := make([]*redis.IntCmd, 0, end-start)
+		for _, cardID := range cardIDs[start:end] {
+			exists = append(exists, pipe.Exists(ctx, fmt.Sprintf("student:%s", cardID)))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to check Redis profiles: %v", err)
+		}
+
+		for i, cmd := range exists {
+			if cmd.Val() > 0 {
+				continue
+			}
+			cardID, profile := cardIDs[start+i], students[start+i]
+			issues = append(issues, ConsistencyIssue{
+				Check:       CheckMissingProfile,
+				Key:         cardID,
+				Detail:      fmt.Sprintf("student of group %s has no student:%s key in Redis", profile.Group, cardID),
+				RepairError: "not repairable: the name and contacts only live in the Redis profile",
+			})
+		}
+	}
+	return issues, nil
+}
+
+func (c *Client) checkOrphanLessonNodes(ctx context.Context, repair bool) ([]ConsistencyIssue, error) {
+	nodeIDs, err := c.neo4jIDs(`MATCH (l:Lesson) RETURN DISTINCT l.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Neo4j lessons: %v", err)
+	}
+	return c.orphanLessonIssues(ctx, nodeIDs, repair)
+}
+
+func (c *Client) orphanLessonIssues(ctx context.Context, nodeIDs []int64, repair bool) ([]ConsistencyIssue, error) {
+	known, err := c.existingLessons(ctx, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []int64
+	var issues []ConsistencyIssue
+	for _, id := range nodeIDs {
+		if known[id] {
+			continue
+		}
+		orphans = append(orphans, id)
+		issues = append(issues, ConsistencyIssue{
+			Check:  CheckOrphanLessonNode,
+			Key:    strconv.FormatInt(id, 10),
+			Detail: fmt.Sprintf("Neo4j Lesson %d is not in the Postgres lesson table", id),
+		})
+	}
+
+	if repair && len(orphans) > 0 {
+		// An empty lesson table next to a populated graph is far more likely
+		// a wrong database than a graph full of orphans.
+		err := fmt.Errorf("not repaired: Postgres has none of the %d Neo4j lessons, refusing to delete them all", len(nodeIDs))
+		if len(known) > 0 {
+			err = c.neo4jWrite(`MATCH (l:Lesson) WHERE l.id IN $ids DETACH DELETE l`, map[string]interface{}{"ids": orphans})
+		}
+		for i := range issues {
+			issues[i].Repaired, issues[i].RepairError = repairResult(err)
+		}
+	}
+	return issues, nil
+}
+
+// existingLessons returns which of the IDs are in the Postgres lesson table.
+func (c *Client) existingLessons(ctx context.Context, ids []int64) (map[int64]bool, error) {
+	known := make(map[int64]bool, len(ids))
+	for start := 0; start < len(ids); start += consistencyBatch {
+		end := min(start+consistencyBatch, len(ids))
+		rows, err := c.pgdbClient.QueryContext(ctx, getExistingLessonsQuery, pq.Array(ids[start:end]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query lessons: %v", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan lesson: %v", err)
+			}
+			known[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lessons: %v", err)
+		}
+	}
+	return known, nil
+}
+
+func (c *Client) checkDisciplineDocuments(ctx context.Context, _ bool) ([]ConsistencyIssue, error) {
+	rows, err := c.pgdbClient.QueryContext(ctx, getUsedDisciplinesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disciplines: %v", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan discipline: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disciplines: %v", err)
+	}
+
+	var issues []ConsistencyIssue
+	for start := 0; start < len(ids); start += consistencyBatch {
+		batch := ids[start:min(start+consistencyBatch, len(ids))]
+		records, err := c.DisciplineRecords(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range batch {
+			if _, ok := records[id]; ok {
+				continue
+			}
+			issues = append(issues, ConsistencyIssue{
+				Check:       CheckDisciplineWithoutDocument,
+				Key:         strconv.Itoa(id),
+				Detail:      fmt.Sprintf("discipline %d is used in Postgres but missing from the disciplines index", id),
+				RepairError: "not repairable: the catalog is the only source of the discipline's name",
+			})
+		}
+	}
+	return issues, nil
+}
+
+func (c *Client) checkMaterialLinks(ctx context.Context, repair bool) ([]ConsistencyIssue, error) {
+	linked, err := c.neo4jIDs(`MATCH (m:Material)-[:MAT_LES]->(:Lesson) RETURN DISTINCT m.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list linked materials: %v", err)
+	}
+	isLinked := make(map[int64]bool, len(linked))
+	for _, id := range linked {
+		isLinked[id] = true
+	}
+
+	sources, err := c.scrollSources(ctx, "materials", []string{"material_id", "title", "lesson_ids"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list materials: %v", err)
+	}
+
+	var issues []ConsistencyIssue
+	for _, source := range sources {
+		id, err := strconv.ParseInt(fmt.Sprint(source["material_id"]), 10, 64)
+		if err != nil || isLinked[id] {
+			continue
+		}
+		title, _ := source["title"].(string)
+		issue := ConsistencyIssue{
+			Check:  CheckMaterialWithoutLinks,
+			Key:    strconv.FormatInt(id, 10),
+			Detail: fmt.Sprintf("material %d %q has no MAT_LES edge", id, title),
+		}
+		if repair {
+			issue.Repaired, issue.RepairError = repairResult(c.linkMaterial(ctx, id, source["lesson_ids"]))
+		}
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		a, _ := strconv.Atoi(issues[i].Key)
+		b, _ := strconv.Atoi(issues[j].Key)
+		return a < b
+	})
+	return issues, nil
+}
+
+// linkMaterial creates the MAT_LES edges listed in the material's document,
+// to the lessons that exist in Postgres.
+func (c *Client) linkMaterial(ctx context.Context, materialID int64, lessonIDs interface{}) error {
+	list, _ := lessonIDs.([]interface{})
+	var ids []int64
+	for _, value := range list {
+		if id, err := strconv.ParseInt(fmt.Sprint(value), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("not repairable: the document lists no lesson_ids")
+	}
+
+	known, err := c.existingLessons(ctx, ids)
+	if err != nil {
+		return err
+	}
+	var existing []int64
+	for _, id := range ids {
+		if known[id] {
+			existing = append(existing, id)
+		}
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("not repairable: none of lessons %v exist", ids)
+	}
+
+	return c.neo4jWrite(`MERGE (m:Material {id: $material})
+	WITH m
+	UNWIND $lessons AS lessonID
+	MERGE (l:Lesson {id: lessonID})
+	MERGE (m)-[:MAT_LES]->(l)`, map[string]interface{}{"material": materialID, "lessons": existing})
+}
+
+func repairResult(err error) (bool, string) {
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+func (c *Client) neo4jIDs(query string) ([]int64, error) {
+	session := c.neoClient.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	result, err := session.Run(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for result.Next() {
+		if id, ok := result.Record().GetByIndex(0).(int64); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, result.Err()
+}
+
+func (c *Client) neo4jWrite(query string, params map[string]interface{}) error {
+	session := c.neoClient.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(query, params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume()
+	})
+	return err
+}
+
+// scrollSources reads the given fields of every document in the index.
+func (c *Client) scrollSources(ctx context.Context, index string, fields []string) ([]map[string]interface{}, error) {
+	es := c.esClient
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(index),
+		es.Search.WithSize(consistencyBatch),
+		es.Search.WithScroll(time.Minute),
+		es.Search.WithSourceIncludes(fields...),
+	)
+
+	var sources []map[string]interface{}
+	var scrollID string
+	for {
+		if err != nil {
+			return nil, err
+		}
+		page, nextID, err := decodeScrollPage(res)
+		if err != nil {
+			return nil, err
+		}
+		scrollID = nextID
+		if len(page) == 0 {
+			break
+		}
+		sources = append(sources, page...)
+		res, err = es.Scroll(es.Scroll.WithContext(ctx), es.Scroll.WithScrollID(scrollID), es.Scroll.WithScroll(time.Minute))
+	}
+
+	if scrollID != "" {
+		if res, err := es.ClearScroll(es.ClearScroll.WithScrollID(scrollID)); err == nil {
+			res.Body.Close()
+		}
+	}
+	return sources, nil
+}
+
+func decodeScrollPage(res *esapi.Response) ([]map[string]interface{}, string, error) {
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, "", fmt.Errorf("search failed: %s", res.Status())
+	}
+
+	var result struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []struct {
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	page := make([]map[string]interface{}, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		page = append(page, hit.Source)
+	}
+	return page, result.ScrollID, nil
+}
@@ -0,0 +1,126 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// lessonDriver answers every query with the lesson IDs of its data source
+// name and then, if set, fails the read.
+type lessonDriver struct{}
+
+var lessonSources = map[string]struct {
+	ids []int64
+	err error
+}{}
+
+func init() {
+	sql.Register("consistency-lessons", lessonDriver{})
+}
+
+func (lessonDriver) Open(name string) (driver.Conn, error) { return lessonConn(name), nil }
+
+type lessonConn string
+
+func (c lessonConn) Prepare(string) (driver.Stmt, error) { return lessonStmt(c), nil }
+func (lessonConn) Close() error                          { return nil }
+func (lessonConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+type lessonStmt string
+
+func (lessonStmt) Close() error  { return nil }
+func (lessonStmt) NumInput() int { return -1 }
+func (lessonStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s lessonStmt) Query([]driver.Value) (driver.Rows, error) {
+	source := lessonSources[string(s)]
+	return &lessonRows{ids: source.ids, err: source.err}, nil
+}
+
+type lessonRows struct {
+	ids []int64
+	err error
+}
+
+func (*lessonRows) Columns() []string { return []string{"lesson_id"} }
+func (*lessonRows) Close() error      { return nil }
+func (r *lessonRows) Next(dest []driver.Value) error {
+	if len(r.ids) == 0 {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	dest[0], r.ids = r.ids[0], r.ids[1:]
+	return nil
+}
+
+// lessonClient has no Neo4j driver, so a repair that reaches the delete
+// panics.
+func lessonClient(t *testing.T, ids []int64, err error) *Client {
+	t.Helper()
+	lessonSources[t.Name()] = struct {
+		ids []int64
+		err error
+	}{ids, err}
+	db, openErr := sql.Open("consistency-lessons", t.Name())
+	if openErr != nil {
+		t.Fatalf("open: %v", openErr)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Client{pgdbClient: db}
+}
+
+func TestOrphanLessonIssues(t *testing.T) {
+	c := lessonClient(t, []int64{1, 3}, nil)
+
+	issues, err := c.orphanLessonIssues(context.Background(), []int64{1, 2, 3, 4}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var keys []string
+	for _, issue := range issues {
+		keys = append(keys, issue.Key)
+		if issue.Repaired {
+			t.Errorf("lesson %s repaired without repair mode", issue.Key)
+		}
+	}
+	if got := strings.Join(keys, ","); got != "2,4" {
+		t.Errorf("orphans %s, want 2,4", got)
+	}
+}
+
+func TestOrphanLessonRepairAfterPartialRead(t *testing.T) {
+	c := lessonClient(t, []int64{1}, errors.New("connection reset"))
+
+	issues, err := c.orphanLessonIssues(context.Background(), []int64{1, 2, 3}, true)
+	if err == nil {
+		t.Fatalf("got %d issues, want the read error", len(issues))
+	}
+	if !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("error %q does not report the read failure", err)
+	}
+}
+
+func TestOrphanLessonRepairWithoutKnownLessons(t *testing.T) {
+	c := lessonClient(t, nil, nil)
+
+	issues, err := c.orphanLessonIssues(context.Background(), []int64{1, 2}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("got %d issues, want 2", len(issues))
+	}
+	for _, issue := range issues {
+		if issue.Repaired || !strings.Contains(issue.RepairError, "refusing") {
+			t.Errorf("lesson %s: repaired %v, repair error %q, want a refused repair", issue.Key, issue.Repaired, issue.RepairError)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// lowestTurnoutLessons is how many of the worst attended lessons are listed
+// per group and lesson type.
+const lowestTurnoutLessons = 3
+
+// DisciplineAttendanceReport is the attendance of one discipline across the
+// groups it is scheduled for. Attendance is a percentage of the enrolled
+// students and only counts lessons already held.
+type DisciplineAttendanceReport struct {
+	DisciplineID    int               `json:"discipline_id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	IsSpecial       bool              `json:"is_special"`
+	ReportingPeriod string            `json:"reporting_period"`
+	Groups          []DisciplineGroup `json:"groups"`
+}
+
+type DisciplineGroup struct {
+	Group             string                 `json:"group"`
+	Department        string                 `json:"department"`
+	EnrolledStudents  int                    `json:"enrolled_students"`
+	PlannedSessions   int                    `json:"planned_sessions"`
+	HeldSessions      int                    `json:"held_sessions"`
+	AverageAttendance float64                `json:"average_attendance"`
+	LessonTypes       []DisciplineLessonType `json:"lesson_types"`
+}
+
+type DisciplineLessonType struct {
+	Type              string          `json:"type"`
+	PlannedSessions   int             `json:"planned_sessions"`
+	HeldSessions      int             `json:"held_sessions"`
+	AverageAttendance float64         `json:"average_attendance"`
+	LowestTurnout     []LessonTurnout `json:"lowest_turnout"`
+}
+
+type LessonTurnout struct {
+	ScheduleID int64   `json:"schedule_id"`
+	LessonID   int64   `json:"lesson_id"`
+	Topic      string  `json:"topic"`
+	Date       string  `json:"date"`
+	Attended   int     `json:"attended"`
+	Enrolled   int     `json:"enrolled"`
+	Rate       float64 `json:"rate"`
+}
+
+type disciplineSession struct {
+	group      string
+	department string
+	lessonType string
+	held       bool
+	turnout    LessonTurnout
+}
+
+// GenerateDisciplineReport groups the sessions of the discipline between
+// startDate and endDate by group and lesson type. Only students in scope are
+// counted as enrolled. It fails with ErrDisciplineNotFound when the
+// discipline is neither in the catalog nor scheduled in the period.
+func (c *Client) GenerateDisciplineReport(ctx context.Context, scope Scope, disciplineID int, startDate, endDate string) (*DisciplineAttendanceReport, error) {
+	args := append([]interface{}{disciplineID, startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.QueryContext(ctx, fmt.Sprintf(getDisciplineSessionsQuery, scopeStudentFilter("s", 4)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discipline sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []disciplineSession
+	for rows.Next() {
+		var session disciplineSession
+		var lessonType int
+		turnout := &session.turnout
+		if err := rows.Scan(&turnout.ScheduleID, &session.group, &session.department, &turnout.LessonID, &turnout.Topic, &lessonType,
+			&turnout.Date, &session.held, &turnout.Enrolled, &turnout.Attended); err != nil {
+			return nil, fmt.Errorf("failed to scan discipline session: %v", err)
+		}
+		session.lessonType = typeToStringLesson[lessonType]
+		turnout.Rate = percent(turnout.Attended, turnout.Enrolled)
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query discipline sessions: %v", err)
+	}
+
+	records, err := c.DisciplineRecords(ctx, []int{disciplineID})
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[disciplineID]
+	if !ok && len(sessions) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrDisciplineNotFound, disciplineID)
+	}
+
+	return &DisciplineAttendanceReport{
+		DisciplineID:    disciplineID,
+		Name:            record.Name,
+		Description:     record.Description,
+		IsSpecial:       record.IsSpecial,
+		ReportingPeriod: fmt.Sprintf("%s to %s", startDate, endDate),
+		Groups:          groupDisciplineSessions(sessions),
+	}, nil
+}
+
+// groupDisciplineSessions expects the sessions ordered by group.
+func groupDisciplineSessions(sessions []disciplineSession) []DisciplineGroup {
+	groups := []DisciplineGroup{}
+	for start := 0; start < len(sessions); {
+		end := start
+		for end < len(sessions) && sessions[end].group == sessions[start].group {
+			end++
+		}
+		groups = append(groups, summarizeGroupSessions(sessions[start:end]))
+		start = end
+	}
+	return groups
+}
+
+func summarizeGroupSessions(sessions []disciplineSession) DisciplineGroup {
+	group := DisciplineGroup{Group: sessions[0].group, Department: sessions[0].department, LessonTypes: []DisciplineLessonType{}}
+
+	byType := make(map[string][]disciplineSession)
+	var types []string
+	var attended, enrolled int
+	for _, session := range sessions {
+		if _, ok := byType[session.lessonType]; !ok {
+			types = append(types, session.lessonType)
+		}
+		byType[session.lessonType] = append(byType[session.lessonType], session)
+
+		group.PlannedSessions++
+		// Enrollment is the same for every session of the group.
+		group.EnrolledStudents = max(group.EnrolledStudents, session.turnout.Enrolled)
+		if session.held {
+			group.HeldSessions++
+			attended += session.turnout.Attended
+			enrolled += session.turnout.Enrolled
+		}
+	}
+	group.AverageAttendance = percent(attended, enrolled)
+
+	sort.Strings(types)
+	for _, lessonType := range types {
+		stats := DisciplineLessonType{Type: lessonType, LowestTurnout: []LessonTurnout{}}
+		var held []LessonTurnout
+		attended, enrolled := 0, 0
+		for _, session := range byType[lessonType] {
+			stats.PlannedSessions++
+			if session.held {
+				held = append(held, session.turnout)
+				attended += session.turnout.Attended
+				enrolled += session.turnout.Enrolled
+			}
+		}
+		stats.HeldSessions = len(held)
+		stats.AverageAttendance = percent(attended, enrolled)
+
+		sort.SliceStable(held, func(i, j int) bool { return held[i].Rate < held[j].Rate })
+		if len(held) > lowestTurnoutLessons {
+			held = held[:lowestTurnoutLessons]
+		}
+		stats.LowestTurnout = append(stats.LowestTurnout, held...)
+		group.LessonTypes = append(group.LessonTypes, stats)
+	}
+	return group
+}
@@ -0,0 +1,156 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+)
+
+// StudentDossier is everything known about one student for a period. Rates
+// are percentages of the planned hours, with a lesson without a mark counted
+// as missed.
+type StudentDossier struct {
+	StudentID      string              `json:"student_id"`
+	Profile        *StudentProfile     `json:"profile"`
+	Group          DossierGroup        `json:"group"`
+	StartDate      string              `json:"start_date"`
+	EndDate        string              `json:"end_date"`
+	PlannedHours   int                 `json:"planned_hours"`
+	AttendedHours  int                 `json:"attended_hours"`
+	AttendanceRate float64             `json:"attendance_rate"`
+	Disciplines    []DossierDiscipline `json:"disciplines"`
+	Lessons        []DossierLesson     `json:"lessons"`
+}
+
+type DossierGroup struct {
+	GroupID      int    `json:"group_id"`
+	Name         string `json:"name"`
+	Department   string `json:"department"`
+	CuratorEmail string `json:"curator_email"`
+}
+
+type DossierDiscipline struct {
+	DisciplineID   int     `json:"discipline_id"`
+	Name           string  `json:"name"`
+	IsSpecial      bool    `json:"is_special"`
+	PlannedHours   int     `json:"planned_hours"`
+	AttendedHours  int     `json:"attended_hours"`
+	AttendanceRate float64 `json:"attendance_rate"`
+}
+
+type DossierLesson struct {
+	ScheduleID   int64  `json:"schedule_id"`
+	LessonID     int64  `json:"lesson_id"`
+	DisciplineID int    `json:"discipline_id"`
+	Discipline   string `json:"discipline"`
+	Topic        string `json:"topic"`
+	Type         string `json:"type"`
+	Date         string `json:"date"`
+	Attended     bool   `json:"attended"`
+}
+
+// lessonHours is how long a scheduled lesson is, as in the course report.
+const lessonHours = 2
+
+// GetStudentDossier combines the Redis profile, the group, the disciplines
+// scheduled for the group and the lessons held between startDate and endDate.
+// Profile is nil when Redis has none.
+func (c *Client) GetStudentDossier(ctx context.Context, scope Scope, cardID, startDate, endDate string) (*StudentDossier, error) {
+	if err := c.studentInScope(ctx, scope, cardID); err != nil {
+		return nil, err
+	}
+
+	students, err := c.StudentRecords(scope, nil, []string{cardID})
+	if err != nil {
+		return nil, err
+	}
+	if len(students) == 0 {
+		return nil, ErrStudentNotFound
+	}
+	groupID := students[0].GroupID
+
+	dossier := &StudentDossier{StudentID: cardID, StartDate: startDate, EndDate: endDate}
+
+	profiles, err := c.StudentProfiles(ctx, []string{cardID})
+	if err != nil {
+		return nil, err
+	}
+	if profile, ok := profiles[cardID]; ok {
+		dossier.Profile = &profile
+	}
+
+	groups, err := c.GroupRecords(scope, []int{groupID}, nil)
+	if err != nil {
+		return nil, err
+	}
+	dossier.Group.GroupID = groupID
+	if len(groups) > 0 {
+		dossier.Group.Name = groups[0].Name
+		dossier.Group.Department = groups[0].Department
+		dossier.Group.CuratorEmail = groups[0].CuratorEmail
+	}
+
+	rows, err := c.pgdbClient.QueryContext(ctx, getStudentLessonHistoryQuery, cardID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lesson history: %v", err)
+	}
+	defer rows.Close()
+
+	dossier.Lessons = []DossierLesson{}
+	for rows.Next() {
+		var lesson DossierLesson
+		var lessonType int
+		if err := rows.Scan(&lesson.ScheduleID, &lesson.LessonID, &lesson.DisciplineID, &lesson.Topic, &lessonType, &lesson.Date, &lesson.Attended); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson history: %v", err)
+		}
+		lesson.Type = typeToStringLesson[lessonType]
+		dossier.Lessons = append(dossier.Lessons, lesson)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query lesson history: %v", err)
+	}
+
+	groupDisciplines, err := c.GroupDisciplines(scope, []int{groupID})
+	if err != nil {
+		return nil, err
+	}
+	disciplineIDs := groupDisciplines[groupID]
+	records, err := c.DisciplineRecords(ctx, disciplineIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := make(map[int]*DossierDiscipline, len(disciplineIDs))
+	dossier.Disciplines = make([]DossierDiscipline, len(disciplineIDs))
+	for i, id := range disciplineIDs {
+		dossier.Disciplines[i] = DossierDiscipline{DisciplineID: id, Name: records[id].Name, IsSpecial: records[id].IsSpecial}
+		hours[id] = &dossier.Disciplines[i]
+	}
+	for i := range dossier.Lessons {
+		lesson := &dossier.Lessons[i]
+		lesson.Discipline = records[lesson.DisciplineID].Name
+
+		discipline, ok := hours[lesson.DisciplineID]
+		if !ok {
+			continue
+		}
+		discipline.PlannedHours += lessonHours
+		dossier.PlannedHours += lessonHours
+		if lesson.Attended {
+			discipline.AttendedHours += lessonHours
+			dossier.AttendedHours += lessonHours
+		}
+	}
+	for i := range dossier.Disciplines {
+		discipline := &dossier.Disciplines[i]
+		discipline.AttendanceRate = percent(discipline.AttendedHours, discipline.PlannedHours)
+	}
+	dossier.AttendanceRate = percent(dossier.AttendedHours, dossier.PlannedHours)
+	return dossier, nil
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,177 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldSet selects JSON fields of a report by dotted path, e.g.
+// students.disciplines.planned_hours. Paths step through arrays, so
+// "students.name" selects the name of every student. A selected field keeps
+// everything below it. The nil FieldSet selects every field.
+type FieldSet map[string]FieldSet
+
+// ParseFieldSet parses a comma separated list of paths. An empty list is the
+// nil FieldSet.
+func ParseFieldSet(list string) (FieldSet, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	fields := FieldSet{}
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return nil, fmt.Errorf("empty field in fields list")
+		}
+		node := fields
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			if name == "" {
+				return nil, fmt.Errorf("invalid field path %q", path)
+			}
+			child, seen := node[name]
+			if seen && child == nil {
+				// A shorter path already selects the whole subtree.
+				break
+			}
+			if i == len(names)-1 {
+				node[name] = nil
+				break
+			}
+			if !seen {
+				child = FieldSet{}
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	return fields, nil
+}
+
+// Has reports whether anything at or below the path is selected, i.e. whether
+// the data behind it has to be loaded.
+func (f FieldSet) Has(path ...string) bool {
+	node := f
+	for _, name := range path {
+		if node == nil {
+			return true
+		}
+		child, ok := node[name]
+		if !ok {
+			return false
+		}
+		node = child
+	}
+	return true
+}
+
+// HasAny is Has for several sibling fields below a common path prefix.
+func (f FieldSet) HasAny(prefix []string, names ...string) bool {
+	for _, name := range names {
+		if f.Has(append(append([]string{}, prefix...), name)...) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the paths against the JSON shape of t.
+func (f FieldSet) validate(t reflect.Type, prefix string) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		if t == reflect.TypeOf(json.RawMessage{}) {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if f == nil || t.Kind() == reflect.Interface || t.Kind() == reflect.Map {
+		return nil
+	}
+	if t.Kind() != reflect.Struct {
+		if prefix == "" {
+			return errors.New("the report has no fields to select")
+		}
+		return fmt.Errorf("field %q has no subfields", strings.TrimSuffix(prefix, "."))
+	}
+
+	fieldTypes := jsonFields(t)
+	for name, child := range f {
+		fieldType, ok := fieldTypes[name]
+		if !ok {
+			return fmt.Errorf("unknown field %q", prefix+name)
+		}
+		if err := child.validate(fieldType, prefix+name+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, embeddedType := range jsonFields(field.Type) {
+				fields[embedded] = embeddedType
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// Project returns v reduced to the selected fields, as a generic JSON value.
+// The nil FieldSet returns v unchanged.
+func (f FieldSet) Project(v interface{}) (interface{}, error) {
+	if f == nil {
+		return v, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	f.project(tree)
+	return tree, nil
+}
+
+func (f FieldSet) project(node interface{}) {
+	if f == nil {
+		return
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			child, ok := f[key]
+			if !ok {
+				delete(n, key)
+				continue
+			}
+			child.project(value)
+		}
+	case []interface{}:
+		for _, value := range n {
+			f.project(value)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package accounting
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldSet(t *testing.T) {
+	tests := []struct {
+		list string
+		want FieldSet
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"student_id", FieldSet{"student_id": nil}},
+		{" student_id , name ", FieldSet{"student_id": nil, "name": nil}},
+		{"students.name,students.disciplines.planned_hours", FieldSet{
+			"students": FieldSet{"name": nil, "disciplines": FieldSet{"planned_hours": nil}},
+		}},
+		// A shorter path selects the whole subtree, in either order.
+		{"students.name,students", FieldSet{"students": nil}},
+		{"students,students.name", FieldSet{"students": nil}},
+		{"name,name", FieldSet{"name": nil}},
+	}
+	for _, tt := range tests {
+		got, err := ParseFieldSet(tt.list)
+		if err != nil {
+			t.Errorf("ParseFieldSet(%q): %v", tt.list, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFieldSet(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+
+	for _, list := range []string{"name,", ",name", "students.", ".name", "students..name"} {
+		if _, err := ParseFieldSet(list); err == nil {
+			t.Errorf("ParseFieldSet(%q) succeeded, want an error", list)
+		}
+	}
+}
+
+func TestFieldSetHas(t *testing.T) {
+	fields, err := ParseFieldSet("group_name,students.disciplines.planned_hours")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path []string
+		want bool
+	}{
+		{[]string{"group_name"}, true},
+		{[]string{"students"}, true},
+		{[]string{"students", "disciplines"}, true},
+		{[]string{"students", "disciplines", "planned_hours"}, true},
+		{[]string{"students", "disciplines", "name"}, false},
+		{[]string{"students", "name"}, false},
+		{[]string{"group_name", "anything"}, true},
+	}
+	for _, tt := range tests {
+		if got := fields.Has(tt.path...); got != tt.want {
+			t.Errorf("Has(%v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if !fields.HasAny([]string{"students", "disciplines"}, "name", "planned_hours") {
+		t.Error("HasAny misses planned_hours")
+	}
+	if fields.HasAny([]string{"students"}, "name", "email") {
+		t.Error("HasAny selects unselected profile fields")
+	}
+	var all FieldSet
+	if !all.Has("anything", "at", "all") {
+		t.Error("the nil FieldSet must select everything")
+	}
+}
+
+func TestParseReportFields(t *testing.T) {
+	tests := []struct {
+		report string
+		list   string
+		ok     bool
+	}{
+		{ReportGroup, "", true},
+		{ReportGroup, "group_name,students.student_id", true},
+		{ReportGroup, "students.disciplines.planned_hours", true},
+		{ReportGroup, "students", true},
+		{ReportGroup, "students.unknown", false},
+		{ReportGroup, "group_name.length", false},
+		{ReportGroup, "students.disciplines.name.first", false},
+		{ReportAttendance, "student_id,name,attendance_rate", true},
+		{ReportAttendance, "students", false},
+		{ReportGroupList, "", true},
+		{ReportGroupList, "name", false},
+		{"unknown", "name", false},
+	}
+	for _, tt := range tests {
+		_, err := ParseReportFields(tt.report, tt.list)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseReportFields(%s, %q) error = %v, want ok %v", tt.report, tt.list, err, tt.ok)
+		}
+	}
+}
+
+func TestFieldSetProject(t *testing.T) {
+	report := GroupReport{
+		GroupName: "ИКБО-01-22",
+		Students: []StudentInfo{{
+			StudentID:   "1",
+			Name:        "Иванов",
+			Disciplines: []DisciplineReport{{Name: "БД", PlannedHours: 36, AttendedHours: 30}},
+		}},
+	}
+	fields, err := ParseReportFields(ReportGroup, "students.student_id,students.disciplines.planned_hours")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projected, err := fields.Project(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"students":[{"disciplines":[{"planned_hours":36}],"student_id":"1"}]}`
+	if string(got) != want {
+		t.Errorf("Project = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// DefaultCatchUpMaterials is how many materials are attached to each
+	// missed lesson unless asked otherwise.
+	DefaultCatchUpMaterials = 5
+	MaxCatchUpMaterials     = 50
+)
+
+type MissedLessons struct {
+	StudentID string         `json:"student_id"`
+	StartDate string         `json:"start_date"`
+	EndDate   string         `json:"end_date"`
+	Lessons   []MissedLesson `json:"lessons"`
+}
+
+type MissedLesson struct {
+	ScheduleID   int64             `json:"schedule_id"`
+	LessonID     int64             `json:"lesson_id"`
+	DisciplineID int               `json:"discipline_id"`
+	Discipline   string            `json:"discipline"`
+	Topic        string            `json:"topic"`
+	Type         string            `json:"type"`
+	Date         string            `json:"date"`
+	Materials    []CatchUpMaterial `json:"materials"`
+}
+
+// CatchUpMaterial is a material linked to a missed lesson. Score is the
+// relevance of the material to the lesson topic.
+type CatchUpMaterial struct {
+	MaterialID int     `json:"material_id"`
+	Title      string  `json:"title"`
+	Score      float64 `json:"score"`
+}
+
+// GetMissedLessons lists the lessons held between startDate and endDate that
+// the student did not attend, a lesson without a mark counting as missed.
+// Each comes with up to limit of the materials linked to it, best matches of
+// the lesson topic first.
+func (c *Client) GetMissedLessons(ctx context.Context, scope Scope, cardID, startDate, endDate string, limit int) (*MissedLessons, error) {
+	if err := c.studentInScope(ctx, scope, cardID); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pgdbClient.QueryContext(ctx, getMissedLessonsQuery, cardID, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missed lessons: %v", err)
+	}
+	defer rows.Close()
+
+	missed := &MissedLessons{StudentID: cardID, StartDate: startDate, EndDate: endDate, Lessons: []MissedLesson{}}
+	topics := make(map[int64]string)
+	var lessonIDs []int64
+	var disciplineIDs []int
+	for rows.Next() {
+		var lesson MissedLesson
+		var lessonType int
+		if err := rows.Scan(&lesson.ScheduleID, &lesson.LessonID, &lesson.DisciplineID, &lesson.Topic, &lessonType, &lesson.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan missed lesson: %v", err)
+		}
+		lesson.Type = typeToStringLesson[lessonType]
+		missed.Lessons = append(missed.Lessons, lesson)
+
+		if _, ok := topics[lesson.LessonID]; !ok {
+			topics[lesson.LessonID] = lesson.Topic
+			lessonIDs = append(lessonIDs, lesson.LessonID)
+			disciplineIDs = append(disciplineIDs, lesson.DisciplineID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query missed lessons: %v", err)
+	}
+	if len(lessonIDs) == 0 {
+		return missed, nil
+	}
+
+	linked, err := c.LessonMaterials(lessonIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lesson materials: %v", err)
+	}
+	materials, err := c.rankLessonMaterials(ctx, lessonIDs, topics, linked, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank lesson materials: %v", err)
+	}
+	disciplines, err := c.DisciplineRecords(ctx, disciplineIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range missed.Lessons {
+		lesson := &missed.Lessons[i]
+		lesson.Discipline = disciplines[lesson.DisciplineID].Name
+		lesson.Materials = materials[lesson.LessonID]
+		if lesson.Materials == nil {
+			lesson.Materials = []CatchUpMaterial{}
+		}
+	}
+	return missed, nil
+}
+
+// rankLessonMaterials searches the linked materials of every lesson for its
+// topic in one multi-search. Linked materials that do not match the topic
+// still come back, after those that do.
+func (c *Client) rankLessonMaterials(ctx context.Context, lessonIDs []int64, topics map[int64]string, linked map[int64][]int, limit int) (map[int64][]CatchUpMaterial, error) {
+	var body bytes.Buffer
+	var searched []int64
+	for _, lessonID := range lessonIDs {
+		materialIDs := linked[lessonID]
+		if len(materialIDs) == 0 || limit == 0 {
+			continue
+		}
+		// material_id is indexed as a string.
+		keys := make([]string, len(materialIDs))
+		for i, id := range materialIDs {
+			keys[i] = strconv.Itoa(id)
+		}
+		query := map[string]interface{}{
+			"size":    limit,
+			"_source": []string{"material_id", "title"},
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"filter": map[string]interface{}{
+						"terms": map[string]interface{}{"material_id": keys},
+					},
+					"should": []interface{}{
+						map[string]interface{}{
+							"multi_match": map[string]interface{}{
+								"query":  topics[lessonID],
+								"fields": []string{"title^3", "tags^2", "content"},
+							},
+						},
+					},
+				},
+			},
+		}
+		body.WriteString(`{"index": "materials"}` + "\n")
+		body.WriteString(mustJSON(query) + "\n")
+		searched = append(searched, lessonID)
+	}
+
+	ranked := make(map[int64][]CatchUpMaterial, len(searched))
+	if len(searched) == 0 {
+		return ranked, nil
+	}
+
+	res, err := c.esClient.Msearch(&body, c.esClient.Msearch.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("msearch materials: %s", res.Status())
+	}
+
+	var result struct {
+		Responses []struct {
+			Error json.RawMessage `json:"error"`
+			Hits  struct {
+				Hits []struct {
+					Score  float64 `json:"_score"`
+					Source struct {
+						MaterialID interface{} `json:"material_id"`
+						Title      string      `json:"title"`
+					} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(result.Responses) != len(searched) {
+		return nil, fmt.Errorf("msearch materials: %d responses to %d searches", len(result.Responses), len(searched))
+	}
+
+	for i, response := range result.Responses {
+		if response.Error != nil {
+			return nil, fmt.Errorf("msearch materials: %s", response.Error)
+		}
+		materials := make([]CatchUpMaterial, 0, len(response.Hits.Hits))
+		for _, hit := range response.Hits.Hits {
+			id, err := strconv.Atoi(fmt.Sprint(hit.Source.MaterialID))
+			if err != nil {
+				continue
+			}
+			materials = append(materials, CatchUpMaterial{MaterialID: id, Title: hit.Source.Title, Score: hit.Score})
+		}
+		ranked[searched[i]] = materials
+	}
+	return ranked, nil
+}
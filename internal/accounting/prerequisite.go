@@ -0,0 +1,478 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"sort"
+	"strings"
+)
+
+// Prerequisites are kept in Neo4j as (:Discipline {id})-[:REQUIRES]->(:Discipline {id}),
+// pointing from a discipline to the one that has to be taken before it.
+
+var (
+	ErrDisciplineNotFound   = errors.New("discipline not found")
+	ErrPrerequisiteNotFound = errors.New("prerequisite not found")
+	ErrPrerequisiteCycle    = errors.New("prerequisites form a cycle")
+)
+
+// DefaultPrerequisiteMinPercent is the attendance below which a prerequisite
+// counts as poorly attended.
+const DefaultPrerequisiteMinPercent = 60
+
+type Prerequisite struct {
+	DisciplineID int `json:"discipline_id"`
+	RequiresID   int `json:"requires_id"`
+}
+
+// PrerequisiteCycleError lists the cycles found in the graph. It matches
+// ErrPrerequisiteCycle.
+type PrerequisiteCycleError struct {
+	Cycles [][]int `json:"cycles"`
+}
+
+func (e *PrerequisiteCycleError) Error() string {
+	cycles := make([]string, len(e.Cycles))
+	for i, cycle := range e.Cycles {
+		cycles[i] = formatPath(append(append([]int(nil), cycle...), cycle[0]))
+	}
+	return fmt.Sprintf("%v: %s", ErrPrerequisiteCycle, strings.Join(cycles, "; "))
+}
+
+func (e *PrerequisiteCycleError) Is(target error) bool {
+	return target == ErrPrerequisiteCycle
+}
+
+type Curriculum struct {
+	Disciplines []CurriculumDiscipline `json:"disciplines"`
+}
+
+// CurriculumDiscipline is a discipline with its direct prerequisites. Level
+// 0 has none, level n requires something from level n-1.
+type CurriculumDiscipline struct {
+	DisciplineID int    `json:"discipline_id"`
+	Name         string `json:"name"`
+	Level        int    `json:"level"`
+	Requires     []int  `json:"requires"`
+}
+
+type PrerequisiteCheck struct {
+	StudentID   string                  `json:"student_id"`
+	MinPercent  float64                 `json:"min_percent"`
+	Disciplines []PrerequisiteShortfall `json:"disciplines"`
+}
+
+// PrerequisiteShortfall is a discipline of the student with the
+// prerequisites the student attended poorly.
+type PrerequisiteShortfall struct {
+	DisciplineID  int                      `json:"discipline_id"`
+	Name          string                   `json:"name"`
+	Prerequisites []PrerequisiteAttendance `json:"prerequisites"`
+}
+
+type PrerequisiteAttendance struct {
+	DisciplineID   int     `json:"discipline_id"`
+	Name           string  `json:"name"`
+	PlannedHours   int     `json:"planned_hours"`
+	AttendedHours  int     `json:"attended_hours"`
+	AttendanceRate float64 `json:"attendance_rate"`
+}
+
+func (c *Client) ListPrerequisites(ctx context.Context) ([]Prerequisite, error) {
+	prerequisites, err := c.prerequisites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prerequisites: %v", err)
+	}
+	return prerequisites, nil
+}
+
+// AddPrerequisite records that disciplineID requires requiresID. An edge that
+// would close a cycle is refused with a PrerequisiteCycleError.
+func (c *Client) AddPrerequisite(ctx context.Context, disciplineID, requiresID int) error {
+	if disciplineID == requiresID {
+		return &PrerequisiteCycleError{Cycles: [][]int{{disciplineID}}}
+	}
+	records, err := c.DisciplineRecords(ctx, []int{disciplineID, requiresID})
+	if err != nil {
+		return err
+	}
+	for _, id := range []int{disciplineID, requiresID} {
+		if _, ok := records[id]; !ok {
+			return fmt.Errorf("%w: %d", ErrDisciplineNotFound, id)
+		}
+	}
+
+	session := c.neoClient.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	params := map[string]interface{}{"discipline": int64(disciplineID), "requires": int64(requiresID)}
+	_, err = session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		// Neo4j reads at read committed, so two transactions adding the
+		// halves of a cycle would both miss the other's edge. Every addition
+		// first writes the same lock node, which serializes them until
+		// commit, and the check then sees all committed edges.
+		result, err := tx.Run(`
+			MERGE (l:PrerequisiteLock {id: 0})
+			SET l.locked_at = timestamp()`, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Consume(); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(`
+			MATCH p = shortestPath((r:Discipline {id: $requires})-[:REQUIRES*]->(d:Discipline {id: $discipline}))
+			RETURN [n IN nodes(p) | n.id]`, params)
+		if err != nil {
+			return nil, err
+		}
+		if result.Next() {
+			path := result.Record().Values[0].([]interface{})
+			cycle := make([]int, 0, len(path))
+			for _, id := range path {
+				cycle = append(cycle, int(id.(int64)))
+			}
+			// The path runs requiresID -> ... -> disciplineID; the new
+			// edge would close it.
+			return nil, &PrerequisiteCycleError{Cycles: [][]int{rotateCycle(cycle)}}
+		}
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(`
+			MERGE (d:Discipline {id: $discipline})
+			MERGE (r:Discipline {id: $requires})
+			MERGE (d)-[:REQUIRES]->(r)`, params)
+		if err != nil {
+			return nil, err
+		}
+		return result.Consume()
+	})
+	if errors.Is(err, ErrPrerequisiteCycle) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add prerequisite: %v", err)
+	}
+	return nil
+}
+
+// RemovePrerequisite deletes the edge and the discipline nodes left without
+// edges.
+func (c *Client) RemovePrerequisite(ctx context.Context, disciplineID, requiresID int) error {
+	session := c.neoClient.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	deleted, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+			MATCH (d:Discipline {id: $discipline})-[e:REQUIRES]->(r:Discipline {id: $requires})
+			DELETE e
+			WITH [d, r] AS nodes
+			UNWIND nodes AS n
+			WITH n WHERE NOT (n)--()
+			DELETE n
+			RETURN count(*)`,
+			map[string]interface{}{"discipline": int64(disciplineID), "requires": int64(requiresID)})
+		if err != nil {
+			return nil, err
+		}
+		summary, err := result.Consume()
+		if err != nil {
+			return nil, err
+		}
+		return summary.Counters().RelationshipsDeleted(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove prerequisite: %v", err)
+	}
+	if deleted.(int) == 0 {
+		return ErrPrerequisiteNotFound
+	}
+	return nil
+}
+
+// PrerequisiteCycles returns every cycle in the graph, each starting from its
+// smallest discipline ID. Cycles can only appear through writes that bypass
+// AddPrerequisite.
+func (c *Client) PrerequisiteCycles(ctx context.Context) ([][]int, error) {
+	prerequisites, err := c.prerequisites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prerequisites: %v", err)
+	}
+	return findCycles(prerequisiteGraph(prerequisites)), nil
+}
+
+// GetCurriculum orders the disciplines in use and those in the prerequisite
+// graph so that every discipline comes after its prerequisites. It fails
+// with a PrerequisiteCycleError when there is no such order.
+func (c *Client) GetCurriculum(ctx context.Context) (*Curriculum, error) {
+	prerequisites, err := c.prerequisites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prerequisites: %v", err)
+	}
+	graph := prerequisiteGraph(prerequisites)
+
+	rows, err := c.pgdbClient.QueryContext(ctx, getUsedDisciplinesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disciplines: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan discipline: %v", err)
+		}
+		if _, ok := graph[id]; !ok {
+			graph[id] = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query disciplines: %v", err)
+	}
+
+	levels, ok := topologicalLevels(graph)
+	if !ok {
+		return nil, &PrerequisiteCycleError{Cycles: findCycles(graph)}
+	}
+
+	ids := make([]int, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if levels[ids[i]] != levels[ids[j]] {
+			return levels[ids[i]] < levels[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	records, err := c.DisciplineRecords(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	curriculum := &Curriculum{Disciplines: make([]CurriculumDiscipline, 0, len(ids))}
+	for _, id := range ids {
+		requires := graph[id]
+		if requires == nil {
+			requires = []int{}
+		}
+		curriculum.Disciplines = append(curriculum.Disciplines, CurriculumDiscipline{
+			DisciplineID: id,
+			Name:         records[id].Name,
+			Level:        levels[id],
+			Requires:     requires,
+		})
+	}
+	return curriculum, nil
+}
+
+// CheckStudentPrerequisites lists the disciplines scheduled for the
+// student's group whose direct prerequisites the student attended below
+// minPercent, counting the lessons held so far. Prerequisites the student
+// never had scheduled are not counted as poorly attended.
+func (c *Client) CheckStudentPrerequisites(ctx context.Context, scope Scope, cardID string, minPercent float64) (*PrerequisiteCheck, error) {
+	if err := c.studentInScope(ctx, scope, cardID); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pgdbClient.QueryContext(ctx, getStudentDisciplineHoursQuery, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query student disciplines: %v", err)
+	}
+	defer rows.Close()
+	hours := make(map[int]PrerequisiteAttendance)
+	var taken []int
+	for rows.Next() {
+		var record PrerequisiteAttendance
+		if err := rows.Scan(&record.DisciplineID, &record.PlannedHours, &record.AttendedHours); err != nil {
+			return nil, fmt.Errorf("failed to scan student discipline: %v", err)
+		}
+		if record.PlannedHours > 0 {
+			record.AttendanceRate = float64(record.AttendedHours) / float64(record.PlannedHours) * 100
+		}
+		hours[record.DisciplineID] = record
+		taken = append(taken, record.DisciplineID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query student disciplines: %v", err)
+	}
+
+	prerequisites, err := c.prerequisites()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prerequisites: %v", err)
+	}
+	graph := prerequisiteGraph(prerequisites)
+
+	check := &PrerequisiteCheck{StudentID: cardID, MinPercent: minPercent, Disciplines: []PrerequisiteShortfall{}}
+	var named []int
+	for _, id := range taken {
+		var poor []PrerequisiteAttendance
+		for _, required := range graph[id] {
+			record, ok := hours[required]
+			if ok && record.PlannedHours > 0 && record.AttendanceRate < minPercent {
+				poor = append(poor, record)
+				named = append(named, required)
+			}
+		}
+		if len(poor) > 0 {
+			check.Disciplines = append(check.Disciplines, PrerequisiteShortfall{DisciplineID: id, Prerequisites: poor})
+			named = append(named, id)
+		}
+	}
+
+	records, err := c.DisciplineRecords(ctx, named)
+	if err != nil {
+		return nil, err
+	}
+	for i := range check.Disciplines {
+		shortfall := &check.Disciplines[i]
+		shortfall.Name = records[shortfall.DisciplineID].Name
+		for j := range shortfall.Prerequisites {
+			shortfall.Prerequisites[j].Name = records[shortfall.Prerequisites[j].DisciplineID].Name
+		}
+	}
+	return check, nil
+}
+
+func (c *Client) prerequisites() ([]Prerequisite, error) {
+	session := c.neoClient.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	result, err := session.Run(`
+		MATCH (d:Discipline)-[:REQUIRES]->(r:Discipline)
+		RETURN d.id, r.id
+		ORDER BY d.id, r.id`, nil)
+	if err != nil {
+		return nil, err
+	}
+	prerequisites := []Prerequisite{}
+	for result.Next() {
+		record := result.Record()
+		discipline, _ := record.Values[0].(int64)
+		requires, _ := record.Values[1].(int64)
+		prerequisites = append(prerequisites, Prerequisite{DisciplineID: int(discipline), RequiresID: int(requires)})
+	}
+	return prerequisites, result.Err()
+}
+
+// prerequisiteGraph maps each discipline in the edges to its sorted direct
+// prerequisites.
+func prerequisiteGraph(prerequisites []Prerequisite) map[int][]int {
+	graph := make(map[int][]int)
+	for _, p := range prerequisites {
+		graph[p.DisciplineID] = append(graph[p.DisciplineID], p.RequiresID)
+		if _, ok := graph[p.RequiresID]; !ok {
+			graph[p.RequiresID] = nil
+		}
+	}
+	for _, requires := range graph {
+		sort.Ints(requires)
+	}
+	return graph
+}
+
+// topologicalLevels assigns each discipline one level more than its deepest
+// prerequisite, peeling off disciplines whose prerequisites are all placed.
+// It reports false when a cycle leaves disciplines unplaced.
+func topologicalLevels(graph map[int][]int) (map[int]int, bool) {
+	remaining := make(map[int]int, len(graph))
+	dependents := make(map[int][]int)
+	var ready []int
+	for id, requires := range graph {
+		remaining[id] = len(requires)
+		for _, required := range requires {
+			dependents[required] = append(dependents[required], id)
+		}
+		if len(requires) == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	levels := make(map[int]int, len(graph))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		for _, dependent := range dependents[id] {
+			levels[dependent] = max(levels[dependent], levels[id]+1)
+			if remaining[dependent]--; remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	for _, count := range remaining {
+		if count > 0 {
+			return levels, false
+		}
+	}
+	return levels, true
+}
+
+// findCycles returns the cycles closed by the back edges of a depth-first
+// search in ID order. A graph with any cycle yields at least one.
+func findCycles(graph map[int][]int) [][]int {
+	ids := make([]int, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[int]int, len(graph))
+	var path []int
+	cycles := [][]int{}
+
+	var visit func(id int)
+	visit = func(id int) {
+		state[id] = onPath
+		path = append(path, id)
+		for _, required := range graph[id] {
+			switch state[required] {
+			case unvisited:
+				visit(required)
+			case onPath:
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == required {
+						cycles = append(cycles, rotateCycle(append([]int(nil), path[i:]...)))
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+	}
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+	return cycles
+}
+
+// rotateCycle starts the cycle at its smallest ID so the same cycle always
+// reads the same.
+func rotateCycle(cycle []int) []int {
+	start := 0
+	for i, id := range cycle {
+		if id < cycle[start] {
+			start = i
+		}
+	}
+	return append(cycle[start:len(cycle):len(cycle)], cycle[:start]...)
+}
+
+func formatPath(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, " -> ")
+}
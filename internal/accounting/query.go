@@ -10,16 +10,31 @@ const (
 		WHERE sch.lesson_id = ANY($1)
 		  AND sch.date BETWEEN $2 AND $3
 		  AND s.group_id = sch.group_id
+		  AND %s
 		GROUP BY s.card_id
 		ORDER BY attendance_rate ASC
 		LIMIT 10;
 	`
 
+	getStudentLessonsQuery = `
+		SELECT s.card_id, l.lesson_id, l.topic, sch.date::text, a.status
+		FROM attendance a
+		JOIN student s ON a.student_id = s.student_id
+		JOIN schedule sch ON a.schedule_id = sch.schedule_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		WHERE s.card_id = ANY($1)
+		  AND sch.lesson_id = ANY($2)
+		  AND sch.date BETWEEN $3 AND $4
+		  AND s.group_id = sch.group_id
+		ORDER BY sch.date, l.lesson_id;
+	`
+
 	getDisciplinesForDateQuery = `
 		SELECT DISTINCT l.discipline_id
 		FROM lesson l
 		JOIN schedule sch ON l.lesson_id = sch.lesson_id
-		WHERE sch.date BETWEEN $1 AND $2;
+		WHERE sch.date BETWEEN $1 AND $2
+		  AND %s;
 	`
 
 	getLecturesWithDetailsQuery = `
@@ -32,6 +47,7 @@ const (
 		LEFT JOIN equipment_requirements er ON l.lesson_id = er.lesson_id
 		LEFT JOIN equipment e ON er.equipment = e.id
 		WHERE l.discipline_id = $1 AND sch.date BETWEEN $2 AND $3
+		  AND %s
 		GROUP BY l.lesson_id, sch.date
 		ORDER BY sch.date;
 	`
@@ -48,7 +64,8 @@ const (
 		SELECT g.group_id, s.card_id
 		FROM "group" g
 		JOIN student s ON g.group_id = s.group_id
-		WHERE g.name = $1;
+		WHERE g.name = $1
+		  AND %s;
 	`
 
 	plannedQuery = `
@@ -69,5 +86,305 @@ const (
 		  );
 	`
 
-	getAllGroupsQuery = "SELECT name FROM \"group\""
+	getAllGroupsQuery = "SELECT g.name FROM \"group\" g WHERE %s"
+
+	groupInScopeQuery = `SELECT EXISTS (SELECT 1 FROM "group" g WHERE g.name = $1 AND %s)`
+
+	studentInScopeQuery = `
+		SELECT EXISTS (SELECT 1 FROM student WHERE card_id = $1),
+		       EXISTS (SELECT 1 FROM student s WHERE s.card_id = $1 AND %s);
+	`
+)
+
+const (
+	// getAttendanceTrendQuery is completed with one of attendanceTrendFilters
+	// and a scope filter.
+	getAttendanceTrendQuery = `
+		SELECT date_trunc($1, sch.date)::date::text AS bucket,
+		       COUNT(*) AS planned,
+		       COUNT(CASE WHEN a.status = true THEN 1 END) AS attended
+		FROM attendance a
+		JOIN student s ON a.student_id = s.student_id
+		JOIN schedule sch ON a.schedule_id = sch.schedule_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		JOIN "group" g ON sch.group_id = g.group_id
+		WHERE sch.date BETWEEN $2 AND $3
+		  AND s.group_id = sch.group_id
+		  AND %s
+		  AND %s
+		GROUP BY bucket
+		ORDER BY bucket;
+	`
+)
+
+var attendanceTrendFilters = map[string]string{
+	TrendScopeStudent:    "s.card_id = $4",
+	TrendScopeGroup:      "g.name = $4",
+	TrendScopeDiscipline: "l.discipline_id = $4::int",
+	TrendScopeDepartment: "g.department_id IN (SELECT department_id FROM department WHERE name = $4)",
+}
+
+const (
+	// getRiskAttendanceQuery returns every past scheduled lesson of every
+	// student in scope, with missing attendance rows counted as absences.
+	getRiskAttendanceQuery = `
+		SELECT s.card_id, g.name, sch.date::text,
+		       EXISTS (
+		           SELECT 1 FROM course c
+		           WHERE c.discipline_id = l.discipline_id AND c.is_special = true
+		       ) AS is_special,
+		       COALESCE(a.status, false) AS attended
+		FROM student s
+		JOIN "group" g ON s.group_id = g.group_id
+		JOIN schedule sch ON sch.group_id = s.group_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		LEFT JOIN attendance a ON a.schedule_id = sch.schedule_id AND a.student_id = s.student_id
+		WHERE sch.date BETWEEN $1 AND $2
+		  AND sch.date <= CURRENT_DATE
+		  AND %s
+		ORDER BY s.card_id, sch.date, sch.schedule_id;
+	`
+)
+
+const (
+	getGroupCuratorQuery = `SELECT COALESCE(curator_email, '') FROM "group" WHERE name = $1`
+)
+
+// Record queries back the batched lookups of the GraphQL API. Each is
+// completed with a scope filter.
+const (
+	getGroupRecordsQuery = `
+		SELECT g.group_id, g.name, COALESCE(d.name, ''), COALESCE(g.curator_email, '')
+		FROM "group" g
+		LEFT JOIN department d ON g.department_id = d.department_id
+		WHERE (cardinality($1::int[]) = 0 OR g.group_id = ANY($1::int[]))
+		  AND (cardinality($2::text[]) = 0 OR g.name = ANY($2::text[]))
+		  AND %s
+		ORDER BY g.name;
+	`
+
+	getStudentRecordsQuery = `
+		SELECT s.card_id, s.group_id
+		FROM student s
+		WHERE (cardinality($1::int[]) = 0 OR s.group_id = ANY($1::int[]))
+		  AND (cardinality($2::text[]) = 0 OR s.card_id = ANY($2::text[]))
+		  AND %s
+		ORDER BY s.card_id;
+	`
+
+	getGroupDisciplinesQuery = `
+		SELECT DISTINCT sch.group_id, l.discipline_id
+		FROM schedule sch
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		WHERE sch.group_id = ANY($1::int[])
+		  AND %s
+		ORDER BY sch.group_id, l.discipline_id;
+	`
+
+	getScheduleRecordsQuery = `
+		SELECT sch.schedule_id, sch.lesson_id, sch.group_id, sch.date::text
+		FROM schedule sch
+		WHERE (cardinality($1::bigint[]) = 0 OR sch.schedule_id = ANY($1::bigint[]))
+		  AND (cardinality($2::int[]) = 0 OR sch.group_id = ANY($2::int[]))
+		  AND ($3::date IS NULL OR sch.date >= $3::date)
+		  AND ($4::date IS NULL OR sch.date <= $4::date)
+		  AND %s
+		ORDER BY sch.date, sch.schedule_id;
+	`
+
+	getAttendanceRecordsQuery = `
+		SELECT s.card_id, a.schedule_id, a.status
+		FROM attendance a
+		JOIN student s ON a.student_id = s.student_id
+		JOIN schedule sch ON a.schedule_id = sch.schedule_id
+		WHERE (cardinality($1::bigint[]) = 0 OR a.schedule_id = ANY($1::bigint[]))
+		  AND (cardinality($2::text[]) = 0 OR s.card_id = ANY($2::text[]))
+		  AND ($3::date IS NULL OR sch.date >= $3::date)
+		  AND ($4::date IS NULL OR sch.date <= $4::date)
+		  AND %s
+		ORDER BY sch.date, a.schedule_id, s.card_id;
+	`
+)
+
+const (
+	getLessonRecordsQuery = `
+		SELECT l.lesson_id, l.discipline_id, l.topic, l.type,
+		       array_remove(array_agg(DISTINCT e.name), NULL) AS equipment
+		FROM lesson l
+		LEFT JOIN equipment_requirements er ON l.lesson_id = er.lesson_id
+		LEFT JOIN equipment e ON er.equipment = e.id
+		WHERE (cardinality($1::bigint[]) = 0 OR l.lesson_id = ANY($1::bigint[]))
+		  AND (cardinality($2::int[]) = 0 OR l.discipline_id = ANY($2::int[]))
+		GROUP BY l.lesson_id
+		ORDER BY l.lesson_id;
+	`
+
+	getSpecialFlagsQuery = `
+		SELECT discipline_id, bool_or(is_special)
+		FROM course
+		WHERE discipline_id = ANY($1::int[])
+		GROUP BY discipline_id;
+	`
+)
+
+// Consistency check queries.
+const (
+	getStudentProfileSourcesQuery = `
+		SELECT s.card_id, g.name, COALESCE(d.name, '')
+		FROM student s
+		JOIN "group" g ON s.group_id = g.group_id
+		LEFT JOIN department d ON g.department_id = d.department_id
+		ORDER BY s.card_id;
+	`
+
+	getExistingLessonsQuery = `SELECT lesson_id FROM lesson WHERE lesson_id = ANY($1::bigint[])`
+
+	getUsedDisciplinesQuery = `
+		SELECT discipline_id FROM lesson
+		UNION
+		SELECT discipline_id FROM course
+		ORDER BY discipline_id;
+	`
+)
+
+// Prerequisite check queries.
+const (
+	getStudentDisciplineHoursQuery = `
+		SELECT l.discipline_id,
+		       COUNT(CASE WHEN sch.date <= CURRENT_DATE THEN 1 END) * 2 AS planned_hours,
+		       COUNT(CASE WHEN sch.date <= CURRENT_DATE AND a.status = true THEN 1 END) * 2 AS attended_hours
+		FROM student s
+		JOIN schedule sch ON sch.group_id = s.group_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		LEFT JOIN attendance a ON a.schedule_id = sch.schedule_id AND a.student_id = s.student_id
+		WHERE s.card_id = $1
+		GROUP BY l.discipline_id
+		ORDER BY l.discipline_id;
+	`
+)
+
+const (
+	getStudentLessonHistoryQuery = `
+		SELECT sch.schedule_id, l.lesson_id, l.discipline_id, l.topic, l.type, sch.date::text,
+		       COALESCE(a.status, false) AS attended
+		FROM student s
+		JOIN schedule sch ON sch.group_id = s.group_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		LEFT JOIN attendance a ON a.schedule_id = sch.schedule_id AND a.student_id = s.student_id
+		WHERE s.card_id = $1
+		  AND sch.date BETWEEN $2 AND $3
+		  AND sch.date <= CURRENT_DATE
+		ORDER BY sch.date, sch.schedule_id;
+	`
+
+	getMissedLessonsQuery = `
+		SELECT sch.schedule_id, l.lesson_id, l.discipline_id, l.topic, l.type, sch.date::text
+		FROM student s
+		JOIN schedule sch ON sch.group_id = s.group_id
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		LEFT JOIN attendance a ON a.schedule_id = sch.schedule_id AND a.student_id = s.student_id
+		WHERE s.card_id = $1
+		  AND sch.date BETWEEN $2 AND $3
+		  AND sch.date <= CURRENT_DATE
+		  AND COALESCE(a.status, false) = false
+		ORDER BY sch.date, sch.schedule_id;
+	`
+)
+
+const (
+	// getDisciplineSessionsQuery is completed with a student scope filter.
+	getDisciplineSessionsQuery = `
+		SELECT sch.schedule_id, g.name, COALESCE(d.name, ''), l.lesson_id, l.topic, l.type, sch.date::text,
+		       sch.date <= CURRENT_DATE AS held,
+		       COUNT(s.student_id) AS enrolled,
+		       COUNT(CASE WHEN a.status = true THEN 1 END) AS attended
+		FROM schedule sch
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		JOIN "group" g ON sch.group_id = g.group_id
+		LEFT JOIN department d ON g.department_id = d.department_id
+		JOIN student s ON s.group_id = sch.group_id
+		LEFT JOIN attendance a ON a.schedule_id = sch.schedule_id AND a.student_id = s.student_id
+		WHERE l.discipline_id = $1
+		  AND sch.date BETWEEN $2 AND $3
+		  AND %s
+		GROUP BY sch.schedule_id, g.name, d.name, l.lesson_id
+		ORDER BY g.name, sch.date, sch.schedule_id;
+	`
+)
+
+// Teacher queries.
+const (
+	listTeachersQuery = `
+		SELECT t.teacher_id, t.name, COALESCE(t.email, ''), COALESCE(d.name, ''), t.weekly_load_hours
+		FROM teacher t
+		LEFT JOIN department d ON t.department_id = d.department_id
+		ORDER BY t.teacher_id;
+	`
+
+	getTeacherQuery = `
+		SELECT t.teacher_id, t.name, COALESCE(t.email, ''), COALESCE(d.name, ''), t.weekly_load_hours
+		FROM teacher t
+		LEFT JOIN department d ON t.department_id = d.department_id
+		WHERE t.teacher_id = $1;
+	`
+
+	getDepartmentIDQuery = `SELECT department_id FROM department WHERE name = $1`
+
+	createTeacherQuery = `
+		INSERT INTO teacher (name, email, department_id, weekly_load_hours)
+		VALUES ($1, NULLIF($2, ''), $3, $4)
+		RETURNING teacher_id;
+	`
+
+	updateTeacherQuery = `
+		UPDATE teacher
+		SET name = $2, email = NULLIF($3, ''), department_id = $4, weekly_load_hours = $5
+		WHERE teacher_id = $1;
+	`
+
+	deleteTeacherQuery = `DELETE FROM teacher WHERE teacher_id = $1`
+
+	setLessonTeacherQuery = `UPDATE lesson SET teacher_id = $2 WHERE lesson_id = $1`
+
+	setScheduleTeacherQuery = `UPDATE schedule SET teacher_id = $2 WHERE schedule_id = $1`
+
+	// getTeacherSessionsQuery is completed with a group scope filter. A
+	// session's own teacher takes precedence over the lesson's.
+	getTeacherSessionsQuery = `
+		SELECT g.name, l.discipline_id, l.type,
+		       sch.date <= CURRENT_DATE AS held,
+		       (SELECT COUNT(*) FROM student s WHERE s.group_id = sch.group_id) AS enrolled,
+		       (SELECT COUNT(*) FROM attendance a WHERE a.schedule_id = sch.schedule_id AND a.status = true) AS attended
+		FROM schedule sch
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		JOIN "group" g ON sch.group_id = g.group_id
+		WHERE COALESCE(sch.teacher_id, l.teacher_id) = $1
+		  AND sch.date BETWEEN $2 AND $3
+		  AND %s
+		ORDER BY sch.date, sch.schedule_id;
+	`
+)
+
+// Check-in queries.
+const (
+	// getScheduledLessonQuery is completed with a group and a session scope
+	// filter.
+	getScheduledLessonQuery = `
+		SELECT sch.schedule_id, l.lesson_id, l.discipline_id, g.name, l.topic, l.type, sch.date::text,
+		       sch.date = CURRENT_DATE AS today,
+		       (%s) AND %s AS in_scope
+		FROM schedule sch
+		JOIN lesson l ON sch.lesson_id = l.lesson_id
+		JOIN "group" g ON sch.group_id = g.group_id
+		WHERE sch.schedule_id = $1;
+	`
+
+	markAttendedQuery = `
+		INSERT INTO attendance (student_id, schedule_id, status)
+		SELECT s.student_id, sch.schedule_id, true
+		FROM schedule sch
+		JOIN student s ON s.group_id = sch.group_id
+		WHERE sch.schedule_id = $1 AND s.card_id = $2
+		ON CONFLICT (student_id, schedule_id) DO UPDATE SET status = true;
+	`
 )
@@ -0,0 +1,434 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"strconv"
+	"strings"
+)
+
+// Records are the plain entities of the domain as each store keeps them.
+// The lookups below take a batch of keys so callers can collect the keys of
+// many parents and fetch them in one round trip per store.
+
+type GroupRecord struct {
+	ID           int
+	Name         string
+	Department   string
+	CuratorEmail string
+}
+
+type StudentRecord struct {
+	CardID  string
+	GroupID int
+}
+
+// StudentProfile is the student document kept in Redis under student:<card_id>.
+type StudentProfile struct {
+	Name       string `json:"name"`
+	Group      string `json:"group"`
+	Course     int    `json:"course"`
+	Department string `json:"department-name"`
+	Email      string `json:"email"`
+	Birth      string `json:"birth"`
+}
+
+type DisciplineRecord struct {
+	ID          int
+	Name        string
+	Description string
+	IsSpecial   bool
+}
+
+type LessonRecord struct {
+	ID           int64
+	DisciplineID int
+	Topic        string
+	Type         string
+	Equipment    []string
+}
+
+type MaterialRecord struct {
+	ID      int
+	Title   string
+	Content string
+	Tags    []string
+}
+
+type ScheduleRecord struct {
+	ID       int64
+	LessonID int64
+	GroupID  int
+	Date     string
+}
+
+type AttendanceRecord struct {
+	CardID     string
+	ScheduleID int64
+	Attended   bool
+}
+
+// GroupRecords returns the groups in scope with the given IDs or names. With
+// neither it returns every group in scope.
+func (c *Client) GroupRecords(scope Scope, ids []int, names []string) ([]GroupRecord, error) {
+	args := append([]interface{}{pq.Array(nonNilInts(ids)), pq.Array(nonNilStrings(names))}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getGroupRecordsQuery, scopeGroupFilter("g.group_id", 3)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []GroupRecord
+	for rows.Next() {
+		var group GroupRecord
+		if err := rows.Scan(&group.ID, &group.Name, &group.Department, &group.CuratorEmail); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %v", err)
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// StudentRecords returns the students in scope of the given groups or with
+// the given card IDs.
+func (c *Client) StudentRecords(scope Scope, groupIDs []int, cardIDs []string) ([]StudentRecord, error) {
+	args := append([]interface{}{pq.Array(nonNilInts(groupIDs)), pq.Array(nonNilStrings(cardIDs))}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getStudentRecordsQuery, scopeStudentFilter("s", 3)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query students: %v", err)
+	}
+	defer rows.Close()
+
+	var students []StudentRecord
+	for rows.Next() {
+		var student StudentRecord
+		if err := rows.Scan(&student.CardID, &student.GroupID); err != nil {
+			return nil, fmt.Errorf("failed to scan student: %v", err)
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+// StudentProfiles reads the Redis profiles of the students. Students without
+// a profile are missing from the result.
+func (c *Client) StudentProfiles(ctx context.Context, cardIDs []string) (map[string]StudentProfile, error) {
+	profiles := make(map[string]StudentProfile, len(cardIDs))
+	if len(cardIDs) == 0 {
+		return profiles, nil
+	}
+
+	keys := make([]string, len(cardIDs))
+	for i, cardID := range cardIDs {
+		keys[i] = fmt.Sprintf("student:%s", cardID)
+	}
+	values, err := c.redisClient.MGet(ctx, keys...).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get student profiles: %v", err)
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var profile StudentProfile
+		if err := json.Unmarshal([]byte(data), &profile); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal student %s: %v", cardIDs[i], err)
+		}
+		profiles[cardIDs[i]] = profile
+	}
+	return profiles, nil
+}
+
+// GroupDisciplines returns the disciplines scheduled for each group.
+func (c *Client) GroupDisciplines(scope Scope, groupIDs []int) (map[int][]int, error) {
+	args := append([]interface{}{pq.Array(nonNilInts(groupIDs))}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getGroupDisciplinesQuery, scopeGroupFilter("sch.group_id", 2)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group disciplines: %v", err)
+	}
+	defer rows.Close()
+
+	disciplines := make(map[int][]int)
+	for rows.Next() {
+		var groupID, disciplineID int
+		if err := rows.Scan(&groupID, &disciplineID); err != nil {
+			return nil, fmt.Errorf("failed to scan group discipline: %v", err)
+		}
+		disciplines[groupID] = append(disciplines[groupID], disciplineID)
+	}
+	return disciplines, rows.Err()
+}
+
+// DisciplineRecords combines the Elasticsearch catalog entry of each
+// discipline with its special flag from Postgres. Disciplines missing from
+// the catalog are missing from the result.
+func (c *Client) DisciplineRecords(ctx context.Context, ids []int) (map[int]DisciplineRecord, error) {
+	records := make(map[int]DisciplineRecord, len(ids))
+	if len(ids) == 0 {
+		return records, nil
+	}
+
+	query := map[string]interface{}{
+		"size": len(ids),
+		"query": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"discipline_id": ids,
+			},
+		},
+	}
+	hits, err := c.searchSources(ctx, "disciplines", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search disciplines: %v", err)
+	}
+	for _, source := range hits {
+		id, err := strconv.Atoi(fmt.Sprint(source["discipline_id"]))
+		if err != nil {
+			continue
+		}
+		record := DisciplineRecord{ID: id}
+		record.Name, _ = source["name"].(string)
+		record.Description, _ = source["description"].(string)
+		records[id] = record
+	}
+
+	rows, err := c.pgdbClient.QueryContext(ctx, getSpecialFlagsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query special disciplines: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var special bool
+		if err := rows.Scan(&id, &special); err != nil {
+			return nil, fmt.Errorf("failed to scan special flag: %v", err)
+		}
+		if record, ok := records[id]; ok {
+			record.IsSpecial = special
+			records[id] = record
+		}
+	}
+	return records, rows.Err()
+}
+
+// LessonRecords returns the lessons with the given IDs or of the given
+// disciplines.
+func (c *Client) LessonRecords(ctx context.Context, ids []int64, disciplineIDs []int) ([]LessonRecord, error) {
+	rows, err := c.pgdbClient.QueryContext(ctx, getLessonRecordsQuery, pq.Array(nonNilInt64s(ids)), pq.Array(nonNilInts(disciplineIDs)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lessons: %v", err)
+	}
+	defer rows.Close()
+
+	var lessons []LessonRecord
+	for rows.Next() {
+		var lesson LessonRecord
+		var lessonType int
+		if err := rows.Scan(&lesson.ID, &lesson.DisciplineID, &lesson.Topic, &lessonType, pq.Array(&lesson.Equipment)); err != nil {
+			return nil, fmt.Errorf("failed to scan lesson: %v", err)
+		}
+		lesson.Type = typeToStringLesson[lessonType]
+		lessons = append(lessons, lesson)
+	}
+	return lessons, rows.Err()
+}
+
+// MaterialRecords reads the materials from Elasticsearch. Materials missing
+// from the index are missing from the result.
+func (c *Client) MaterialRecords(ctx context.Context, ids []int) (map[int]MaterialRecord, error) {
+	records := make(map[int]MaterialRecord, len(ids))
+	if len(ids) == 0 {
+		return records, nil
+	}
+
+	// material_id is indexed as a string.
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.Itoa(id)
+	}
+	query := map[string]interface{}{
+		"size": len(ids),
+		"query": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"material_id": keys,
+			},
+		},
+	}
+	hits, err := c.searchSources(ctx, "materials", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search materials: %v", err)
+	}
+	for _, source := range hits {
+		id, err := strconv.Atoi(fmt.Sprint(source["material_id"]))
+		if err != nil {
+			continue
+		}
+		record := MaterialRecord{ID: id}
+		record.Title, _ = source["title"].(string)
+		record.Content, _ = source["content"].(string)
+		switch tags := source["tags"].(type) {
+		case string:
+			record.Tags = []string{tags}
+		case []interface{}:
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					record.Tags = append(record.Tags, s)
+				}
+			}
+		}
+		records[id] = record
+	}
+	return records, nil
+}
+
+// LessonMaterials returns the materials linked to each lesson through
+// MAT_LES.
+func (c *Client) LessonMaterials(lessonIDs []int64) (map[int64][]int, error) {
+	session := c.neoClient.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	query :=
+		`MATCH (m:Material)-[:MAT_LES]->(l:Lesson)
+	WHERE l.id IN $lessonIDs
+	RETURN l.id AS lessonID, m.id AS materialID
+	ORDER BY materialID`
+
+	result, err := session.Run(query, map[string]interface{}{"lessonIDs": lessonIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Neo4j: %v", err)
+	}
+
+	materials := make(map[int64][]int)
+	for result.Next() {
+		record := result.Record()
+		lessonID := record.GetByIndex(0).(int64)
+		materials[lessonID] = append(materials[lessonID], int(record.GetByIndex(1).(int64)))
+	}
+	return materials, result.Err()
+}
+
+// MaterialLessons returns the lessons each material is linked to through
+// MAT_LES.
+func (c *Client) MaterialLessons(materialIDs []int) (map[int][]int64, error) {
+	materialsByLesson, err := c.getLecturesByMaterials(materialIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := make(map[int][]int64)
+	for lessonID, materials := range materialsByLesson {
+		for _, materialID := range materials {
+			lessons[materialID] = append(lessons[materialID], lessonID)
+		}
+	}
+	return lessons, nil
+}
+
+// ScheduleRecords returns the scheduled lessons in scope with the given IDs or
+// of the given groups, optionally limited to a date range.
+func (c *Client) ScheduleRecords(scope Scope, ids []int64, groupIDs []int, startDate, endDate string) ([]ScheduleRecord, error) {
+	args := append([]interface{}{pq.Array(nonNilInt64s(ids)), pq.Array(nonNilInts(groupIDs)), nullIfEmpty(startDate), nullIfEmpty(endDate)}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getScheduleRecordsQuery, scopeGroupFilter("sch.group_id", 5)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule: %v", err)
+	}
+	defer rows.Close()
+
+	var schedule []ScheduleRecord
+	for rows.Next() {
+		var record ScheduleRecord
+		if err := rows.Scan(&record.ID, &record.LessonID, &record.GroupID, &record.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %v", err)
+		}
+		schedule = append(schedule, record)
+	}
+	return schedule, rows.Err()
+}
+
+// AttendanceRecords returns the attendance marks of students in scope for the
+// given scheduled lessons or students, optionally limited to a date range.
+func (c *Client) AttendanceRecords(scope Scope, scheduleIDs []int64, cardIDs []string, startDate, endDate string) ([]AttendanceRecord, error) {
+	args := append([]interface{}{pq.Array(nonNilInt64s(scheduleIDs)), pq.Array(nonNilStrings(cardIDs)), nullIfEmpty(startDate), nullIfEmpty(endDate)}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getAttendanceRecordsQuery, scopeStudentFilter("s", 5)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attendance: %v", err)
+	}
+	defer rows.Close()
+
+	var attendance []AttendanceRecord
+	for rows.Next() {
+		var record AttendanceRecord
+		if err := rows.Scan(&record.CardID, &record.ScheduleID, &record.Attended); err != nil {
+			return nil, fmt.Errorf("failed to scan attendance: %v", err)
+		}
+		attendance = append(attendance, record)
+	}
+	return attendance, rows.Err()
+}
+
+func (c *Client) searchSources(ctx context.Context, index string, query map[string]interface{}) ([]map[string]interface{}, error) {
+	res, err := c.esClient.Search(
+		c.esClient.Search.WithContext(ctx),
+		c.esClient.Search.WithIndex(index),
+		c.esClient.Search.WithBody(strings.NewReader(mustJSON(query))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("search %s: %s", index, res.Status())
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	sources := make([]map[string]interface{}, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		sources = append(sources, hit.Source)
+	}
+	return sources, nil
+}
+
+// An empty array means "no filter" in the record queries, pq sends a nil
+// slice as NULL instead.
+func nonNilInts(values []int) []int {
+	if values == nil {
+		return []int{}
+	}
+	return values
+}
+
+func nonNilInt64s(values []int64) []int64 {
+	if values == nil {
+		return []int64{}
+	}
+	return values
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,209 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report types accepted by RunReport. Parameters use the same names as the
+// query arguments of the matching HTTP endpoints.
+const (
+	ReportAttendance = "attendance"
+	ReportCourse     = "course"
+	ReportGroup      = "group"
+	ReportGroupList  = "group-list"
+	ReportTrend      = "trend"
+	ReportAtRisk     = "at-risk"
+	ReportDiscipline = "discipline"
+	ReportWorkload   = "workload"
+)
+
+var reportParams = map[string][]string{
+	ReportAttendance: {"term", "startDate", "endDate"},
+	ReportCourse:     {"year", "sem"},
+	ReportGroup:      {"group"},
+	ReportGroupList:  {},
+	ReportTrend:      {"scope", "id", "startDate", "endDate"},
+	ReportAtRisk:     {"startDate", "endDate"},
+	ReportDiscipline: {"discipline", "startDate", "endDate"},
+	ReportWorkload:   {"teacher", "startDate", "endDate"},
+}
+
+var dateParams = []string{"startDate", "endDate"}
+
+// reportShapes are the result types of the reports that support the fields
+// parameter.
+var reportShapes = map[string]reflect.Type{
+	ReportAttendance: reflect.TypeOf([]StudentReport{}),
+	ReportCourse:     reflect.TypeOf([]CourseReport{}),
+	ReportGroup:      reflect.TypeOf(GroupReport{}),
+	// The group list holds plain names, it has no fields to select.
+	ReportGroupList:  reflect.TypeOf([]string{}),
+	ReportTrend:      reflect.TypeOf(AttendanceTrend{}),
+	ReportAtRisk:     reflect.TypeOf([]FlaggedStudent{}),
+	ReportDiscipline: reflect.TypeOf(DisciplineAttendanceReport{}),
+	ReportWorkload:   reflect.TypeOf(WorkloadReport{}),
+}
+
+// ParseReportFields parses the fields parameter of a report and checks its
+// paths against the report's JSON shape.
+func ParseReportFields(reportType, list string) (FieldSet, error) {
+	fields, err := ParseFieldSet(list)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	shape, ok := reportShapes[reportType]
+	if !ok {
+		return nil, fmt.Errorf("report %s does not support fields", reportType)
+	}
+	if err := fields.validate(shape, ""); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ValidateReportParams checks that the report type is known and its required
+// parameters are present and well-formed.
+func ValidateReportParams(reportType string, params map[string]string) error {
+	required, ok := reportParams[reportType]
+	if !ok {
+		return fmt.Errorf("unknown report type %q", reportType)
+	}
+	for _, name := range required {
+		if params[name] == "" {
+			return fmt.Errorf("report %s requires parameter %q", reportType, name)
+		}
+	}
+	for _, name := range dateParams {
+		if value, ok := params[name]; ok {
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return fmt.Errorf("'%s' must be in the format YYYY-MM-DD", name)
+			}
+		}
+	}
+
+	if _, err := ParseReportFields(reportType, params["fields"]); err != nil {
+		return err
+	}
+
+	switch reportType {
+	case ReportAttendance:
+		_, err := termSearchFromParams(params)
+		return err
+	case ReportCourse:
+		if _, err := strconv.Atoi(params["year"]); err != nil {
+			return fmt.Errorf("'year' must be a number")
+		}
+		if _, err := strconv.Atoi(params["sem"]); err != nil {
+			return fmt.Errorf("'sem' must be a number")
+		}
+	case ReportTrend:
+		if _, ok := attendanceTrendFilters[params["scope"]]; !ok {
+			return fmt.Errorf("unknown trend scope %q", params["scope"])
+		}
+		if bucket, ok := params["bucket"]; ok {
+			if _, ok := trendBuckets[bucket]; !ok {
+				return fmt.Errorf("unknown trend bucket %q", bucket)
+			}
+		}
+		if movingAvg, ok := params["movingAvg"]; ok {
+			if n, err := strconv.Atoi(movingAvg); err != nil || n < 0 {
+				return fmt.Errorf("'movingAvg' must be a non-negative integer")
+			}
+		}
+	case ReportDiscipline:
+		if _, err := strconv.Atoi(params["discipline"]); err != nil {
+			return fmt.Errorf("'discipline' must be a number")
+		}
+	case ReportWorkload:
+		if _, err := strconv.Atoi(params["teacher"]); err != nil {
+			return fmt.Errorf("'teacher' must be a number")
+		}
+	}
+	return nil
+}
+
+// ReportProgress is called as a report advances, done out of total steps.
+type ReportProgress func(done, total int)
+
+func (p ReportProgress) report(done, total int) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+// RunReport generates an unrestricted report from its type and string
+// parameters. It backs the callers that store report definitions rather than
+// HTTP requests.
+func (c *Client) RunReport(reportType string, params map[string]string) (interface{}, error) {
+	return c.RunReportContext(context.Background(), Scope{}, reportType, params, nil)
+}
+
+// RunReportContext is RunReport limited to scope that stops when ctx is
+// cancelled and reports progress of the attendance, course and group reports.
+// A fields parameter projects the result to the selected fields.
+func (c *Client) RunReportContext(ctx context.Context, scope Scope, reportType string, params map[string]string, progress ReportProgress) (interface{}, error) {
+	if err := ValidateReportParams(reportType, params); err != nil {
+		return nil, err
+	}
+
+	fields, _ := ParseReportFields(reportType, params["fields"])
+	report, err := c.runReport(ctx, scope, fields, reportType, params, progress)
+	if err != nil {
+		return nil, err
+	}
+	return fields.Project(report)
+}
+
+func (c *Client) runReport(ctx context.Context, scope Scope, fields FieldSet, reportType string, params map[string]string, progress ReportProgress) (interface{}, error) {
+	switch reportType {
+	case ReportAttendance:
+		search, _ := termSearchFromParams(params)
+		return c.generateAttendanceReport(ctx, scope, fields, search, params["startDate"], params["endDate"], progress)
+	case ReportCourse:
+		year, _ := strconv.Atoi(params["year"])
+		semester, _ := strconv.Atoi(params["sem"])
+		return c.generateCourseReport(ctx, scope, fields, year, semester, progress)
+	case ReportGroup:
+		return c.generateGroupReport(ctx, scope, fields, params["group"], progress)
+	case ReportGroupList:
+		return c.GetAllGroups(scope)
+	case ReportTrend:
+		bucket := params["bucket"]
+		if bucket == "" {
+			bucket = TrendBucketWeek
+		}
+		movingAvg, _ := strconv.Atoi(params["movingAvg"])
+		return c.GenerateAttendanceTrend(scope, params["scope"], params["id"], bucket, params["startDate"], params["endDate"], movingAvg)
+	case ReportAtRisk:
+		return c.FindAtRiskStudents(scope, params["startDate"], params["endDate"])
+	case ReportDiscipline:
+		disciplineID, _ := strconv.Atoi(params["discipline"])
+		return c.GenerateDisciplineReport(ctx, scope, disciplineID, params["startDate"], params["endDate"])
+	case ReportWorkload:
+		teacherID, _ := strconv.Atoi(params["teacher"])
+		return c.GenerateWorkloadReport(ctx, scope, teacherID, params["startDate"], params["endDate"])
+	}
+	return nil, fmt.Errorf("unknown report type %q", reportType)
+}
+
+func termSearchFromParams(params map[string]string) (*TermSearch, error) {
+	var fields []string
+	if params["searchFields"] != "" {
+		fields = strings.Split(params["searchFields"], ",")
+	}
+
+	var minScore float64
+	if params["minScore"] != "" {
+		var err error
+		if minScore, err = strconv.ParseFloat(params["minScore"], 64); err != nil {
+			return nil, fmt.Errorf("'minScore' must be a number")
+		}
+	}
+
+	return NewTermSearch(params["term"], fields, minScore)
+}
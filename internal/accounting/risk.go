@@ -0,0 +1,253 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+const (
+	// RiskRuleSpecialHours flags students whose attended/planned hours for
+	// special disciplines are below MinPercent.
+	RiskRuleSpecialHours = "special_hours_ratio"
+	// RiskRuleConsecutiveAbsences flags students who missed at least Absences
+	// scheduled lessons in a row.
+	RiskRuleConsecutiveAbsences = "consecutive_absences"
+	// RiskRuleRateDrop flags students whose attendance rate over the last
+	// WindowDays dropped by at least DropPoints percentage points compared
+	// with the WindowDays before.
+	RiskRuleRateDrop = "rate_drop"
+)
+
+type RiskRule struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	MinPercent float64 `json:"min_percent,omitempty"`
+	Absences   int     `json:"absences,omitempty"`
+	DropPoints float64 `json:"drop_points,omitempty"`
+	WindowDays int     `json:"window_days,omitempty"`
+}
+
+func DefaultRiskRules() []RiskRule {
+	return []RiskRule{
+		{Name: "low special hours", Type: RiskRuleSpecialHours, MinPercent: 60},
+		{Name: "absence streak", Type: RiskRuleConsecutiveAbsences, Absences: 3},
+		{Name: "attendance drop", Type: RiskRuleRateDrop, DropPoints: 20, WindowDays: 14},
+	}
+}
+
+func ValidateRiskRules(rules []RiskRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("risk rule name must not be empty")
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("duplicate risk rule %q", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		switch rule.Type {
+		case RiskRuleSpecialHours:
+			if rule.MinPercent <= 0 || rule.MinPercent > 100 {
+				return fmt.Errorf("risk rule %q: min_percent must be in (0, 100]", rule.Name)
+			}
+		case RiskRuleConsecutiveAbsences:
+			if rule.Absences <= 0 {
+				return fmt.Errorf("risk rule %q: absences must be positive", rule.Name)
+			}
+		case RiskRuleRateDrop:
+			if rule.DropPoints <= 0 || rule.WindowDays <= 0 {
+				return fmt.Errorf("risk rule %q: drop_points and window_days must be positive", rule.Name)
+			}
+		default:
+			return fmt.Errorf("risk rule %q: unknown type %q", rule.Name, rule.Type)
+		}
+	}
+	return nil
+}
+
+// SetRiskRules replaces the rules used by FindAtRiskStudents.
+func (c *Client) SetRiskRules(rules []RiskRule) error {
+	if err := ValidateRiskRules(rules); err != nil {
+		return err
+	}
+	c.riskRules = rules
+	return nil
+}
+
+type FlaggedStudent struct {
+	StudentID string        `json:"student_id"`
+	Name      string        `json:"name"`
+	Group     string        `json:"group"`
+	Email     string        `json:"email"`
+	Triggers  []RuleTrigger `json:"triggers"`
+}
+
+type RuleTrigger struct {
+	Rule     string                 `json:"rule"`
+	Type     string                 `json:"type"`
+	Evidence map[string]interface{} `json:"evidence"`
+}
+
+type riskLesson struct {
+	date      string
+	isSpecial bool
+	attended  bool
+}
+
+// FindAtRiskStudents evaluates the configured rules for every student in scope
+// over the lessons scheduled between startDate and endDate.
+func (c *Client) FindAtRiskStudents(scope Scope, startDate, endDate string) ([]FlaggedStudent, error) {
+	ctx := context.Background()
+
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date: %v", err)
+	}
+	if today := time.Now().UTC().Truncate(24 * time.Hour); end.After(today) {
+		end = today
+	}
+
+	args := append([]interface{}{startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getRiskAttendanceQuery, scopeStudentFilter("s", 3)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attendance history: %v", err)
+	}
+	defer rows.Close()
+
+	lessonsByStudent := make(map[string][]riskLesson)
+	groupByStudent := make(map[string]string)
+	for rows.Next() {
+		var studentID, group string
+		var lesson riskLesson
+		if err := rows.Scan(&studentID, &group, &lesson.date, &lesson.isSpecial, &lesson.attended); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		groupByStudent[studentID] = group
+		lessonsByStudent[studentID] = append(lessonsByStudent[studentID], lesson)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read attendance history: %v", err)
+	}
+
+	flagged := make([]FlaggedStudent, 0)
+	for studentID, lessons := range lessonsByStudent {
+		var triggers []RuleTrigger
+		for _, rule := range c.riskRules {
+			if evidence, ok := evaluateRiskRule(rule, lessons, end); ok {
+				triggers = append(triggers, RuleTrigger{Rule: rule.Name, Type: rule.Type, Evidence: evidence})
+			}
+		}
+		if len(triggers) == 0 {
+			continue
+		}
+
+		student := FlaggedStudent{
+			StudentID: studentID,
+			Group:     groupByStudent[studentID],
+			Triggers:  triggers,
+		}
+		if details, err := c.getStudentDetails(ctx, studentID); err != nil {
+			logrus.Errorf("failed to get student details for ID %s: %v", studentID, err)
+		} else {
+			student.Name, _ = details["name"].(string)
+			student.Email, _ = details["email"].(string)
+		}
+		flagged = append(flagged, student)
+	}
+
+	sort.Slice(flagged, func(i, j int) bool {
+		if len(flagged[i].Triggers) != len(flagged[j].Triggers) {
+			return len(flagged[i].Triggers) > len(flagged[j].Triggers)
+		}
+		return flagged[i].StudentID < flagged[j].StudentID
+	})
+
+	return flagged, nil
+}
+
+func evaluateRiskRule(rule RiskRule, lessons []riskLesson, end time.Time) (map[string]interface{}, bool) {
+	switch rule.Type {
+	case RiskRuleSpecialHours:
+		var planned, attended int
+		for _, lesson := range lessons {
+			if !lesson.isSpecial {
+				continue
+			}
+			planned += 2
+			if lesson.attended {
+				attended += 2
+			}
+		}
+		if planned == 0 {
+			return nil, false
+		}
+		percent := float64(attended) / float64(planned) * 100
+		return map[string]interface{}{
+			"planned_hours":  planned,
+			"attended_hours": attended,
+			"percent":        percent,
+			"min_percent":    rule.MinPercent,
+		}, percent < rule.MinPercent
+
+	case RiskRuleConsecutiveAbsences:
+		var longest, current int
+		var longestFrom, longestTo, currentFrom string
+		for _, lesson := range lessons {
+			if lesson.attended {
+				current = 0
+				continue
+			}
+			if current == 0 {
+				currentFrom = lesson.date
+			}
+			current++
+			if current > longest {
+				longest, longestFrom, longestTo = current, currentFrom, lesson.date
+			}
+		}
+		return map[string]interface{}{
+			"absences":  longest,
+			"from":      longestFrom,
+			"to":        longestTo,
+			"threshold": rule.Absences,
+		}, longest >= rule.Absences
+
+	case RiskRuleRateDrop:
+		recentFrom := end.AddDate(0, 0, -rule.WindowDays+1).Format("2006-01-02")
+		previousFrom := end.AddDate(0, 0, -2*rule.WindowDays+1).Format("2006-01-02")
+
+		var recentTotal, recentAttended, previousTotal, previousAttended int
+		for _, lesson := range lessons {
+			switch {
+			case lesson.date >= recentFrom:
+				recentTotal++
+				if lesson.attended {
+					recentAttended++
+				}
+			case lesson.date >= previousFrom:
+				previousTotal++
+				if lesson.attended {
+					previousAttended++
+				}
+			}
+		}
+		if recentTotal == 0 || previousTotal == 0 {
+			return nil, false
+		}
+		recentRate := float64(recentAttended) / float64(recentTotal) * 100
+		previousRate := float64(previousAttended) / float64(previousTotal) * 100
+		drop := previousRate - recentRate
+		return map[string]interface{}{
+			"previous_rate": previousRate,
+			"recent_rate":   recentRate,
+			"drop_points":   drop,
+			"window_days":   rule.WindowDays,
+			"threshold":     rule.DropPoints,
+		}, drop >= rule.DropPoints
+	}
+	return nil, false
+}
@@ -0,0 +1,134 @@
+package accounting
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRiskRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []RiskRule
+		err   string
+	}{
+		{"defaults", DefaultRiskRules(), ""},
+		{"none", nil, ""},
+		{"min percent upper bound", []RiskRule{{Name: "r", Type: RiskRuleSpecialHours, MinPercent: 100}}, ""},
+		{"empty name", []RiskRule{{Type: RiskRuleSpecialHours, MinPercent: 50}}, "name must not be empty"},
+		{"duplicate", []RiskRule{
+			{Name: "r", Type: RiskRuleSpecialHours, MinPercent: 50},
+			{Name: "r", Type: RiskRuleConsecutiveAbsences, Absences: 2},
+		}, "duplicate risk rule"},
+		{"zero min percent", []RiskRule{{Name: "r", Type: RiskRuleSpecialHours}}, "min_percent"},
+		{"min percent over 100", []RiskRule{{Name: "r", Type: RiskRuleSpecialHours, MinPercent: 100.5}}, "min_percent"},
+		{"zero absences", []RiskRule{{Name: "r", Type: RiskRuleConsecutiveAbsences}}, "absences"},
+		{"negative absences", []RiskRule{{Name: "r", Type: RiskRuleConsecutiveAbsences, Absences: -1}}, "absences"},
+		{"zero drop", []RiskRule{{Name: "r", Type: RiskRuleRateDrop, WindowDays: 7}}, "drop_points"},
+		{"zero window", []RiskRule{{Name: "r", Type: RiskRuleRateDrop, DropPoints: 10}}, "window_days"},
+		{"unknown type", []RiskRule{{Name: "r", Type: "late_arrivals"}}, "unknown type"},
+	}
+	for _, tt := range tests {
+		err := ValidateRiskRules(tt.rules)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%s: expected error containing %q", tt.name, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%s: error %q does not contain %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// lessons builds a history from "date[s][+|-]" entries: s marks a special
+// discipline, + an attended lesson and - a missed one.
+func lessons(entries ...string) []riskLesson {
+	result := make([]riskLesson, 0, len(entries))
+	for _, entry := range entries {
+		lesson := riskLesson{date: entry[:10], attended: strings.HasSuffix(entry, "+")}
+		lesson.isSpecial = strings.Contains(entry[10:], "s")
+		result = append(result, lesson)
+	}
+	return result
+}
+
+func TestEvaluateRiskRule(t *testing.T) {
+	end := time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+	special := RiskRule{Name: "special", Type: RiskRuleSpecialHours, MinPercent: 60}
+	streak := RiskRule{Name: "streak", Type: RiskRuleConsecutiveAbsences, Absences: 3}
+	drop := RiskRule{Name: "drop", Type: RiskRuleRateDrop, DropPoints: 50, WindowDays: 7}
+
+	tests := []struct {
+		name     string
+		rule     RiskRule
+		lessons  []riskLesson
+		flagged  bool
+		evidence map[string]string
+	}{
+		{"special below threshold", special,
+			lessons("2024-03-01s+", "2024-03-02s+", "2024-03-03s-", "2024-03-04s-", "2024-03-05s-"),
+			true, map[string]string{"planned_hours": "10", "attended_hours": "4", "percent": "40"}},
+		{"special at threshold", special,
+			lessons("2024-03-01s+", "2024-03-02s+", "2024-03-03s+", "2024-03-04s-", "2024-03-05s-"),
+			false, map[string]string{"percent": "60"}},
+		{"special ignores regular lessons", special,
+			lessons("2024-03-01s+", "2024-03-02-", "2024-03-03-", "2024-03-04-"),
+			false, map[string]string{"planned_hours": "2", "attended_hours": "2", "percent": "100"}},
+		{"special without special lessons", special,
+			lessons("2024-03-01-", "2024-03-02-"),
+			false, nil},
+
+		{"streak at threshold", streak,
+			lessons("2024-03-01+", "2024-03-02-", "2024-03-04-", "2024-03-05-", "2024-03-06+"),
+			true, map[string]string{"absences": "3", "from": "2024-03-02", "to": "2024-03-05"}},
+		{"streak broken by attendance", streak,
+			lessons("2024-03-01-", "2024-03-02-", "2024-03-03+", "2024-03-04-", "2024-03-05-"),
+			false, map[string]string{"absences": "2", "from": "2024-03-01", "to": "2024-03-02"}},
+		{"streak keeps the longest run", streak,
+			lessons("2024-03-01-", "2024-03-02+", "2024-03-03-", "2024-03-04-", "2024-03-05-", "2024-03-06-"),
+			true, map[string]string{"absences": "4", "from": "2024-03-03", "to": "2024-03-06"}},
+		{"streak at the end of the period", streak,
+			lessons("2024-03-12+", "2024-03-13-", "2024-03-14-", "2024-03-14-"),
+			true, map[string]string{"absences": "3", "from": "2024-03-13", "to": "2024-03-14"}},
+		{"streak without absences", streak,
+			lessons("2024-03-01+", "2024-03-02+"),
+			false, map[string]string{"absences": "0"}},
+
+		{"drop at threshold", drop,
+			lessons("2024-03-01+", "2024-03-07+", "2024-03-08+", "2024-03-14-"),
+			true, map[string]string{"previous_rate": "100", "recent_rate": "50", "drop_points": "50"}},
+		{"drop below threshold", drop,
+			lessons("2024-03-02+", "2024-03-03+", "2024-03-04+", "2024-03-09+", "2024-03-10+", "2024-03-11-"),
+			false, map[string]string{"previous_rate": "100"}},
+		{"drop window edges", drop,
+			// 2024-02-29 is before the previous window, 2024-03-01 is its first
+			// day and 2024-03-08 is the first day of the recent window.
+			lessons("2024-02-29-", "2024-03-01+", "2024-03-07+", "2024-03-08-", "2024-03-13-"),
+			true, map[string]string{"previous_rate": "100", "recent_rate": "0", "drop_points": "100"}},
+		{"drop ignores lessons before the previous window", drop,
+			lessons("2024-02-29+", "2024-03-08-"),
+			false, nil},
+		{"drop without recent lessons", drop,
+			lessons("2024-03-01+", "2024-03-07+"),
+			false, nil},
+		{"improvement is not a drop", drop,
+			lessons("2024-03-01-", "2024-03-07-", "2024-03-08+", "2024-03-14+"),
+			false, map[string]string{"drop_points": "-100"}},
+	}
+	for _, tt := range tests {
+		evidence, flagged := evaluateRiskRule(tt.rule, tt.lessons, end)
+		if flagged != tt.flagged {
+			t.Errorf("%s: flagged = %v, want %v (evidence %v)", tt.name, flagged, tt.flagged, evidence)
+		}
+		if tt.evidence == nil && evidence != nil {
+			t.Errorf("%s: evidence = %v, want none", tt.name, evidence)
+		}
+		for key, want := range tt.evidence {
+			if got := fmt.Sprint(evidence[key]); got != want {
+				t.Errorf("%s: evidence[%s] = %s, want %s", tt.name, key, got, want)
+			}
+		}
+	}
+}
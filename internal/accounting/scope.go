@@ -0,0 +1,106 @@
+package accounting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrOutOfScope      = errors.New("requested data is outside of the caller's scope")
+	ErrStudentNotFound = errors.New("student not found")
+)
+
+// Scope limits the students a report may include. Every non-empty field
+// narrows it further; the zero value is unrestricted.
+type Scope struct {
+	// CardID keeps a single student.
+	CardID string `bson:"card_id,omitempty" json:"card_id,omitempty"`
+	// Groups keeps students of the named groups.
+	Groups []string `bson:"groups,omitempty" json:"groups,omitempty"`
+	// Department keeps students of groups in the named department.
+	Department string `bson:"department,omitempty" json:"department,omitempty"`
+	// Teacher keeps students of groups with a session taught by the
+	// teacher, and only the teacher's own sessions for check-in and
+	// workload.
+	Teacher int `bson:"teacher_id,omitempty" json:"teacher_id,omitempty"`
+}
+
+func (s Scope) Unrestricted() bool {
+	return s.CardID == "" && len(s.Groups) == 0 && s.Department == "" && s.Teacher == 0
+}
+
+// args are the query arguments consumed by scopeGroupFilter and
+// scopeStudentFilter, in order.
+func (s Scope) args() []interface{} {
+	groups := s.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	return []interface{}{s.CardID, pq.Array(groups), s.Department, s.Teacher}
+}
+
+// scopeGroupFilter is an SQL predicate keeping rows whose group column is
+// visible in the scope. Its four arguments start at $first.
+func scopeGroupFilter(groupColumn string, first int) string {
+	return fmt.Sprintf(`($%[2]d::text = '' OR %[1]s IN (SELECT group_id FROM student WHERE card_id = $%[2]d::text))
+		  AND (cardinality($%[3]d::text[]) = 0 OR %[1]s IN (SELECT group_id FROM "group" WHERE name = ANY($%[3]d::text[])))
+		  AND ($%[4]d::text = '' OR %[1]s IN (
+		      SELECT sg.group_id FROM "group" sg
+		      JOIN department sd ON sg.department_id = sd.department_id
+		      WHERE sd.name = $%[4]d::text
+		  ))
+		  AND ($%[5]d::int = 0 OR %[1]s IN (
+		      SELECT tsch.group_id FROM schedule tsch
+		      JOIN lesson tl ON tsch.lesson_id = tl.lesson_id
+		      WHERE COALESCE(tsch.teacher_id, tl.teacher_id) = $%[5]d::int
+		  ))`, groupColumn, first, first+1, first+2, first+3)
+}
+
+// scopeSessionFilter is an SQL predicate keeping the sessions a teacher scope
+// teaches, with the same arguments as scopeGroupFilter. A substitute on the
+// schedule takes precedence over the lesson's teacher.
+func scopeSessionFilter(scheduleAlias, lessonAlias string, first int) string {
+	return fmt.Sprintf("($%[3]d::int = 0 OR COALESCE(%[1]s.teacher_id, %[2]s.teacher_id) = $%[3]d::int)",
+		scheduleAlias, lessonAlias, first+3)
+}
+
+// scopeStudentFilter is scopeGroupFilter for a student table alias that also
+// drops other students of the group when the scope is a single card.
+func scopeStudentFilter(alias string, first int) string {
+	return fmt.Sprintf("($%[2]d::text = '' OR %[1]s.card_id = $%[2]d::text) AND %[3]s",
+		alias, first, scopeGroupFilter(alias+".group_id", first))
+}
+
+func (c *Client) groupInScope(scope Scope, groupName string) (bool, error) {
+	if scope.Unrestricted() {
+		return true, nil
+	}
+
+	var ok bool
+	args := append([]interface{}{groupName}, scope.args()...)
+	if err := c.pgdbClient.QueryRow(fmt.Sprintf(groupInScopeQuery, scopeGroupFilter("g.group_id", 2)), args...).Scan(&ok); err != nil {
+		return false, fmt.Errorf("failed to check group scope: %v", err)
+	}
+	return ok, nil
+}
+
+// studentInScope fails with ErrOutOfScope for a student the scope does not
+// cover. An unknown card is ErrStudentNotFound only for unrestricted callers,
+// others get ErrOutOfScope too, so they cannot probe which cards exist.
+func (c *Client) studentInScope(ctx context.Context, scope Scope, cardID string) error {
+	var exists, inScope bool
+	args := append([]interface{}{cardID}, scope.args()...)
+	err := c.pgdbClient.QueryRowContext(ctx, fmt.Sprintf(studentInScopeQuery, scopeStudentFilter("s", 2)), args...).Scan(&exists, &inScope)
+	if err != nil {
+		return fmt.Errorf("failed to look up student: %v", err)
+	}
+	if !exists && scope.Unrestricted() {
+		return ErrStudentNotFound
+	}
+	if !exists || !inScope {
+		return ErrOutOfScope
+	}
+	return nil
+}
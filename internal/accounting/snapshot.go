@@ -0,0 +1,151 @@
+package accounting
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	snapshotCollection   = "report_snapshots"
+	defaultMongoDatabase = "accounting"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot is a report as it was returned at GeneratedAt. The payload is kept
+// as the exact JSON that was served, ContentHash is its SHA-256.
+type Snapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReportType  string             `bson:"report_type" json:"report_type"`
+	Params      map[string]string  `bson:"params" json:"params"`
+	Source      string             `bson:"source" json:"source"`
+	Owner       SnapshotOwner      `bson:"owner" json:"owner"`
+	GeneratedAt time.Time          `bson:"generated_at" json:"generated_at"`
+	ContentHash string             `bson:"content_hash" json:"content_hash"`
+	PayloadJSON string             `bson:"payload,omitempty" json:"-"`
+	Payload     json.RawMessage    `bson:"-" json:"payload,omitempty"`
+}
+
+// SnapshotOwner is who archived a snapshot and the scope its report was
+// generated in. Scheduled snapshots have no subject and an unrestricted scope.
+type SnapshotOwner struct {
+	Subject string `bson:"subject,omitempty" json:"subject,omitempty"`
+	Role    string `bson:"role,omitempty" json:"role,omitempty"`
+	Scope   Scope  `bson:"scope" json:"scope"`
+}
+
+// SetMongoDatabase selects the database holding the report snapshots.
+func (c *Client) SetMongoDatabase(name string) {
+	c.mongoDatabase = name
+}
+
+func (c *Client) snapshots() *mongo.Collection {
+	return c.mongoClient.Database(c.mongoDatabase).Collection(snapshotCollection)
+}
+
+func (c *Client) EnsureSnapshotIndexes(ctx context.Context) error {
+	_, err := c.snapshots().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "report_type", Value: 1}, {Key: "generated_at", Value: -1}},
+	})
+	return err
+}
+
+// SaveSnapshot archives a generated report. Source tells where it came from,
+// e.g. "api" or "scheduler:<job>".
+func (c *Client) SaveSnapshot(reportType string, params map[string]string, source string, owner SnapshotOwner, report interface{}) (*Snapshot, error) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report: %v", err)
+	}
+	hash := sha256.Sum256(payload)
+
+	snapshot := &Snapshot{
+		ReportType:  reportType,
+		Params:      params,
+		Source:      source,
+		Owner:       owner,
+		GeneratedAt: time.Now().UTC(),
+		ContentHash: hex.EncodeToString(hash[:]),
+		PayloadJSON: string(payload),
+	}
+
+	res, err := c.snapshots().InsertOne(context.Background(), snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %v", err)
+	}
+	snapshot.ID = res.InsertedID.(primitive.ObjectID)
+	snapshot.PayloadJSON = ""
+
+	return snapshot, nil
+}
+
+// ListSnapshots returns the newest snapshots without payloads, optionally
+// only of one report type.
+func (c *Client) ListSnapshots(reportType string, limit int64) ([]Snapshot, error) {
+	ctx := context.Background()
+
+	filter := bson.M{}
+	if reportType != "" {
+		filter["report_type"] = reportType
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "generated_at", Value: -1}}).
+		SetProjection(bson.M{"payload": 0}).
+		SetLimit(limit)
+
+	cursor, err := c.snapshots().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := make([]Snapshot, 0)
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+func (c *Client) GetSnapshot(id string) (*Snapshot, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	var snapshot Snapshot
+	err = c.snapshots().FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&snapshot)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %v", err)
+	}
+	snapshot.Payload = json.RawMessage(snapshot.PayloadJSON)
+
+	return &snapshot, nil
+}
+
+func (c *Client) DeleteSnapshot(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSnapshotNotFound
+	}
+
+	res, err := c.snapshots().DeleteOne(context.Background(), bson.M{"_id": objectID})
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %v", err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package accounting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTeacherNotFound  = errors.New("teacher not found")
+	ErrLessonNotFound   = errors.New("lesson not found")
+	ErrScheduleNotFound = errors.New("scheduled lesson not found")
+	// ErrInvalidTeacher wraps the reasons a teacher cannot be saved.
+	ErrInvalidTeacher = errors.New("invalid teacher")
+)
+
+// Postgres error codes of the constraint violations mapped to errors above.
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+// Teacher is a member of the teaching staff. WeeklyLoadHours is the planned
+// teaching load the workload report compares the delivered hours with.
+type Teacher struct {
+	ID              int    `json:"teacher_id"`
+	Name            string `json:"name"`
+	Email           string `json:"email,omitempty"`
+	Department      string `json:"department,omitempty"`
+	WeeklyLoadHours int    `json:"weekly_load_hours"`
+}
+
+func (c *Client) ListTeachers(ctx context.Context) ([]Teacher, error) {
+	rows, err := c.pgdbClient.QueryContext(ctx, listTeachersQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teachers: %v", err)
+	}
+	defer rows.Close()
+
+	teachers := []Teacher{}
+	for rows.Next() {
+		var teacher Teacher
+		if err := rows.Scan(&teacher.ID, &teacher.Name, &teacher.Email, &teacher.Department, &teacher.WeeklyLoadHours); err != nil {
+			return nil, fmt.Errorf("failed to scan teacher: %v", err)
+		}
+		teachers = append(teachers, teacher)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query teachers: %v", err)
+	}
+	return teachers, nil
+}
+
+func (c *Client) GetTeacher(ctx context.Context, id int) (*Teacher, error) {
+	teacher := &Teacher{}
+	err := c.pgdbClient.QueryRowContext(ctx, getTeacherQuery, id).
+		Scan(&teacher.ID, &teacher.Name, &teacher.Email, &teacher.Department, &teacher.WeeklyLoadHours)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrTeacherNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get teacher: %v", err)
+	}
+	return teacher, nil
+}
+
+// CreateTeacher stores a new teacher and returns it with its id. The
+// department, when given, must exist.
+func (c *Client) CreateTeacher(ctx context.Context, teacher Teacher) (*Teacher, error) {
+	departmentID, err := c.validateTeacher(ctx, &teacher)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.pgdbClient.QueryRowContext(ctx, createTeacherQuery, teacher.Name, teacher.Email, departmentID, teacher.WeeklyLoadHours).
+		Scan(&teacher.ID)
+	if err != nil {
+		return nil, teacherWriteError(err)
+	}
+	return &teacher, nil
+}
+
+// UpdateTeacher replaces every field of the teacher with id.
+func (c *Client) UpdateTeacher(ctx context.Context, id int, teacher Teacher) (*Teacher, error) {
+	departmentID, err := c.validateTeacher(ctx, &teacher)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.pgdbClient.ExecContext(ctx, updateTeacherQuery, id, teacher.Name, teacher.Email, departmentID, teacher.WeeklyLoadHours)
+	if err != nil {
+		return nil, teacherWriteError(err)
+	}
+	if err := expectAffected(res, fmt.Errorf("%w: %d", ErrTeacherNotFound, id)); err != nil {
+		return nil, err
+	}
+	teacher.ID = id
+	return &teacher, nil
+}
+
+// DeleteTeacher removes the teacher. Lessons and sessions assigned to the
+// teacher are left without one.
+func (c *Client) DeleteTeacher(ctx context.Context, id int) error {
+	res, err := c.pgdbClient.ExecContext(ctx, deleteTeacherQuery, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete teacher: %v", err)
+	}
+	return expectAffected(res, fmt.Errorf("%w: %d", ErrTeacherNotFound, id))
+}
+
+// AssignLessonTeacher sets the usual teacher of a lesson, teacherID 0
+// clearing it.
+func (c *Client) AssignLessonTeacher(ctx context.Context, lessonID int64, teacherID int) error {
+	res, err := c.pgdbClient.ExecContext(ctx, setLessonTeacherQuery, lessonID, nullTeacher(teacherID))
+	if err != nil {
+		return assignTeacherError(err, teacherID)
+	}
+	return expectAffected(res, fmt.Errorf("%w: %d", ErrLessonNotFound, lessonID))
+}
+
+// AssignScheduleTeacher sets the teacher of one scheduled session in place
+// of the lesson's usual teacher, teacherID 0 clearing the override.
+func (c *Client) AssignScheduleTeacher(ctx context.Context, scheduleID int64, teacherID int) error {
+	res, err := c.pgdbClient.ExecContext(ctx, setScheduleTeacherQuery, scheduleID, nullTeacher(teacherID))
+	if err != nil {
+		return assignTeacherError(err, teacherID)
+	}
+	return expectAffected(res, fmt.Errorf("%w: %d", ErrScheduleNotFound, scheduleID))
+}
+
+// validateTeacher normalizes the teacher and resolves its department.
+func (c *Client) validateTeacher(ctx context.Context, teacher *Teacher) (sql.NullInt64, error) {
+	teacher.Name = strings.TrimSpace(teacher.Name)
+	teacher.Email = strings.TrimSpace(teacher.Email)
+	teacher.Department = strings.TrimSpace(teacher.Department)
+	if teacher.Name == "" {
+		return sql.NullInt64{}, fmt.Errorf("%w: name must not be empty", ErrInvalidTeacher)
+	}
+	if teacher.WeeklyLoadHours < 0 {
+		return sql.NullInt64{}, fmt.Errorf("%w: weekly_load_hours must not be negative", ErrInvalidTeacher)
+	}
+	if teacher.Department == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	var departmentID sql.NullInt64
+	err := c.pgdbClient.QueryRowContext(ctx, getDepartmentIDQuery, teacher.Department).Scan(&departmentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.NullInt64{}, fmt.Errorf("%w: unknown department %q", ErrInvalidTeacher, teacher.Department)
+	}
+	if err != nil {
+		return sql.NullInt64{}, fmt.Errorf("failed to look up department: %v", err)
+	}
+	return departmentID, nil
+}
+
+func teacherWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+		return fmt.Errorf("%w: email is already used by another teacher", ErrInvalidTeacher)
+	}
+	return fmt.Errorf("failed to save teacher: %v", err)
+}
+
+func assignTeacherError(err error, teacherID int) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+		return fmt.Errorf("%w: %d", ErrTeacherNotFound, teacherID)
+	}
+	return fmt.Errorf("failed to assign teacher: %v", err)
+}
+
+func nullTeacher(teacherID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(teacherID), Valid: teacherID != 0}
+}
+
+// expectAffected returns notFound when the statement changed no rows.
+func expectAffected(res sql.Result, notFound error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// WorkloadReport is the teaching done by one teacher in a period. Hours are
+// counted per scheduled session, a session being taught by its own teacher
+// or else by the lesson's. Delivered hours are those of sessions already
+// held, and attendance is a percentage of the enrolled students in them.
+type WorkloadReport struct {
+	Teacher         Teacher `json:"teacher"`
+	ReportingPeriod string  `json:"reporting_period"`
+	PlannedHours    int     `json:"planned_hours"`
+	WorkloadHours
+	LoadPercent float64              `json:"load_percent"`
+	LessonTypes []WorkloadLessonType `json:"lesson_types"`
+	Disciplines []WorkloadDiscipline `json:"disciplines"`
+	Groups      []WorkloadGroup      `json:"groups"`
+}
+
+type WorkloadHours struct {
+	ScheduledHours    int     `json:"scheduled_hours"`
+	DeliveredHours    int     `json:"delivered_hours"`
+	AverageAttendance float64 `json:"average_attendance"`
+
+	attended, enrolled int
+}
+
+type WorkloadLessonType struct {
+	Type string `json:"type"`
+	WorkloadHours
+}
+
+type WorkloadDiscipline struct {
+	DisciplineID int    `json:"discipline_id"`
+	Name         string `json:"name"`
+	WorkloadHours
+}
+
+type WorkloadGroup struct {
+	Group string `json:"group"`
+	WorkloadHours
+}
+
+func (w *WorkloadHours) add(held bool, attended, enrolled int) {
+	w.ScheduledHours += lessonHours
+	if held {
+		w.DeliveredHours += lessonHours
+		w.attended += attended
+		w.enrolled += enrolled
+	}
+	w.AverageAttendance = percent(w.attended, w.enrolled)
+}
+
+// GenerateWorkloadReport sums the sessions of the teacher between startDate
+// and endDate in the groups of the scope. The planned load is the teacher's
+// weekly load over the weeks of the period, and LoadPercent the delivered
+// share of it. A teacher scope only covers the teacher's own report.
+func (c *Client) GenerateWorkloadReport(ctx context.Context, scope Scope, teacherID int, startDate, endDate string) (*WorkloadReport, error) {
+	if scope.Teacher != 0 && scope.Teacher != teacherID {
+		return nil, ErrOutOfScope
+	}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("'startDate' must be in the format YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("'endDate' must be in the format YYYY-MM-DD")
+	}
+
+	teacher, err := c.GetTeacher(ctx, teacherID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WorkloadReport{
+		Teacher:         *teacher,
+		ReportingPeriod: fmt.Sprintf("%s to %s", startDate, endDate),
+		LessonTypes:     []WorkloadLessonType{},
+		Disciplines:     []WorkloadDiscipline{},
+		Groups:          []WorkloadGroup{},
+	}
+	if days := end.Sub(start).Hours()/24 + 1; days > 0 {
+		report.PlannedHours = int(math.Round(float64(teacher.WeeklyLoadHours) * days / 7))
+	}
+
+	args := append([]interface{}{teacherID, startDate, endDate}, scope.args()...)
+	rows, err := c.pgdbClient.QueryContext(ctx, fmt.Sprintf(getTeacherSessionsQuery, scopeGroupFilter("sch.group_id", 4)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teacher sessions: %v", err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]*WorkloadLessonType)
+	disciplines := make(map[int]*WorkloadDiscipline)
+	groups := make(map[string]*WorkloadGroup)
+	for rows.Next() {
+		var group string
+		var disciplineID, lessonType, enrolled, attended int
+		var held bool
+		if err := rows.Scan(&group, &disciplineID, &lessonType, &held, &enrolled, &attended); err != nil {
+			return nil, fmt.Errorf("failed to scan teacher session: %v", err)
+		}
+
+		typeName := typeToStringLesson[lessonType]
+		if types[typeName] == nil {
+			types[typeName] = &WorkloadLessonType{Type: typeName}
+		}
+		if disciplines[disciplineID] == nil {
+			disciplines[disciplineID] = &WorkloadDiscipline{DisciplineID: disciplineID}
+		}
+		if groups[group] == nil {
+			groups[group] = &WorkloadGroup{Group: group}
+		}
+		for _, hours := range []*WorkloadHours{&report.WorkloadHours, &types[typeName].WorkloadHours,
+			&disciplines[disciplineID].WorkloadHours, &groups[group].WorkloadHours} {
+			hours.add(held, attended, enrolled)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query teacher sessions: %v", err)
+	}
+	report.LoadPercent = percent(report.DeliveredHours, report.PlannedHours)
+
+	disciplineIDs := make([]int, 0, len(disciplines))
+	for id := range disciplines {
+		disciplineIDs = append(disciplineIDs, id)
+	}
+	sort.Ints(disciplineIDs)
+	records, err := c.DisciplineRecords(ctx, disciplineIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range disciplineIDs {
+		discipline := disciplines[id]
+		discipline.Name = records[id].Name
+		report.Disciplines = append(report.Disciplines, *discipline)
+	}
+
+	for _, lessonType := range types {
+		report.LessonTypes = append(report.LessonTypes, *lessonType)
+	}
+	sort.Slice(report.LessonTypes, func(i, j int) bool { return report.LessonTypes[i].Type < report.LessonTypes[j].Type })
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Group < report.Groups[j].Group })
+	return report, nil
+}
@@ -0,0 +1,433 @@
+package accounting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query language accepted by the attendance report term parameter:
+//
+//	нормальная форма          bare words form a single phrase
+//	"3НФ"                     quoted phrase
+//	нормализация OR 3НФ       OR between operands
+//	индекс AND транзакция     AND between operands (binds tighter than OR)
+//	-SQL, NOT SQL             exclude operand
+//	норм*                     prefix
+//	нормализацыя~, SQL~1      fuzzy, optional edit distance 0-2
+//	(a OR b) AND c            grouping
+
+var searchableFields = map[string]struct{}{
+	"title":   {},
+	"content": {},
+	"tags":    {},
+}
+
+const (
+	defaultSearchField    = "content"
+	highlightFragmentSize = 150
+)
+
+type TermSearch struct {
+	Query    string
+	Fields   []string
+	MinScore float64
+
+	root termNode
+}
+
+// NewTermSearch parses the term query and validates the field selector. An
+// empty fields list means the "content" field only.
+func NewTermSearch(query string, fields []string, minScore float64) (*TermSearch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("term must not be empty")
+	}
+	if minScore < 0 {
+		return nil, fmt.Errorf("min score must not be negative")
+	}
+
+	if len(fields) == 0 {
+		fields = []string{defaultSearchField}
+	}
+	for _, field := range fields {
+		if _, ok := searchableFields[field]; !ok {
+			return nil, fmt.Errorf("unknown search field %q, expected title, content or tags", field)
+		}
+	}
+
+	root, err := parseTermQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TermSearch{
+		Query:    query,
+		Fields:   fields,
+		MinScore: minScore,
+		root:     root,
+	}, nil
+}
+
+func (s *TermSearch) esQuery() map[string]interface{} {
+	highlightFields := make(map[string]interface{}, len(s.Fields))
+	for _, field := range s.Fields {
+		highlightFields[field] = map[string]interface{}{}
+	}
+
+	query := map[string]interface{}{
+		"query": s.root.esQuery(s.Fields),
+		"highlight": map[string]interface{}{
+			"fields":              highlightFields,
+			"fragment_size":       highlightFragmentSize,
+			"number_of_fragments": 1,
+		},
+	}
+	if s.MinScore > 0 {
+		query["min_score"] = s.MinScore
+	}
+	return query
+}
+
+type termNode interface {
+	esQuery(fields []string) map[string]interface{}
+}
+
+type termMatchKind int
+
+const (
+	matchPhrase termMatchKind = iota
+	matchPrefix
+	matchFuzzy
+)
+
+type termLeaf struct {
+	text      string
+	kind      termMatchKind
+	fuzziness string
+}
+
+func (n *termLeaf) esQuery(fields []string) map[string]interface{} {
+	multiMatch := map[string]interface{}{
+		"query":  n.text,
+		"fields": fields,
+	}
+	switch n.kind {
+	case matchPhrase:
+		multiMatch["type"] = "phrase"
+	case matchPrefix:
+		multiMatch["type"] = "phrase_prefix"
+	case matchFuzzy:
+		multiMatch["type"] = "best_fields"
+		multiMatch["operator"] = "and"
+		multiMatch["fuzziness"] = n.fuzziness
+	}
+	return map[string]interface{}{"multi_match": multiMatch}
+}
+
+type termBool struct {
+	and      bool
+	operands []termNode
+	negated  []termNode
+}
+
+func (n *termBool) esQuery(fields []string) map[string]interface{} {
+	var positive, negative []interface{}
+	for _, operand := range n.operands {
+		positive = append(positive, operand.esQuery(fields))
+	}
+	for _, operand := range n.negated {
+		negative = append(negative, operand.esQuery(fields))
+	}
+
+	boolQuery := map[string]interface{}{}
+	if n.and {
+		if len(positive) > 0 {
+			boolQuery["must"] = positive
+		} else {
+			boolQuery["must"] = []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}}
+		}
+		if len(negative) > 0 {
+			boolQuery["must_not"] = negative
+		}
+	} else {
+		boolQuery["should"] = positive
+		boolQuery["minimum_should_match"] = 1
+	}
+	return map[string]interface{}{"bool": boolQuery}
+}
+
+type termNot struct {
+	operand termNode
+}
+
+func (n *termNot) esQuery(fields []string) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":     []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}},
+			"must_not": []interface{}{n.operand.esQuery(fields)},
+		},
+	}
+}
+
+type termTokenKind int
+
+const (
+	tokenWord termTokenKind = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type termToken struct {
+	kind termTokenKind
+	text string
+}
+
+func tokenizeTermQuery(query string) ([]termToken, error) {
+	var tokens []termToken
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, termToken{kind: tokenOpen})
+			i++
+		case r == ')':
+			tokens = append(tokens, termToken{kind: tokenClose})
+			i++
+		case r == '-' && (i+1 < len(runes) && !unicode.IsSpace(runes[i+1])) && (i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '('):
+			tokens = append(tokens, termToken{kind: tokenNot})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in term")
+			}
+			phrase := strings.TrimSpace(string(runes[i+1 : end]))
+			if phrase == "" {
+				return nil, fmt.Errorf("empty quoted phrase in term")
+			}
+			tokens = append(tokens, termToken{kind: tokenPhrase, text: phrase})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			switch word {
+			case "AND":
+				tokens = append(tokens, termToken{kind: tokenAnd})
+			case "OR":
+				tokens = append(tokens, termToken{kind: tokenOr})
+			case "NOT":
+				tokens = append(tokens, termToken{kind: tokenNot})
+			default:
+				tokens = append(tokens, termToken{kind: tokenWord, text: word})
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type termParser struct {
+	tokens []termToken
+	pos    int
+}
+
+func parseTermQuery(query string) (termNode, error) {
+	tokens, err := tokenizeTermQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &termParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s in term", p.tokens[p.pos].describe())
+	}
+	return node, nil
+}
+
+func (p *termParser) peek() (termToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return termToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *termParser) parseOr() (termNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []termNode{first}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenOr {
+			break
+		}
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+	for _, operand := range operands {
+		if _, ok := operand.(*termNot); ok {
+			return nil, fmt.Errorf("excluded terms cannot be combined with OR")
+		}
+	}
+	return &termBool{operands: operands}, nil
+}
+
+func (p *termParser) parseAnd() (termNode, error) {
+	node := &termBool{and: true}
+	for {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if not, ok := operand.(*termNot); ok {
+			node.negated = append(node.negated, not.operand)
+		} else {
+			node.operands = append(node.operands, operand)
+		}
+
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokenOr || tok.kind == tokenClose {
+			break
+		}
+		// Operands written next to each other without an operator are
+		// combined with AND, e.g. `нормализация -SQL`.
+		if tok.kind == tokenAnd {
+			p.pos++
+		}
+	}
+
+	if len(node.negated) == 0 && len(node.operands) == 1 {
+		return node.operands[0], nil
+	}
+	if len(node.operands) == 0 && len(node.negated) == 1 {
+		return &termNot{operand: node.negated[0]}, nil
+	}
+	return node, nil
+}
+
+func (p *termParser) parseUnary() (termNode, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of term")
+	}
+
+	switch tok.kind {
+	case tokenNot:
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if not, ok := operand.(*termNot); ok {
+			return not.operand, nil
+		}
+		return &termNot{operand: operand}, nil
+	case tokenOpen:
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.kind != tokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis in term")
+		}
+		p.pos++
+		return node, nil
+	case tokenPhrase:
+		p.pos++
+		return &termLeaf{text: tok.text, kind: matchPhrase}, nil
+	case tokenWord:
+		return p.parseWords()
+	default:
+		return nil, fmt.Errorf("unexpected %s in term", tok.describe())
+	}
+}
+
+// parseWords joins consecutive bare words into one phrase, so a plain
+// multi-word term keeps its match_phrase meaning. A trailing * or ~ on the
+// last word turns the phrase into a prefix or fuzzy match.
+func (p *termParser) parseWords() (termNode, error) {
+	var words []string
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenWord {
+			break
+		}
+		words = append(words, tok.text)
+		p.pos++
+	}
+
+	leaf := &termLeaf{kind: matchPhrase}
+	last := words[len(words)-1]
+	switch {
+	case strings.HasSuffix(last, "*"):
+		last = strings.TrimSuffix(last, "*")
+		leaf.kind = matchPrefix
+	case strings.Contains(last, "~"):
+		idx := strings.LastIndex(last, "~")
+		distance := last[idx+1:]
+		last = last[:idx]
+		leaf.kind = matchFuzzy
+		leaf.fuzziness = "AUTO"
+		if distance != "" {
+			n, err := strconv.Atoi(distance)
+			if err != nil || n < 0 || n > 2 {
+				return nil, fmt.Errorf("fuzzy distance must be 0, 1 or 2, got %q", distance)
+			}
+			leaf.fuzziness = distance
+		}
+	}
+	if last == "" {
+		return nil, fmt.Errorf("empty word before operator suffix in term")
+	}
+	words[len(words)-1] = last
+	leaf.text = strings.Join(words, " ")
+
+	return leaf, nil
+}
+
+func (t termToken) describe() string {
+	switch t.kind {
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenOpen:
+		return "'('"
+	case tokenClose:
+		return "')'"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
@@ -0,0 +1,134 @@
+package accounting
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// showTerm renders a parsed term compactly for comparison.
+func showTerm(node termNode) string {
+	switch n := node.(type) {
+	case *termLeaf:
+		switch n.kind {
+		case matchPrefix:
+			return fmt.Sprintf("prefix(%s)", n.text)
+		case matchFuzzy:
+			return fmt.Sprintf("fuzzy(%s,%s)", n.text, n.fuzziness)
+		default:
+			return fmt.Sprintf("phrase(%s)", n.text)
+		}
+	case *termNot:
+		return "not(" + showTerm(n.operand) + ")"
+	case *termBool:
+		var parts []string
+		for _, operand := range n.operands {
+			parts = append(parts, showTerm(operand))
+		}
+		for _, operand := range n.negated {
+			parts = append(parts, "not("+showTerm(operand)+")")
+		}
+		op := "or"
+		if n.and {
+			op = "and"
+		}
+		return op + "(" + strings.Join(parts, " ") + ")"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParseTermQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"нормальная форма", "phrase(нормальная форма)"},
+		{`"3НФ"`, "phrase(3НФ)"},
+		{`"  третья нормальная  "`, "phrase(третья нормальная)"},
+		{"нормализация OR 3НФ", "or(phrase(нормализация) phrase(3НФ))"},
+		{"индекс AND транзакция", "and(phrase(индекс) phrase(транзакция))"},
+		{"a OR b AND c", "or(phrase(a) and(phrase(b) phrase(c)))"},
+		{"(a OR b) AND c", "and(or(phrase(a) phrase(b)) phrase(c))"},
+		{"нормализация -SQL", "and(phrase(нормализация) not(phrase(SQL)))"},
+		{"нормализация NOT SQL", "and(phrase(нормализация) not(phrase(SQL)))"},
+		{"-SQL", "not(phrase(SQL))"},
+		{"NOT NOT SQL", "phrase(SQL)"},
+		{"NOT (a OR b)", "not(or(phrase(a) phrase(b)))"},
+		{`"a" b`, "and(phrase(a) phrase(b))"},
+		{"pre-commit", "phrase(pre-commit)"},
+		{"норм*", "prefix(норм)"},
+		{"нормальная фор*", "prefix(нормальная фор)"},
+		{"нормализацыя~", "fuzzy(нормализацыя,AUTO)"},
+		{"SQL~1", "fuzzy(SQL,1)"},
+		{"SQL~0", "fuzzy(SQL,0)"},
+		{"and or not", "phrase(and or not)"},
+	}
+	for _, tt := range tests {
+		node, err := parseTermQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseTermQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := showTerm(node); got != tt.want {
+			t.Errorf("parseTermQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseTermQueryErrors(t *testing.T) {
+	tests := []string{
+		`"unterminated`,
+		`""`,
+		"(a OR b",
+		"a)",
+		"a OR",
+		"AND a",
+		"OR",
+		"()",
+		"a OR -b",
+		"*",
+		"~1",
+		"SQL~3",
+		"SQL~x",
+	}
+	for _, query := range tests {
+		if node, err := parseTermQuery(query); err == nil {
+			t.Errorf("parseTermQuery(%q) = %s, want an error", query, showTerm(node))
+		}
+	}
+}
+
+func TestNewTermSearch(t *testing.T) {
+	search, err := NewTermSearch("SQL", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(search.Fields) != 1 || search.Fields[0] != defaultSearchField {
+		t.Errorf("default fields = %v, want [%s]", search.Fields, defaultSearchField)
+	}
+	if _, ok := search.esQuery()["min_score"]; ok {
+		t.Error("min_score set without a minimum")
+	}
+
+	search, err = NewTermSearch("SQL", []string{"title", "tags"}, 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := search.esQuery()["min_score"]; got != 1.5 {
+		t.Errorf("min_score = %v, want 1.5", got)
+	}
+
+	for _, tt := range []struct {
+		query    string
+		fields   []string
+		minScore float64
+	}{
+		{" ", nil, 0},
+		{"SQL", []string{"author"}, 0},
+		{"SQL", nil, -1},
+	} {
+		if _, err := NewTermSearch(tt.query, tt.fields, tt.minScore); err == nil {
+			t.Errorf("NewTermSearch(%q, %v, %v) succeeded, want an error", tt.query, tt.fields, tt.minScore)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package accounting
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	TrendScopeStudent    = "student"
+	TrendScopeGroup      = "group"
+	TrendScopeDiscipline = "discipline"
+	TrendScopeDepartment = "department"
+
+	TrendBucketDay   = "day"
+	TrendBucketWeek  = "week"
+	TrendBucketMonth = "month"
+)
+
+var trendBuckets = map[string]struct{}{
+	TrendBucketDay:   {},
+	TrendBucketWeek:  {},
+	TrendBucketMonth: {},
+}
+
+type AttendanceTrend struct {
+	Scope           string        `json:"scope"`
+	ID              string        `json:"id"`
+	Bucket          string        `json:"bucket"`
+	ReportingPeriod string        `json:"reporting_period"`
+	MovingAvgWindow int           `json:"moving_avg_window,omitempty"`
+	Buckets         []TrendBucket `json:"buckets"`
+}
+
+type TrendBucket struct {
+	Start         string   `json:"start"`
+	Planned       int      `json:"planned"`
+	Attended      int      `json:"attended"`
+	Rate          float64  `json:"rate"`
+	MovingAverage *float64 `json:"moving_average,omitempty"`
+}
+
+// GenerateAttendanceTrend buckets the attendance rate of a student (card_id),
+// group (name), discipline (discipline_id) or department (name). Week buckets
+// start on Monday, as ISO weeks do. A positive movingAvgWindow adds the
+// average rate over that many trailing buckets. Only attendance of students
+// in scope is counted.
+func (c *Client) GenerateAttendanceTrend(scope Scope, trendScope, id, bucket, startDate, endDate string, movingAvgWindow int) (*AttendanceTrend, error) {
+	filter, ok := attendanceTrendFilters[trendScope]
+	if !ok {
+		return nil, fmt.Errorf("unknown trend scope %q", trendScope)
+	}
+	if _, ok := trendBuckets[bucket]; !ok {
+		return nil, fmt.Errorf("unknown trend bucket %q", bucket)
+	}
+	if trendScope == TrendScopeDiscipline {
+		if _, err := strconv.Atoi(id); err != nil {
+			return nil, fmt.Errorf("discipline id must be a number")
+		}
+	}
+
+	args := append([]interface{}{bucket, startDate, endDate, id}, scope.args()...)
+	rows, err := c.pgdbClient.Query(fmt.Sprintf(getAttendanceTrendQuery, filter, scopeStudentFilter("s", 5)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attendance trend: %v", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]TrendBucket, 0)
+	for rows.Next() {
+		var b TrendBucket
+		if err := rows.Scan(&b.Start, &b.Planned, &b.Attended); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if b.Planned > 0 {
+			b.Rate = float64(b.Attended) / float64(b.Planned)
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read attendance trend: %v", err)
+	}
+
+	if movingAvgWindow > 0 {
+		addMovingAverage(buckets, movingAvgWindow)
+	}
+
+	return &AttendanceTrend{
+		Scope:           trendScope,
+		ID:              id,
+		Bucket:          bucket,
+		ReportingPeriod: fmt.Sprintf("%s to %s", startDate, endDate),
+		MovingAvgWindow: movingAvgWindow,
+		Buckets:         buckets,
+	}, nil
+}
+
+// addMovingAverage sets the trailing average of bucket rates. The first
+// window-1 buckets average over what is available so the line starts at the
+// first point.
+func addMovingAverage(buckets []TrendBucket, window int) {
+	var sum float64
+	for i := range buckets {
+		sum += buckets[i].Rate
+		if i >= window {
+			sum -= buckets[i-window].Rate
+		}
+		n := window
+		if i+1 < window {
+			n = i + 1
+		}
+		avg := sum / float64(n)
+		buckets[i].MovingAverage = &avg
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix      = "ua_"
+	apiKeyRandomBytes = 32
+	// apiKeyDisplayLength is how many leading characters of a key are kept
+	// in plain text, so an operator can tell keys apart.
+	apiKeyDisplayLength = 10
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+type APIKey struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Grant
+}
+
+// CreatedAPIKey carries the plain key, which is returned only once and never
+// stored.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *KeyStore) Create(ctx context.Context, name string, grant Grant) (*CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("API key name must not be empty")
+	}
+	if err := grant.Validate(); err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, apiKeyRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	created := &CreatedAPIKey{
+		APIKey: APIKey{Name: name, Prefix: key[:apiKeyDisplayLength], Grant: grant},
+		Key:    key,
+	}
+	groups := grant.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	err := s.db.QueryRowContext(ctx, createAPIKeyQuery, name, created.Prefix, hashAPIKey(key),
+		grant.Role, grant.CardID, pq.Array(groups), grant.Department, grant.TeacherID).
+		Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create API key: %w", err)
+	}
+	return created, nil
+}
+
+func (s *KeyStore) Lookup(ctx context.Context, key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	found := &APIKey{}
+	err := s.db.QueryRowContext(ctx, getAPIKeyByHashQuery, hashAPIKey(key)).
+		Scan(&found.ID, &found.Name, &found.Role, &found.CardID, pq.Array(&found.Groups), &found.Department, &found.TeacherID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up API key: %w", err)
+	}
+	return found, nil
+}
+
+func (s *KeyStore) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, listAPIKeysQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CardID, pq.Array(&key.Groups),
+			&key.Department, &key.TeacherID, &key.CreatedAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		if revokedAt.Valid {
+			key.RevokedAt = &revokedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables a key. Revoking an unknown or already revoked key returns
+// ErrAPIKeyNotFound.
+func (s *KeyStore) Revoke(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, revokeAPIKeyQuery, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Disabled turns authentication off entirely. Intended for local
+	// development only.
+	Disabled bool `json:"disabled"`
+	// HS256Secret enables HS256 bearer tokens signed with this secret.
+	HS256Secret string `json:"hs256_secret"`
+	// RSAPublicKeyFiles are PEM files with RS256 verification keys. A PEM key
+	// has no kid of its own, so each is known by its RFC 7638 thumbprint. A
+	// single configured file also verifies tokens that do not name a kid.
+	RSAPublicKeyFiles []string `json:"rsa_public_key_files"`
+	// JWKSFile is a local JSON Web Key Set with RS256 verification keys.
+	JWKSFile  string `json:"jwks_file"`
+	Issuer    string `json:"issuer"`
+	Audience  string `json:"audience"`
+	LeewaySec int    `json:"leeway_sec"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		LeewaySec: 30,
+	}
+}
+
+// minSecretLen is the shortest HS256 secret accepted, the size of the
+// SHA-256 output as RFC 7518 requires.
+const minSecretLen = 32
+
+// placeholderSecret is the value older example configs shipped with.
+const placeholderSecret = "change-me"
+
+func ValidateConfig(cfg Config) error {
+	if cfg.HS256Secret == placeholderSecret {
+		return errors.New("hs256_secret is the example placeholder, set a random secret or leave it empty")
+	}
+	if cfg.HS256Secret != "" && len(cfg.HS256Secret) < minSecretLen {
+		return fmt.Errorf("hs256_secret must be at least %d bytes", minSecretLen)
+	}
+	if cfg.LeewaySec < 0 {
+		return errors.New("leeway_sec must not be negative")
+	}
+	return nil
+}
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrInvalidAPIKey   = errors.New("invalid API key")
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+	Grant
+	// APIKeyID is set for callers authenticated with an API key.
+	APIKeyID int64   `json:"api_key_id,omitempty"`
+	Claims   *Claims `json:"-"`
+}
+
+type Authenticator struct {
+	disabled bool
+	jwt      *jwtVerifier
+	keys     *KeyStore
+}
+
+// NewAuthenticator loads the configured verification keys. API keys are
+// always accepted, bearer tokens only when at least one key is configured.
+func NewAuthenticator(cfg Config, keys *KeyStore) (*Authenticator, error) {
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	verifier := &jwtVerifier{
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   time.Duration(cfg.LeewaySec) * time.Second,
+	}
+	if cfg.HS256Secret != "" {
+		verifier.hmacSecret = []byte(cfg.HS256Secret)
+	}
+	for _, path := range cfg.RSAPublicKeyFiles {
+		key, err := loadRSAPublicKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("load RSA public key %s: %w", path, err)
+		}
+		verifier.rsaKeys[thumbprint(key)] = key
+		if len(cfg.RSAPublicKeyFiles) == 1 {
+			verifier.rsaKeys[""] = key
+		}
+	}
+	if cfg.JWKSFile != "" {
+		jwksKeys, err := loadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("load JWKS %s: %w", cfg.JWKSFile, err)
+		}
+		for kid, key := range jwksKeys {
+			verifier.rsaKeys[kid] = key
+		}
+	}
+
+	return &Authenticator{
+		disabled: cfg.Disabled,
+		jwt:      verifier,
+		keys:     keys,
+	}, nil
+}
+
+func (a *Authenticator) Disabled() bool {
+	return a.disabled
+}
+
+// Authenticate checks the Authorization and X-API-Key header values. A bearer
+// token takes precedence when both are present.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (*Principal, error) {
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		if !a.jwt.enabled() {
+			return nil, errors.New("bearer tokens are not accepted")
+		}
+		claims, err := a.jwt.verify(strings.TrimSpace(token), time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if claims.Subject == "" {
+			return nil, errors.New("token has no subject")
+		}
+		grant := grantFromClaims(claims)
+		if err := grant.Validate(); err != nil {
+			return nil, fmt.Errorf("token grant: %v", err)
+		}
+		return &Principal{Subject: claims.Subject, Method: MethodJWT, Grant: grant, Claims: claims}, nil
+	}
+	if authorization != "" {
+		return nil, errors.New("unsupported authorization scheme")
+	}
+
+	if apiKey != "" {
+		key, err := a.keys.Lookup(ctx, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		// Teacher keys created before teacher_id existed have none.
+		if err := key.Grant.Validate(); err != nil {
+			return nil, fmt.Errorf("API key grant: %v", err)
+		}
+		// Key names are not unique, and the subject owns report jobs and
+		// rate limit buckets.
+		return &Principal{Subject: fmt.Sprintf("api-key:%d", key.ID), Method: MethodAPIKey, Grant: key.Grant, APIKeyID: key.ID}, nil
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+type KeyStore struct {
+	db *sql.DB
+}
+
+func NewKeyStore(db *sql.DB) *KeyStore {
+	return &KeyStore{db: db}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Claims are the registered JWT claims the service checks plus the raw claim
+// set for everything else.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	Raw       map[string]interface{}
+}
+
+type jwtVerifier struct {
+	hmacSecret []byte
+	// rsaKeys are looked up by kid. The key under "" verifies tokens that
+	// do not name one.
+	rsaKeys  map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+func (v *jwtVerifier) enabled() bool {
+	return len(v.hmacSecret) > 0 || len(v.rsaKeys) > 0
+}
+
+func (v *jwtVerifier) verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	switch header.Alg {
+	case "HS256":
+		if len(v.hmacSecret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if subtle.ConstantTimeCompare(mac.Sum(nil), signature) != 1 {
+			return nil, errors.New("invalid token signature")
+		}
+	case "RS256":
+		key, ok := v.rsaKeys[header.Kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", header.Kid)
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	claims := parseClaims(raw)
+
+	if claims.ExpiresAt.IsZero() {
+		return nil, errors.New("token has no expiration")
+	}
+	if now.After(claims.ExpiresAt.Add(v.leeway)) {
+		return nil, errors.New("token has expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.leeway).Before(claims.NotBefore) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, errors.New("token issuer is not accepted")
+	}
+	if v.audience != "" && !containsString(claims.Audience, v.audience) {
+		return nil, errors.New("token audience is not accepted")
+	}
+
+	return claims, nil
+}
+
+func parseClaims(raw map[string]interface{}) *Claims {
+	claims := &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Issuer, _ = raw["iss"].(string)
+	switch aud := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+	if exp, ok := raw["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	if nbf, ok := raw["nbf"].(float64); ok {
+		claims.NotBefore = time.Unix(int64(nbf), 0)
+	}
+	return claims
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
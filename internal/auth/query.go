@@ -0,0 +1,27 @@
+package auth
+
+const (
+	createAPIKeyQuery = `
+		INSERT INTO api_key (name, prefix, key_hash, role, card_id, groups, department, teacher_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at;
+	`
+
+	getAPIKeyByHashQuery = `
+		SELECT id, name, role, card_id, groups, department, teacher_id
+		FROM api_key
+		WHERE key_hash = $1 AND revoked_at IS NULL;
+	`
+
+	listAPIKeysQuery = `
+		SELECT id, name, prefix, role, card_id, groups, department, teacher_id, created_at, revoked_at
+		FROM api_key
+		ORDER BY id;
+	`
+
+	revokeAPIKeyQuery = `
+		UPDATE api_key
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL;
+	`
+)
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	RoleAdmin          = "admin"
+	RoleDepartmentHead = "department_head"
+	RoleCurator        = "curator"
+	RoleTeacher        = "teacher"
+	RoleStudent        = "student"
+)
+
+var roles = map[string]struct{}{
+	RoleAdmin:          {},
+	RoleDepartmentHead: {},
+	RoleCurator:        {},
+	RoleTeacher:        {},
+	RoleStudent:        {},
+}
+
+// Grant is what a caller is allowed to see: a role and the attributes that
+// bound it. Admins see every student, a department head the students of
+// Department, a curator those of Groups, a teacher those of the groups
+// TeacherID teaches and a student only CardID.
+type Grant struct {
+	Role       string   `json:"role"`
+	CardID     string   `json:"card_id,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Department string   `json:"department,omitempty"`
+	TeacherID  int      `json:"teacher_id,omitempty"`
+}
+
+// Validate checks that the role is known and has the attribute it is bounded
+// by.
+func (g Grant) Validate() error {
+	if _, ok := roles[g.Role]; !ok {
+		return fmt.Errorf("unknown role %q", g.Role)
+	}
+
+	switch g.Role {
+	case RoleDepartmentHead:
+		if g.Department == "" {
+			return errors.New("department_head role requires a department")
+		}
+	case RoleCurator:
+		if len(g.Groups) == 0 {
+			return errors.New("curator role requires groups")
+		}
+	case RoleTeacher:
+		if g.TeacherID <= 0 {
+			return errors.New("teacher role requires a teacher_id")
+		}
+	case RoleStudent:
+		if g.CardID == "" {
+			return errors.New("student role requires a card_id")
+		}
+	}
+	return nil
+}
+
+// HasRole reports whether the grant has one of the roles. Admin has every
+// role.
+func (g Grant) HasRole(allowed ...string) bool {
+	if g.Role == RoleAdmin {
+		return true
+	}
+	for _, role := range allowed {
+		if g.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func grantFromClaims(claims *Claims) Grant {
+	grant := Grant{}
+	grant.Role, _ = claims.Raw["role"].(string)
+	grant.CardID, _ = claims.Raw["card_id"].(string)
+	grant.Department, _ = claims.Raw["department"].(string)
+	if teacherID, ok := claims.Raw["teacher_id"].(float64); ok {
+		grant.TeacherID = int(teacherID)
+	}
+	switch groups := claims.Raw["groups"].(type) {
+	case string:
+		grant.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if s, ok := group.(string); ok {
+				grant.Groups = append(grant.Groups, s)
+			}
+		}
+	}
+	return grant
+}
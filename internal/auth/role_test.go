@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGrantValidate(t *testing.T) {
+	tests := []struct {
+		grant Grant
+		err   string
+	}{
+		{Grant{Role: RoleAdmin}, ""},
+		{Grant{Role: RoleDepartmentHead, Department: "ИТ"}, ""},
+		{Grant{Role: RoleCurator, Groups: []string{"БСБО-01-21"}}, ""},
+		{Grant{Role: RoleTeacher, TeacherID: 3}, ""},
+		{Grant{Role: RoleStudent, CardID: "21Б0001"}, ""},
+		{Grant{Role: "dean"}, "unknown role"},
+		{Grant{Role: RoleDepartmentHead}, "requires a department"},
+		{Grant{Role: RoleCurator}, "requires groups"},
+		{Grant{Role: RoleTeacher}, "requires a teacher_id"},
+		{Grant{Role: RoleTeacher, TeacherID: -1}, "requires a teacher_id"},
+		{Grant{Role: RoleStudent}, "requires a card_id"},
+	}
+	for _, tt := range tests {
+		err := tt.grant.Validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%+v: unexpected error: %v", tt.grant, err)
+		case tt.err != "" && err == nil:
+			t.Errorf("%+v: expected error containing %q", tt.grant, tt.err)
+		case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+			t.Errorf("%+v: error %q does not contain %q", tt.grant, err, tt.err)
+		}
+	}
+}
+
+func TestGrantFromClaims(t *testing.T) {
+	claims := &Claims{Raw: map[string]interface{}{
+		"role":       RoleTeacher,
+		"teacher_id": float64(7),
+		"groups":     "БСБО-01-21",
+	}}
+
+	grant := grantFromClaims(claims)
+	if grant.Role != RoleTeacher || grant.TeacherID != 7 {
+		t.Errorf("got role %q teacher %d, want teacher 7", grant.Role, grant.TeacherID)
+	}
+	if len(grant.Groups) != 1 || grant.Groups[0] != "БСБО-01-21" {
+		t.Errorf("got groups %v, want the single group", grant.Groups)
+	}
+}
@@ -0,0 +1,68 @@
+// Package bootstrap creates the Elasticsearch indices and the Neo4j
+// constraints the service expects, and checks them on startup.
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+type Config struct {
+	// AutoApply brings the indices and constraints up to date when the
+	// service starts. Otherwise the service refuses to start without them.
+	AutoApply bool `json:"auto_apply"`
+}
+
+func DefaultConfig() Config {
+	return Config{AutoApply: false}
+}
+
+type Bootstrapper struct {
+	elastic elasticBootstrap
+	neo4j   neo4jBootstrap
+}
+
+func NewBootstrapper(es *elasticsearch.Client, driver neo4j.Driver) *Bootstrapper {
+	return &Bootstrapper{
+		elastic: elasticBootstrap{es: es},
+		neo4j:   neo4jBootstrap{driver: driver},
+	}
+}
+
+// Apply creates or upgrades the index templates, indices, aliases and
+// constraints, and returns a line per change. It is a no-op on an up to date
+// deployment.
+func (b *Bootstrapper) Apply(ctx context.Context) ([]string, error) {
+	var actions []string
+	for _, spec := range indexSpecs {
+		done, err := b.elastic.apply(ctx, spec)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, done...)
+	}
+
+	created, err := b.neo4j.apply()
+	if err != nil {
+		return actions, err
+	}
+	for _, name := range created {
+		actions = append(actions, "created constraint "+name)
+	}
+	return actions, nil
+}
+
+// Verify fails when an index or a constraint is missing or outdated.
+func (b *Bootstrapper) Verify(ctx context.Context) error {
+	for _, spec := range indexSpecs {
+		if err := b.elastic.verify(ctx, spec); err != nil {
+			return fmt.Errorf("elasticsearch: %v", err)
+		}
+	}
+	if err := b.neo4j.verify(); err != nil {
+		return fmt.Errorf("neo4j: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// indexSpec describes an index the service reads through an alias. Documents
+// live in <alias>-v<version>, created from the <alias> index template. A new
+// version gets a new index, reindexed from the old one, and the alias is
+// moved in one atomic step, so searches never see a half-built index.
+type indexSpec struct {
+	alias   string
+	version int
+	// properties are the field mappings. Changing them requires a new
+	// version.
+	properties map[string]interface{}
+}
+
+var keywordSubfield = map[string]interface{}{
+	"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+}
+
+// IDs are keywords since the accounting client reads them back as strings.
+var indexSpecs = []indexSpec{
+	{
+		alias:   "materials",
+		version: 1,
+		properties: map[string]interface{}{
+			"material_id": map[string]interface{}{"type": "keyword"},
+			"title":       map[string]interface{}{"type": "text", "fields": keywordSubfield},
+			"content":     map[string]interface{}{"type": "text"},
+			"tags":        map[string]interface{}{"type": "text", "fields": keywordSubfield},
+			"lesson_ids":  map[string]interface{}{"type": "long"},
+		},
+	},
+	{
+		alias:   "disciplines",
+		version: 1,
+		properties: map[string]interface{}{
+			"discipline_id": map[string]interface{}{"type": "keyword"},
+			"name":          map[string]interface{}{"type": "text", "fields": keywordSubfield},
+			"description":   map[string]interface{}{"type": "text"},
+		},
+	},
+}
+
+func (s indexSpec) index() string {
+	return fmt.Sprintf("%s-v%d", s.alias, s.version)
+}
+
+func (s indexSpec) template() map[string]interface{} {
+	return map[string]interface{}{
+		"index_patterns": []string{s.alias + "-v*"},
+		"version":        s.version,
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{"properties": s.properties},
+		},
+	}
+}
+
+type elasticBootstrap struct {
+	es *elasticsearch.Client
+}
+
+// apply brings the template, the index and the alias of the spec up to date
+// and returns what it did.
+func (b elasticBootstrap) apply(ctx context.Context, spec indexSpec) ([]string, error) {
+	var actions []string
+
+	templateVersion, err := b.templateVersion(ctx, spec.alias)
+	if err != nil {
+		return nil, err
+	}
+	if templateVersion != spec.version {
+		if err := b.do(b.es.Indices.PutIndexTemplate(spec.alias, jsonBody(spec.template()), b.es.Indices.PutIndexTemplate.WithContext(ctx))); err != nil {
+			return nil, fmt.Errorf("failed to put index template %s: %v", spec.alias, err)
+		}
+		actions = append(actions, fmt.Sprintf("put index template %s version %d", spec.alias, spec.version))
+	}
+
+	current, legacy, err := b.aliasTarget(ctx, spec.alias)
+	if err != nil {
+		return nil, err
+	}
+	if current == spec.index() {
+		return actions, nil
+	}
+
+	exists, err := b.exists(ctx, spec.index())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// The template supplies the mappings.
+		if err := b.do(b.es.Indices.Create(spec.index(), b.es.Indices.Create.WithContext(ctx))); err != nil {
+			return nil, fmt.Errorf("failed to create index %s: %v", spec.index(), err)
+		}
+		actions = append(actions, "created index "+spec.index())
+	}
+
+	source := current
+	if legacy {
+		source = spec.alias
+	}
+	if source != "" {
+		body := map[string]interface{}{
+			"source": map[string]interface{}{"index": source},
+			"dest":   map[string]interface{}{"index": spec.index()},
+		}
+		copied, err := b.reindex(ctx, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reindex %s into %s: %v", source, spec.index(), err)
+		}
+		actions = append(actions, fmt.Sprintf("reindexed %d documents from %s into %s", copied, source, spec.index()))
+	}
+
+	// A legacy index named like the alias is dropped in the same request
+	// that creates the alias, only after reindex copied all its documents.
+	// Older versioned indices are kept for rollback.
+	aliasActions := []interface{}{
+		map[string]interface{}{"add": map[string]interface{}{"index": spec.index(), "alias": spec.alias, "is_write_index": true}},
+	}
+	switch {
+	case legacy:
+		aliasActions = append(aliasActions, map[string]interface{}{"remove_index": map[string]interface{}{"index": spec.alias}})
+	case current != "":
+		aliasActions = append(aliasActions, map[string]interface{}{"remove": map[string]interface{}{"index": current, "alias": spec.alias}})
+	}
+	if err := b.do(b.es.Indices.UpdateAliases(jsonBody(map[string]interface{}{"actions": aliasActions}), b.es.Indices.UpdateAliases.WithContext(ctx))); err != nil {
+		return nil, fmt.Errorf("failed to point alias %s to %s: %v", spec.alias, spec.index(), err)
+	}
+	actions = append(actions, fmt.Sprintf("pointed alias %s to %s", spec.alias, spec.index()))
+	return actions, nil
+}
+
+// reindex copies the documents and fails unless every one of them reached the
+// destination. Documents left there by an earlier failed run are updated
+// rather than created, so both count as copied.
+func (b elasticBootstrap) reindex(ctx context.Context, body map[string]interface{}) (int, error) {
+	res, err := b.es.Reindex(jsonBody(body), b.es.Reindex.WithContext(ctx), b.es.Reindex.WithWaitForCompletion(true), b.es.Reindex.WithRefresh(true))
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		Total    int               `json:"total"`
+		Created  int               `json:"created"`
+		Updated  int               `json:"updated"`
+		TimedOut bool              `json:"timed_out"`
+		Failures []json.RawMessage `json:"failures"`
+	}
+	if err := decode(res, &result); err != nil {
+		return 0, err
+	}
+	if len(result.Failures) > 0 {
+		return 0, fmt.Errorf("%d documents failed, first: %s", len(result.Failures), result.Failures[0])
+	}
+	if result.TimedOut {
+		return 0, errors.New("timed out")
+	}
+	copied := result.Created + result.Updated
+	if copied < result.Total {
+		return 0, fmt.Errorf("copied %d of %d documents", copied, result.Total)
+	}
+	return copied, nil
+}
+
+// verify checks that the alias points to the current version and that the
+// fields have the mapped types.
+func (b elasticBootstrap) verify(ctx context.Context, spec indexSpec) error {
+	current, legacy, err := b.aliasTarget(ctx, spec.alias)
+	if err != nil {
+		return err
+	}
+	switch {
+	case legacy:
+		return fmt.Errorf("%s is a plain index, not an alias to %s", spec.alias, spec.index())
+	case current == "":
+		return fmt.Errorf("alias %s does not exist", spec.alias)
+	case current != spec.index():
+		return fmt.Errorf("alias %s points to %s instead of %s", spec.alias, current, spec.index())
+	}
+
+	res, err := b.es.Indices.GetMapping(b.es.Indices.GetMapping.WithContext(ctx), b.es.Indices.GetMapping.WithIndex(current))
+	if err != nil {
+		return fmt.Errorf("failed to get mapping of %s: %v", current, err)
+	}
+	var mappings map[string]struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type string `json:"type"`
+			} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := decode(res, &mappings); err != nil {
+		return fmt.Errorf("failed to get mapping of %s: %v", current, err)
+	}
+
+	properties := mappings[current].Mappings.Properties
+	for field, mapping := range spec.properties {
+		want := mapping.(map[string]interface{})["type"]
+		if got := properties[field].Type; got != want {
+			return fmt.Errorf("field %s of %s is mapped as %q instead of %q", field, current, got, want)
+		}
+	}
+	return nil
+}
+
+func (b elasticBootstrap) templateVersion(ctx context.Context, name string) (int, error) {
+	res, err := b.es.Indices.GetIndexTemplate(b.es.Indices.GetIndexTemplate.WithContext(ctx), b.es.Indices.GetIndexTemplate.WithName(name))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get index template %s: %v", name, err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return 0, nil
+	}
+	var result struct {
+		IndexTemplates []struct {
+			IndexTemplate struct {
+				Version int `json:"version"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := decode(res, &result); err != nil {
+		return 0, fmt.Errorf("failed to get index template %s: %v", name, err)
+	}
+	if len(result.IndexTemplates) == 0 {
+		return 0, nil
+	}
+	return result.IndexTemplates[0].IndexTemplate.Version, nil
+}
+
+// aliasTarget returns the index the alias points to, or legacy when a
+// concrete index has the alias' name, as dynamic mapping creates it.
+func (b elasticBootstrap) aliasTarget(ctx context.Context, alias string) (string, bool, error) {
+	res, err := b.es.Indices.GetAlias(b.es.Indices.GetAlias.WithContext(ctx), b.es.Indices.GetAlias.WithName(alias))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get alias %s: %v", alias, err)
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		exists, err := b.exists(ctx, alias)
+		return "", exists, err
+	}
+
+	var indices map[string]interface{}
+	if err := decode(res, &indices); err != nil {
+		return "", false, fmt.Errorf("failed to get alias %s: %v", alias, err)
+	}
+	for index := range indices {
+		return index, false, nil
+	}
+	return "", false, nil
+}
+
+func (b elasticBootstrap) exists(ctx context.Context, index string) (bool, error) {
+	res, err := b.es.Indices.Exists([]string{index}, b.es.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to check index %s: %v", index, err)
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// do checks the response of a request whose body is not needed.
+func (b elasticBootstrap) do(res *esapi.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return decode(res, nil)
+}
+
+func decode(res *esapi.Response, v interface{}) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status(), strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func jsonBody(v interface{}) io.Reader {
+	data, _ := json.Marshal(v)
+	return bytes.NewReader(data)
+}
@@ -0,0 +1,101 @@
+package bootstrap
+
+import (
+	"fmt"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// nodeConstraint is a uniqueness constraint on a node property. Neo4j backs
+// each one with an index, which the MERGE statements of the service use.
+type nodeConstraint struct {
+	name     string
+	label    string
+	property string
+}
+
+var nodeConstraints = []nodeConstraint{
+	{name: "material_id_unique", label: "Material", property: "id"},
+	{name: "lesson_id_unique", label: "Lesson", property: "id"},
+	{name: "discipline_id_unique", label: "Discipline", property: "id"},
+	// Prerequisite additions serialize on one lock node. Without the
+	// constraint two first additions could each MERGE their own.
+	{name: "prerequisite_lock_id_unique", label: "PrerequisiteLock", property: "id"},
+}
+
+type neo4jBootstrap struct {
+	driver neo4j.Driver
+}
+
+// apply creates the missing constraints and returns the names of those it
+// created.
+func (b neo4jBootstrap) apply() ([]string, error) {
+	existing, err := b.constraints()
+	if err != nil {
+		return nil, err
+	}
+
+	session := b.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	var created []string
+	for _, constraint := range nodeConstraints {
+		if existing[constraint.key()] {
+			continue
+		}
+		query := fmt.Sprintf("CREATE CONSTRAINT %s IF NOT EXISTS FOR (n:%s) REQUIRE n.%s IS UNIQUE", constraint.name, constraint.label, constraint.property)
+		result, err := session.Run(query, nil)
+		if err == nil {
+			_, err = result.Consume()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create constraint %s: %v", constraint.name, err)
+		}
+		created = append(created, constraint.name)
+	}
+	return created, nil
+}
+
+func (b neo4jBootstrap) verify() error {
+	existing, err := b.constraints()
+	if err != nil {
+		return err
+	}
+	for _, constraint := range nodeConstraints {
+		if !existing[constraint.key()] {
+			return fmt.Errorf("%s.%s has no uniqueness constraint", constraint.label, constraint.property)
+		}
+	}
+	return nil
+}
+
+// constraints returns the single-property uniqueness constraints in the
+// database by label and property, whatever their names.
+func (b neo4jBootstrap) constraints() (map[string]bool, error) {
+	session := b.driver.NewSession(neo4j.SessionConfig{})
+	defer session.Close()
+
+	result, err := session.Run(`SHOW CONSTRAINTS YIELD type, labelsOrTypes, properties RETURN type, labelsOrTypes, properties`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list constraints: %v", err)
+	}
+
+	existing := make(map[string]bool)
+	for result.Next() {
+		record := result.Record()
+		kind, _ := record.Values[0].(string)
+		labels, _ := record.Values[1].([]interface{})
+		properties, _ := record.Values[2].([]interface{})
+		if (kind != "UNIQUENESS" && kind != "NODE_PROPERTY_UNIQUENESS") || len(labels) != 1 || len(properties) != 1 {
+			continue
+		}
+		existing[fmt.Sprintf("%v.%v", labels[0], properties[0])] = true
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list constraints: %v", err)
+	}
+	return existing, nil
+}
+
+func (c nodeConstraint) key() string {
+	return c.label + "." + c.property
+}
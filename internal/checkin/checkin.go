@@ -0,0 +1,305 @@
+// Package checkin lets students mark their own attendance with a short code
+// shown by the teacher during the lesson. The code rotates every few seconds
+// and is derived from a secret of the check-in session, so a code is only
+// good for the session it was shown in and only for a short while.
+package checkin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+type Config struct {
+	// SessionTTLSec is how long a session accepts check-ins after it is
+	// opened.
+	SessionTTLSec int `json:"session_ttl_sec"`
+	// CodeStepSec is how often the code changes.
+	CodeStepSec int `json:"code_step_sec"`
+	CodeDigits  int `json:"code_digits"`
+	// AcceptedSteps is how many codes before the current one are still
+	// accepted, for students who typed or scanned the code as it changed.
+	AcceptedSteps int `json:"accepted_steps"`
+	// MaxAttempts is how many codes a student may submit per session.
+	MaxAttempts int `json:"max_attempts"`
+	// QRSize is the default side of the QR image in pixels.
+	QRSize int `json:"qr_size"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SessionTTLSec: 15 * 60,
+		CodeStepSec:   30,
+		CodeDigits:    6,
+		AcceptedSteps: 1,
+		MaxAttempts:   5,
+		QRSize:        256,
+	}
+}
+
+const (
+	minCodeDigits = 4
+	maxCodeDigits = 9
+	secretBytes   = 20
+	// MaxQRSize bounds the size of a requested QR image.
+	MaxQRSize = 2048
+)
+
+func ValidateConfig(cfg Config) error {
+	if cfg.CodeStepSec <= 0 || cfg.SessionTTLSec < cfg.CodeStepSec {
+		return fmt.Errorf("code_step_sec must be positive and not longer than session_ttl_sec")
+	}
+	if cfg.CodeDigits < minCodeDigits || cfg.CodeDigits > maxCodeDigits {
+		return fmt.Errorf("code_digits must be between %d and %d", minCodeDigits, maxCodeDigits)
+	}
+	if cfg.AcceptedSteps < 0 || cfg.MaxAttempts <= 0 {
+		return fmt.Errorf("accepted_steps must not be negative and max_attempts must be positive")
+	}
+	if cfg.QRSize <= 0 || cfg.QRSize > MaxQRSize {
+		return fmt.Errorf("qr_size must be between 1 and %d", MaxQRSize)
+	}
+	return nil
+}
+
+var (
+	ErrNoSession        = errors.New("no open check-in session for the scheduled lesson")
+	ErrNotToday         = errors.New("check-in can only be opened on the day of the lesson")
+	ErrInvalidCode      = errors.New("check-in code is wrong or expired")
+	ErrAlreadyCheckedIn = errors.New("student has already checked in to this session")
+	ErrTooManyAttempts  = errors.New("too many check-in attempts")
+)
+
+// Session is an open check-in for one scheduled lesson. Opening another
+// session for the lesson replaces it.
+type Session struct {
+	ID         string    `json:"session_id"`
+	ScheduleID int64     `json:"schedule_id"`
+	OpenedBy   string    `json:"opened_by,omitempty"`
+	OpenedAt   time.Time `json:"opened_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// storedSession is the Redis record of a session. The secret never leaves
+// the service.
+type storedSession struct {
+	Session
+	Secret []byte `json:"secret"`
+}
+
+// Code is the code of a session at the moment it was asked for.
+type Code struct {
+	Session
+	Code      string    `json:"code"`
+	RotatesAt time.Time `json:"rotates_at"`
+}
+
+type CheckIn struct {
+	SessionID   string    `json:"session_id"`
+	ScheduleID  int64     `json:"schedule_id"`
+	CardID      string    `json:"card_id"`
+	CheckedInAt time.Time `json:"checked_in_at"`
+}
+
+// Manager keeps sessions in Redis, so the code shown by one replica is
+// accepted by every other.
+type Manager struct {
+	accountingClient *accounting.Client
+	redisClient      *redis.Client
+	cfg              Config
+}
+
+func NewManager(accountingClient *accounting.Client, redisClient *redis.Client, cfg Config) *Manager {
+	return &Manager{
+		accountingClient: accountingClient,
+		redisClient:      redisClient,
+		cfg:              cfg,
+	}
+}
+
+// Open starts a session for a lesson scheduled today in the caller's scope
+// and returns its first code. A session already open for the lesson is
+// closed, and its codes stop working.
+func (m *Manager) Open(ctx context.Context, scope accounting.Scope, scheduleID int64, openedBy string) (*Code, error) {
+	lesson, err := m.accountingClient.GetScheduledLesson(ctx, scope, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if !lesson.Today {
+		return nil, ErrNotToday
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate session secret: %v", err)
+	}
+
+	now := time.Now().UTC()
+	s := &storedSession{
+		Session: Session{
+			ID:         id,
+			ScheduleID: scheduleID,
+			OpenedBy:   openedBy,
+			OpenedAt:   now,
+			ExpiresAt:  now.Add(m.sessionTTL()),
+		},
+		Secret: secret,
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal check-in session: %v", err)
+	}
+
+	previous, err := m.redisClient.Get(ctx, scheduleKey(scheduleID)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get check-in session: %v", err)
+	}
+	pipe := m.redisClient.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, sessionKey(previous))
+	}
+	pipe.Set(ctx, sessionKey(id), data, m.sessionTTL())
+	pipe.Set(ctx, scheduleKey(scheduleID), id, m.sessionTTL())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save check-in session: %v", err)
+	}
+
+	return m.code(s, now), nil
+}
+
+// Current returns the code to show now for the session of a lesson in the
+// caller's scope.
+func (m *Manager) Current(ctx context.Context, scope accounting.Scope, scheduleID int64) (*Code, error) {
+	if _, err := m.accountingClient.GetScheduledLesson(ctx, scope, scheduleID); err != nil {
+		return nil, err
+	}
+	s, err := m.load(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return m.code(s, time.Now().UTC()), nil
+}
+
+// Close ends the session of a lesson in the caller's scope. Attendance
+// recorded through it stays.
+func (m *Manager) Close(ctx context.Context, scope accounting.Scope, scheduleID int64) error {
+	if _, err := m.accountingClient.GetScheduledLesson(ctx, scope, scheduleID); err != nil {
+		return err
+	}
+	s, err := m.load(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	if err := m.redisClient.Del(ctx, scheduleKey(scheduleID), sessionKey(s.ID)).Err(); err != nil {
+		return fmt.Errorf("failed to close check-in session: %v", err)
+	}
+	return nil
+}
+
+// CheckIn marks the student present when code is one of the session's
+// current codes. Each student checks in once per session, so a submission
+// cannot be replayed, and gets MaxAttempts tries at the code.
+func (m *Manager) CheckIn(ctx context.Context, scheduleID int64, cardID, code string) (*CheckIn, error) {
+	s, err := m.load(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	remaining := s.ExpiresAt.Sub(now)
+	if remaining <= 0 {
+		return nil, ErrNoSession
+	}
+
+	attempts, err := m.redisClient.Incr(ctx, attemptsKey(s.ID, cardID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count check-in attempts: %v", err)
+	}
+	if attempts == 1 {
+		if err := m.redisClient.Expire(ctx, attemptsKey(s.ID, cardID), remaining).Err(); err != nil {
+			return nil, fmt.Errorf("failed to count check-in attempts: %v", err)
+		}
+	}
+	if attempts > int64(m.cfg.MaxAttempts) {
+		return nil, ErrTooManyAttempts
+	}
+
+	if !m.accepts(s, code, now) {
+		return nil, ErrInvalidCode
+	}
+
+	first, err := m.redisClient.SetNX(ctx, checkedInKey(s.ID, cardID), now.Unix(), remaining).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record check-in: %v", err)
+	}
+	if !first {
+		return nil, ErrAlreadyCheckedIn
+	}
+	if err := m.accountingClient.MarkAttended(ctx, scheduleID, cardID); err != nil {
+		// Let the student try again once the problem is solved.
+		_ = m.redisClient.Del(context.Background(), checkedInKey(s.ID, cardID)).Err()
+		return nil, err
+	}
+
+	return &CheckIn{SessionID: s.ID, ScheduleID: scheduleID, CardID: cardID, CheckedInAt: now}, nil
+}
+
+func (m *Manager) load(ctx context.Context, scheduleID int64) (*storedSession, error) {
+	id, err := m.redisClient.Get(ctx, scheduleKey(scheduleID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get check-in session: %v", err)
+	}
+
+	data, err := m.redisClient.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get check-in session: %v", err)
+	}
+
+	var s storedSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal check-in session: %v", err)
+	}
+	return &s, nil
+}
+
+func (m *Manager) sessionTTL() time.Duration {
+	return time.Duration(m.cfg.SessionTTLSec) * time.Second
+}
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("checkin:session:%s", id)
+}
+
+func scheduleKey(scheduleID int64) string {
+	return fmt.Sprintf("checkin:schedule:%d", scheduleID)
+}
+
+func attemptsKey(sessionID, cardID string) string {
+	return fmt.Sprintf("checkin:session:%s:attempts:%s", sessionID, cardID)
+}
+
+func checkedInKey(sessionID, cardID string) string {
+	return fmt.Sprintf("checkin:session:%s:checked-in:%s", sessionID, cardID)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
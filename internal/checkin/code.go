@@ -0,0 +1,88 @@
+package checkin
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"time"
+)
+
+// Codes are HOTP values (RFC 4226) of the session secret over the number of
+// code steps since the Unix epoch, as in TOTP (RFC 6238).
+
+func (m *Manager) step(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(m.cfg.CodeStepSec)
+}
+
+func (m *Manager) code(s *storedSession, now time.Time) *Code {
+	step := m.step(now)
+	return &Code{
+		Session:   s.Session,
+		Code:      hotp(s.Secret, step, m.cfg.CodeDigits),
+		RotatesAt: time.Unix(int64(step+1)*int64(m.cfg.CodeStepSec), 0).UTC(),
+	}
+}
+
+// accepts checks code against the current step and the AcceptedSteps before
+// it, leaving out steps from before the session was opened.
+func (m *Manager) accepts(s *storedSession, code string, now time.Time) bool {
+	if len(code) != m.cfg.CodeDigits {
+		return false
+	}
+	opened := m.step(s.OpenedAt)
+	step := m.step(now)
+	for i := 0; i <= m.cfg.AcceptedSteps && step >= opened; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(s.Secret, step, m.cfg.CodeDigits)), []byte(code)) == 1 {
+			return true
+		}
+		if step == 0 {
+			break
+		}
+		step--
+	}
+	return false
+}
+
+func hotp(secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+// qrPayload is what the QR image encodes, ready to be posted to the check-in
+// endpoint once the student adds a card_id.
+type qrPayload struct {
+	ScheduleID int64  `json:"schedule_id"`
+	Code       string `json:"code"`
+}
+
+// QR renders the code as a PNG QR image with the given side in pixels, the
+// configured one when size is 0.
+func (m *Manager) QR(code *Code, size int) ([]byte, error) {
+	if size == 0 {
+		size = m.cfg.QRSize
+	}
+	payload, err := json.Marshal(qrPayload{ScheduleID: code.ScheduleID, Code: code.Code})
+	if err != nil {
+		return nil, err
+	}
+	png, err := qrcode.Encode(string(payload), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %v", err)
+	}
+	return png, nil
+}
@@ -0,0 +1,82 @@
+package checkin
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the shared secret of the RFC 4226 and RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226, Appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp(rfcSecret, uint64(counter), 6); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestCodeTOTP(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CodeStepSec = 30
+	cfg.CodeDigits = 8
+	m := NewManager(nil, nil, cfg)
+	s := &storedSession{Secret: rfcSecret}
+
+	// RFC 6238, Appendix B, SHA1.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		code := m.code(s, time.Unix(tt.unix, 0))
+		if code.Code != tt.code {
+			t.Errorf("code at %d = %s, want %s", tt.unix, code.Code, tt.code)
+		}
+		if want := (tt.unix/30 + 1) * 30; code.RotatesAt.Unix() != want {
+			t.Errorf("code at %d rotates at %d, want %d", tt.unix, code.RotatesAt.Unix(), want)
+		}
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CodeStepSec = 30
+	cfg.CodeDigits = 6
+	cfg.AcceptedSteps = 1
+	m := NewManager(nil, nil, cfg)
+
+	opened := time.Unix(300, 0)
+	s := &storedSession{Session: Session{OpenedAt: opened}, Secret: rfcSecret}
+	at := func(sec int64) time.Time { return opened.Add(time.Duration(sec) * time.Second) }
+	codeAt := func(sec int64) string { return m.code(s, at(sec)).Code }
+
+	tests := []struct {
+		name string
+		code string
+		now  time.Time
+		want bool
+	}{
+		{"current step", codeAt(0), at(10), true},
+		{"previous step", codeAt(0), at(35), true},
+		{"two steps old", codeAt(0), at(65), false},
+		{"next step", codeAt(30), at(10), false},
+		{"before the session opened", codeAt(-30), at(5), false},
+		{"wrong length", codeAt(0)[:5], at(10), false},
+		{"wrong code", "000000", at(10), codeAt(0) == "000000"},
+	}
+	for _, tt := range tests {
+		if got := m.accepts(s, tt.code, tt.now); got != tt.want {
+			t.Errorf("%s: accepts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
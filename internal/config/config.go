@@ -0,0 +1,160 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/AlanMute/university-accounting/internal/bootstrap"
+	"github.com/AlanMute/university-accounting/internal/checkin"
+	"github.com/AlanMute/university-accounting/internal/graph"
+	"github.com/AlanMute/university-accounting/internal/migrate"
+	"github.com/AlanMute/university-accounting/internal/notify"
+	"github.com/AlanMute/university-accounting/internal/ratelimit"
+	"github.com/AlanMute/university-accounting/internal/redact"
+	"github.com/AlanMute/university-accounting/internal/reportjob"
+	"github.com/AlanMute/university-accounting/internal/scheduler"
+	"os"
+)
+
+type Config struct {
+	HTTP       HTTPConfig            `json:"http"`
+	Redis      RedisConfig           `json:"redis"`
+	Mongo      MongoConfig           `json:"mongo"`
+	Neo4j      Neo4jConfig           `json:"neo4j"`
+	Postgres   PostgresConfig        `json:"postgres"`
+	Elastic    ElasticConfig         `json:"elastic"`
+	RiskRules  []accounting.RiskRule `json:"risk_rules"`
+	Notify     notify.Config         `json:"notify"`
+	Scheduler  scheduler.Config      `json:"scheduler"`
+	ReportJobs reportjob.Config      `json:"report_jobs"`
+	Auth       auth.Config           `json:"auth"`
+	RateLimit  ratelimit.Config      `json:"rate_limit"`
+	Redaction  redact.Config         `json:"redaction"`
+	GraphQL    graph.Config          `json:"graphql"`
+	Migrations migrate.Config        `json:"migrations"`
+	Bootstrap  bootstrap.Config      `json:"bootstrap"`
+	CheckIn    checkin.Config        `json:"checkin"`
+}
+
+type HTTPConfig struct {
+	Addr string `json:"addr"`
+}
+
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+type MongoConfig struct {
+	URI      string `json:"uri"`
+	Database string `json:"database"`
+}
+
+type Neo4jConfig struct {
+	URI      string `json:"uri"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+type PostgresConfig struct {
+	DSN string `json:"dsn"`
+}
+
+// ElasticConfig with no addresses falls back to ELASTICSEARCH_URL or
+// http://localhost:9200.
+type ElasticConfig struct {
+	Addresses []string `json:"addresses"`
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+}
+
+// Default matches the port-forwarded setup described in README.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr: "0.0.0.0:8000",
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Mongo: MongoConfig{
+			URI:      "mongodb://localhost:27017",
+			Database: "accounting",
+		},
+		Neo4j: Neo4jConfig{
+			URI:      "bolt://localhost:7687",
+			User:     "neo4j",
+			Password: "password123",
+		},
+		Postgres: PostgresConfig{
+			DSN: "user=admin password=password123 dbname=mydb sslmode=disable",
+		},
+		RiskRules:  accounting.DefaultRiskRules(),
+		Notify:     notify.DefaultConfig(),
+		Scheduler:  scheduler.DefaultConfig(),
+		ReportJobs: reportjob.DefaultConfig(),
+		Auth:       auth.DefaultConfig(),
+		RateLimit:  ratelimit.DefaultConfig(),
+		Redaction:  redact.DefaultConfig(),
+		GraphQL:    graph.DefaultConfig(),
+		Migrations: migrate.DefaultConfig(),
+		Bootstrap:  bootstrap.DefaultConfig(),
+		CheckIn:    checkin.DefaultConfig(),
+	}
+}
+
+// Load reads a JSON config file on top of Default. An empty path returns the
+// defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	cfg.RiskRules = nil
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if cfg.RiskRules == nil {
+		cfg.RiskRules = accounting.DefaultRiskRules()
+	}
+
+	if err := accounting.ValidateRiskRules(cfg.RiskRules); err != nil {
+		return nil, fmt.Errorf("invalid risk rules: %v", err)
+	}
+
+	switch cfg.Notify.Locale {
+	case notify.LocaleRu, notify.LocaleEn:
+	default:
+		return nil, fmt.Errorf("invalid notify locale %q", cfg.Notify.Locale)
+	}
+	if err := scheduler.ValidateConfig(cfg.Scheduler); err != nil {
+		return nil, fmt.Errorf("invalid scheduler config: %v", err)
+	}
+	if cfg.ReportJobs.Workers <= 0 || cfg.ReportJobs.QueueSize < 0 || cfg.ReportJobs.ResultTTLSec <= 0 {
+		return nil, fmt.Errorf("report_jobs needs positive workers and result_ttl_sec")
+	}
+	if err := auth.ValidateConfig(cfg.Auth); err != nil {
+		return nil, fmt.Errorf("invalid auth config: %v", err)
+	}
+	if err := ratelimit.ValidateConfig(cfg.RateLimit); err != nil {
+		return nil, fmt.Errorf("invalid rate_limit config: %v", err)
+	}
+	if err := redact.ValidateConfig(cfg.Redaction); err != nil {
+		return nil, fmt.Errorf("invalid redaction config: %v", err)
+	}
+	if err := graph.ValidateConfig(cfg.GraphQL); err != nil {
+		return nil, fmt.Errorf("invalid graphql config: %v", err)
+	}
+	if err := checkin.ValidateConfig(cfg.CheckIn); err != nil {
+		return nil, fmt.Errorf("invalid checkin config: %v", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,133 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/AlanMute/university-accounting/pkg/cast"
+	"github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"strconv"
+)
+
+const principalKey = "principal"
+
+// authenticate stores the caller in the request user values, or writes 401
+// and returns false.
+func (h *HttpHandler) authenticate(ctx *fasthttp.RequestCtx) bool {
+	if h.authenticator.Disabled() {
+		return true
+	}
+
+	principal, err := h.authenticator.Authenticate(ctx,
+		cast.ByteArrayToString(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization)),
+		cast.ByteArrayToString(ctx.Request.Header.Peek("X-API-Key")),
+	)
+	if err != nil {
+		if !errors.Is(err, auth.ErrUnauthenticated) && !errors.Is(err, auth.ErrInvalidAPIKey) {
+			logrus.Debugf("Rejected credentials from %s: %v", ctx.RemoteIP(), err)
+		}
+		ctx.Response.Header.Set(fasthttp.HeaderWWWAuthenticate, `Bearer realm="university-accounting"`)
+		writeError(ctx, err.Error(), fasthttp.StatusUnauthorized)
+		return false
+	}
+
+	ctx.SetUserValue(principalKey, principal)
+	return true
+}
+
+// authorize writes 403 and returns false when the caller has none of the
+// roles. Every caller is authorized when authentication is disabled.
+func authorize(ctx *fasthttp.RequestCtx, roles []string) bool {
+	p := principal(ctx)
+	if p == nil || p.HasRole(roles...) {
+		return true
+	}
+
+	writeError(ctx, fmt.Sprintf("role %s may not access this endpoint", p.Role), fasthttp.StatusForbidden)
+	return false
+}
+
+// scopeOf maps the caller's grant to the students reports may include.
+func scopeOf(ctx *fasthttp.RequestCtx) accounting.Scope {
+	p := principal(ctx)
+	if p == nil {
+		return accounting.Scope{}
+	}
+
+	switch p.Role {
+	case auth.RoleDepartmentHead:
+		return accounting.Scope{Department: p.Department}
+	case auth.RoleCurator:
+		return accounting.Scope{Groups: p.Groups}
+	case auth.RoleTeacher:
+		return accounting.Scope{Teacher: p.TeacherID}
+	case auth.RoleStudent:
+		return accounting.Scope{CardID: p.CardID}
+	default:
+		return accounting.Scope{}
+	}
+}
+
+// principal returns the authenticated caller, or nil when authentication is
+// disabled.
+func principal(ctx *fasthttp.RequestCtx) *auth.Principal {
+	p, _ := ctx.UserValue(principalKey).(*auth.Principal)
+	return p
+}
+
+type createAPIKeyRequest struct {
+	Name string `json:"name"`
+	auth.Grant
+}
+
+func (h *HttpHandler) createAPIKey(ctx *fasthttp.RequestCtx) {
+	var req createAPIKeyRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, "body must be a JSON object with 'name' and 'role'", fasthttp.StatusBadRequest)
+		return
+	}
+
+	key, err := h.apiKeys.Create(ctx, req.Name, req.Grant)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+	if p := principal(ctx); p != nil {
+		logrus.Infof("API key %d (%s) created by %s", key.ID, key.Name, p.Subject)
+	}
+
+	writeObject(ctx, key, fasthttp.StatusCreated)
+}
+
+func (h *HttpHandler) listAPIKeys(ctx *fasthttp.RequestCtx) {
+	keys, err := h.apiKeys.List(ctx)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, keys, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) revokeAPIKey(ctx *fasthttp.RequestCtx) {
+	id, err := strconv.ParseInt(string(ctx.QueryArgs().Peek("id")), 10, 64)
+	if err != nil {
+		writeError(ctx, "'id' must be an API key id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	err = h.apiKeys.Revoke(ctx, id)
+	if errors.Is(err, auth.ErrAPIKeyNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
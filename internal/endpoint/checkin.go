@@ -0,0 +1,157 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/checkin"
+	"github.com/valyala/fasthttp"
+	"strconv"
+	"strings"
+)
+
+type checkInRequest struct {
+	ScheduleID int64  `json:"schedule_id"`
+	CardID     string `json:"card_id"`
+	Code       string `json:"code"`
+}
+
+func (h *HttpHandler) openCheckInSession(ctx *fasthttp.RequestCtx) {
+	scheduleID, err := strconv.ParseInt(string(ctx.QueryArgs().Peek("schedule")), 10, 64)
+	if err != nil {
+		writeError(ctx, "'schedule' must be a schedule id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	var openedBy string
+	if p := principal(ctx); p != nil {
+		openedBy = p.Subject
+	}
+
+	resp, err := h.checkIns.Open(ctx, scopeOf(ctx), scheduleID, openedBy)
+	if writeCheckInError(ctx, err) {
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusCreated)
+}
+
+// getCheckInCode answers with the current code, or with it as a QR image
+// when format=png.
+func (h *HttpHandler) getCheckInCode(ctx *fasthttp.RequestCtx) {
+	scheduleID, ok := scheduleIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	format := string(ctx.QueryArgs().Peek("format"))
+	switch format {
+	case "", "json", "png":
+	default:
+		writeError(ctx, "'format' must be json or png", fasthttp.StatusBadRequest)
+		return
+	}
+	var size int
+	if ctx.QueryArgs().Has("size") {
+		var err error
+		if size, err = ctx.QueryArgs().GetUint("size"); err != nil || size == 0 || size > checkin.MaxQRSize {
+			writeError(ctx, fmt.Sprintf("'size' must be an integer from 1 to %d", checkin.MaxQRSize), fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	code, err := h.checkIns.Current(ctx, scopeOf(ctx), scheduleID)
+	if writeCheckInError(ctx, err) {
+		return
+	}
+
+	if format != "png" {
+		writeObject(ctx, code, fasthttp.StatusOK)
+		return
+	}
+	png, err := h.checkIns.QR(code, size)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	// The image is only good until the code rotates.
+	ctx.Response.Header.Set(fasthttp.HeaderCacheControl, "no-store")
+	ctx.Response.Header.Set(fasthttp.HeaderContentType, "image/png")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	_, _ = ctx.Write(png)
+}
+
+func (h *HttpHandler) closeCheckInSession(ctx *fasthttp.RequestCtx) {
+	scheduleID, ok := scheduleIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if writeCheckInError(ctx, h.checkIns.Close(ctx, scopeOf(ctx), scheduleID)) {
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// checkIn takes the card_id of a student caller from the token, and any
+// other card only from an admin.
+func (h *HttpHandler) checkIn(ctx *fasthttp.RequestCtx) {
+	var req checkInRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil || req.ScheduleID == 0 || strings.TrimSpace(req.Code) == "" {
+		writeError(ctx, "body must be a JSON object with 'schedule_id' and 'code'", fasthttp.StatusBadRequest)
+		return
+	}
+
+	scope := scopeOf(ctx)
+	if req.CardID == "" {
+		req.CardID = scope.CardID
+	}
+	if req.CardID == "" {
+		writeError(ctx, "card_id", fasthttp.StatusBadRequest)
+		return
+	}
+	if scope.CardID != "" && req.CardID != scope.CardID {
+		writeError(ctx, accounting.ErrOutOfScope.Error(), fasthttp.StatusForbidden)
+		return
+	}
+
+	resp, err := h.checkIns.CheckIn(ctx, req.ScheduleID, req.CardID, strings.TrimSpace(req.Code))
+	if writeCheckInError(ctx, err) {
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusCreated)
+}
+
+func scheduleIDParam(ctx *fasthttp.RequestCtx) (int64, bool) {
+	id, err := strconv.ParseInt(pathParam(ctx, "schedule_id"), 10, 64)
+	if err != nil {
+		writeError(ctx, "schedule id must be a number", fasthttp.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeCheckInError writes the response for a non-nil err and reports
+// whether it did.
+func writeCheckInError(ctx *fasthttp.RequestCtx, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, accounting.ErrScheduleNotFound), errors.Is(err, checkin.ErrNoSession):
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+	case errors.Is(err, accounting.ErrOutOfScope), errors.Is(err, accounting.ErrNotEnrolled):
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+	case errors.Is(err, checkin.ErrNotToday), errors.Is(err, checkin.ErrAlreadyCheckedIn):
+		writeError(ctx, err.Error(), fasthttp.StatusConflict)
+	case errors.Is(err, checkin.ErrInvalidCode):
+		writeError(ctx, err.Error(), fasthttp.StatusBadRequest)
+	case errors.Is(err, checkin.ErrTooManyAttempts):
+		writeError(ctx, err.Error(), fasthttp.StatusTooManyRequests)
+	default:
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package endpoint
+
+import (
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/pkg/cast"
+	"github.com/valyala/fasthttp"
+)
+
+// checkConsistency runs the cross-store checks listed in the checks argument,
+// all of them by default. GET only reports; POST also repairs what it can.
+func (h *HttpHandler) checkConsistency(ctx *fasthttp.RequestCtx) {
+	checks, err := accounting.ParseConsistencyChecks(string(ctx.QueryArgs().Peek("checks")))
+	if err != nil {
+		writeError(ctx, "'checks': "+err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+	repair := cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodPost
+
+	report, err := h.accountingClient.CheckConsistency(ctx, checks, repair)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	writeObject(ctx, report, fasthttp.StatusOK)
+}
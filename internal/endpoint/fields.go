@@ -0,0 +1,17 @@
+package endpoint
+
+import (
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/valyala/fasthttp"
+)
+
+// fieldsArg parses the fields query argument of a report and writes a 400
+// response when it names unknown fields.
+func fieldsArg(ctx *fasthttp.RequestCtx, reportType string) (accounting.FieldSet, bool) {
+	fields, err := accounting.ParseReportFields(reportType, string(ctx.QueryArgs().Peek("fields")))
+	if err != nil {
+		writeError(ctx, "'fields': "+err.Error(), fasthttp.StatusBadRequest)
+		return nil, false
+	}
+	return fields, true
+}
@@ -0,0 +1,47 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"github.com/AlanMute/university-accounting/internal/graph"
+	"github.com/AlanMute/university-accounting/pkg/cast"
+	"github.com/valyala/fasthttp"
+)
+
+// serveGraphQL accepts a JSON body on POST and the query, variables and
+// operationName arguments on GET. Like the reports, results are limited to
+// the caller's scope and student fields are redacted with their policy.
+func (h *HttpHandler) serveGraphQL(ctx *fasthttp.RequestCtx) {
+	var req graph.Request
+	if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodPost {
+		if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+			writeError(ctx, "invalid request body", fasthttp.StatusBadRequest)
+			return
+		}
+	} else {
+		args := ctx.QueryArgs()
+		req.Query = string(args.Peek("query"))
+		req.OperationName = string(args.Peek("operationName"))
+		if variables := args.Peek("variables"); len(variables) > 0 {
+			if err := json.Unmarshal(variables, &req.Variables); err != nil {
+				writeError(ctx, "'variables' must be a JSON object", fasthttp.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.Query == "" {
+		writeError(ctx, "'query' is required", fasthttp.StatusBadRequest)
+		return
+	}
+
+	policy := h.redactor.PolicyFor(callerRole(ctx))
+	result := h.graph.Execute(ctx, req, scopeOf(ctx), policy)
+
+	// A request that failed before execution, on parsing, validation or the
+	// limits, has no data at all; field errors come back with partial data.
+	status := fasthttp.StatusOK
+	if result.Data == nil && result.HasErrors() {
+		status = fasthttp.StatusBadRequest
+	}
+	ctx.Response.Header.Set(redactionPolicyHeader, policy)
+	writeObject(ctx, result, status)
+}
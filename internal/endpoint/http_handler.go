@@ -1,10 +1,24 @@
 package endpoint
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/AlanMute/university-accounting/internal/checkin"
+	"github.com/AlanMute/university-accounting/internal/graph"
+	"github.com/AlanMute/university-accounting/internal/notify"
+	"github.com/AlanMute/university-accounting/internal/ratelimit"
+	"github.com/AlanMute/university-accounting/internal/redact"
+	"github.com/AlanMute/university-accounting/internal/reportjob"
+	"github.com/AlanMute/university-accounting/internal/scheduler"
 	"github.com/AlanMute/university-accounting/pkg/cast"
 	"github.com/sirupsen/logrus"
 	"github.com/valyala/fasthttp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,20 +26,36 @@ func init() {
 	for path, info := range routingMap {
 		info.path = path
 		routingMap[path] = info
+		if strings.Contains(path, "{") {
+			patternRoutes = append(patternRoutes, info)
+		}
 	}
+	sort.Slice(patternRoutes, func(i, j int) bool { return patternRoutes[i].path < patternRoutes[j].path })
 }
 
+// patternRoutes are the routes with {name} segments, tried in order when no
+// route matches the path exactly.
+var patternRoutes []route
+
 type route struct {
 	handler func(ctx *fasthttp.RequestCtx, h *HttpHandler)
 	path    string
+	// public routes are served without authentication.
+	public bool
+	// roles may call the route besides admin, who may call every route.
+	roles []string
+	// rateClass selects the rate limit bucket, the default one when empty.
+	rateClass string
 }
 
+var everyRole = []string{auth.RoleDepartmentHead, auth.RoleCurator, auth.RoleTeacher, auth.RoleStudent}
+
 var routingMap = map[string]route{
-	"/status": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+	"/status": {public: true, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
 		_, _ = ctx.WriteString("OK")
 	}},
 
-	"/api/v1/attendance-report": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab1
+	"/api/v1/attendance-report": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab1
 		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
 			h.generateAttendanceReport(ctx)
 		} else {
@@ -33,7 +63,7 @@ var routingMap = map[string]route{
 		}
 	}},
 
-	"/api/v1/course-report": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab2
+	"/api/v1/course-report": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab2
 		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
 			h.generateCourseReport(ctx)
 		} else {
@@ -41,7 +71,7 @@ var routingMap = map[string]route{
 		}
 	}},
 
-	"/api/v1/group-report": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab3
+	"/api/v1/group-report": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab3
 		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
 			h.generateGroupReport(ctx)
 		} else {
@@ -49,7 +79,253 @@ var routingMap = map[string]route{
 		}
 	}},
 
-	"/api/v1/groups": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab3
+	"/api/v1/discipline-report": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.generateDisciplineReport(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/workload-report": {roles: []string{auth.RoleDepartmentHead, auth.RoleTeacher}, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.generateWorkloadReport(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/attendance/trend": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.generateAttendanceTrend(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/at-risk": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.findAtRiskStudents(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/notifications/low-attendance": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodPost {
+			h.notifyLowAttendance(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/scheduler/jobs": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.getScheduledJobs(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/scheduler/result": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.getScheduledJobResult(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/report-jobs": {roles: everyRole, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodPost:
+			// A job costs as much as a synchronous report, polling it does
+			// not.
+			if !h.rateLimit(ctx, ratelimit.ClassReport) {
+				return
+			}
+			h.submitReportJob(ctx)
+		case fasthttp.MethodGet:
+			h.getReportJob(ctx)
+		case fasthttp.MethodDelete:
+			h.cancelReportJob(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/snapshots": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.listSnapshots(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/snapshot": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet:
+			h.getSnapshot(ctx)
+		case fasthttp.MethodDelete:
+			h.deleteSnapshot(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/api-keys": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodPost:
+			h.createAPIKey(ctx)
+		case fasthttp.MethodGet:
+			h.listAPIKeys(ctx)
+		case fasthttp.MethodDelete:
+			h.revokeAPIKey(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/admin/consistency": {rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet, fasthttp.MethodPost:
+			h.checkConsistency(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/prerequisites": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet:
+			h.listPrerequisites(ctx)
+		case fasthttp.MethodPost:
+			h.addPrerequisite(ctx)
+		case fasthttp.MethodDelete:
+			h.removePrerequisite(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/prerequisites/cycles": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.findPrerequisiteCycles(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/prerequisites/check": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.checkStudentPrerequisites(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/curriculum": {roles: everyRole, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.getCurriculum(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/students/{card_id}": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.getStudentDossier(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/students/{card_id}/missed": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
+			h.getMissedLessons(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/teachers": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet:
+			h.listTeachers(ctx)
+		case fasthttp.MethodPost:
+			h.createTeacher(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/teachers/{teacher_id}": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet:
+			h.getTeacher(ctx)
+		case fasthttp.MethodPut:
+			h.updateTeacher(ctx)
+		case fasthttp.MethodDelete:
+			h.deleteTeacher(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/lessons/{lesson_id}/teacher": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodPut, fasthttp.MethodDelete:
+			h.assignLessonTeacher(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/schedule/{schedule_id}/teacher": {handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodPut, fasthttp.MethodDelete:
+			h.assignScheduleTeacher(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/checkin/sessions": {roles: []string{auth.RoleDepartmentHead, auth.RoleTeacher}, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodPost {
+			h.openCheckInSession(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/checkin/sessions/{schedule_id}": {roles: []string{auth.RoleDepartmentHead, auth.RoleTeacher}, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet:
+			h.getCheckInCode(ctx)
+		case fasthttp.MethodDelete:
+			h.closeCheckInSession(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/checkin": {roles: []string{auth.RoleStudent}, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodPost {
+			h.checkIn(ctx)
+		} else {
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/graphql": {roles: everyRole, rateClass: ratelimit.ClassReport, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) {
+		switch cast.ByteArrayToString(ctx.Method()) {
+		case fasthttp.MethodGet, fasthttp.MethodPost:
+			h.serveGraphQL(ctx)
+		default:
+			ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		}
+	}},
+
+	"/api/v1/groups": {roles: everyRole, handler: func(ctx *fasthttp.RequestCtx, h *HttpHandler) { //Lab3
 		if cast.ByteArrayToString(ctx.Method()) == fasthttp.MethodGet {
 			h.getGroups(ctx)
 		} else {
@@ -60,11 +336,29 @@ var routingMap = map[string]route{
 
 type HttpHandler struct {
 	accountingClient *accounting.Client
+	notifier         *notify.Notifier
+	scheduler        *scheduler.Scheduler
+	reportJobs       *reportjob.Manager
+	authenticator    *auth.Authenticator
+	apiKeys          *auth.KeyStore
+	limiter          *ratelimit.Limiter
+	redactor         *redact.Redactor
+	graph            *graph.Service
+	checkIns         *checkin.Manager
 }
 
-func NewHttpHandler(accountingClient *accounting.Client) *HttpHandler {
+func NewHttpHandler(accountingClient *accounting.Client, notifier *notify.Notifier, jobScheduler *scheduler.Scheduler, reportJobs *reportjob.Manager, authenticator *auth.Authenticator, apiKeys *auth.KeyStore, limiter *ratelimit.Limiter, redactor *redact.Redactor, graphService *graph.Service, checkIns *checkin.Manager) *HttpHandler {
 	h := &HttpHandler{
 		accountingClient: accountingClient,
+		notifier:         notifier,
+		scheduler:        jobScheduler,
+		reportJobs:       reportJobs,
+		authenticator:    authenticator,
+		apiKeys:          apiKeys,
+		limiter:          limiter,
+		redactor:         redactor,
+		graph:            graphService,
+		checkIns:         checkIns,
 	}
 
 	return h
@@ -79,7 +373,20 @@ func (h *HttpHandler) Handle(ctx *fasthttp.RequestCtx) {
 		}
 	}()
 
-	if r, ok := routingMap[cast.ByteArrayToString(ctx.Path())]; ok {
+	r, ok := routingMap[cast.ByteArrayToString(ctx.Path())]
+	if !ok {
+		r, ok = matchPatternRoute(ctx)
+	}
+	if ok {
+		if !h.rateLimitIP(ctx) {
+			return
+		}
+		if !r.public && (!h.authenticate(ctx) || !authorize(ctx, r.roles)) {
+			return
+		}
+		if !h.rateLimit(ctx, r.rateClass) {
+			return
+		}
 		r.handler(ctx, h)
 	} else {
 		ctx.SetStatusCode(fasthttp.StatusNotFound)
@@ -116,13 +423,38 @@ func (h *HttpHandler) generateAttendanceReport(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	resp, err := h.accountingClient.GenerateAttendanceReport(term, startDate, endDate)
+	var searchFields []string
+	if searchFieldsByte := ctx.QueryArgs().Peek("searchFields"); len(searchFieldsByte) > 0 {
+		searchFields = strings.Split(cast.ByteArrayToString(searchFieldsByte), ",")
+	}
+
+	var minScore float64
+	if ctx.QueryArgs().Has("minScore") {
+		var err error
+		if minScore, err = ctx.QueryArgs().GetUfloat("minScore"); err != nil {
+			writeError(ctx, "'minScore' must be a non-negative number", fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	search, err := accounting.NewTermSearch(term, searchFields, minScore)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+
+	fields, ok := fieldsArg(ctx, accounting.ReportAttendance)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateAttendanceReport(scopeOf(ctx), fields, search, startDate, endDate)
 	if err != nil {
 		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
 		return
 	}
 
-	writeObject(ctx, resp, fasthttp.StatusOK)
+	h.writeReport(ctx, accounting.ReportAttendance, resp)
 }
 
 func (h *HttpHandler) generateCourseReport(ctx *fasthttp.RequestCtx) {
@@ -138,13 +470,18 @@ func (h *HttpHandler) generateCourseReport(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
-	resp, err := h.accountingClient.GenerateCourseReport(year, semester)
+	fields, ok := fieldsArg(ctx, accounting.ReportCourse)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateCourseReport(scopeOf(ctx), fields, year, semester)
 	if err != nil {
 		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
 		return
 	}
 
-	writeObject(ctx, resp, fasthttp.StatusOK)
+	h.writeReport(ctx, accounting.ReportCourse, resp)
 }
 
 func (h *HttpHandler) generateGroupReport(ctx *fasthttp.RequestCtx) {
@@ -155,7 +492,176 @@ func (h *HttpHandler) generateGroupReport(ctx *fasthttp.RequestCtx) {
 	}
 	group := cast.ByteArrayToString(groupByte)
 
-	resp, err := h.accountingClient.GenerateGroupReport(group)
+	fields, ok := fieldsArg(ctx, accounting.ReportGroup)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateGroupReport(scopeOf(ctx), fields, group)
+	if errors.Is(err, accounting.ErrOutOfScope) {
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeReport(ctx, accounting.ReportGroup, resp)
+}
+
+func (h *HttpHandler) generateAttendanceTrend(ctx *fasthttp.RequestCtx) {
+	scopeByte := ctx.QueryArgs().Peek("scope")
+	if scopeByte == nil {
+		writeError(ctx, "scope", fasthttp.StatusBadRequest)
+		return
+	}
+	scope := cast.ByteArrayToString(scopeByte)
+
+	idByte := ctx.QueryArgs().Peek("id")
+	if idByte == nil {
+		writeError(ctx, "id", fasthttp.StatusBadRequest)
+		return
+	}
+	id := cast.ByteArrayToString(idByte)
+
+	bucket := accounting.TrendBucketWeek
+	if bucketByte := ctx.QueryArgs().Peek("bucket"); bucketByte != nil {
+		bucket = cast.ByteArrayToString(bucketByte)
+	}
+
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	var movingAvg int
+	if ctx.QueryArgs().Has("movingAvg") {
+		var err error
+		if movingAvg, err = ctx.QueryArgs().GetUint("movingAvg"); err != nil {
+			writeError(ctx, "'movingAvg' must be a non-negative integer", fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	switch scope {
+	case accounting.TrendScopeStudent, accounting.TrendScopeGroup, accounting.TrendScopeDiscipline, accounting.TrendScopeDepartment:
+	default:
+		writeError(ctx, "'scope' must be one of student, group, discipline, department", fasthttp.StatusBadRequest)
+		return
+	}
+	if _, err := strconv.Atoi(id); scope == accounting.TrendScopeDiscipline && err != nil {
+		writeError(ctx, "'id' must be a discipline number", fasthttp.StatusBadRequest)
+		return
+	}
+	switch bucket {
+	case accounting.TrendBucketDay, accounting.TrendBucketWeek, accounting.TrendBucketMonth:
+	default:
+		writeError(ctx, "'bucket' must be one of day, week, month", fasthttp.StatusBadRequest)
+		return
+	}
+	if _, ok := fieldsArg(ctx, accounting.ReportTrend); !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateAttendanceTrend(scopeOf(ctx), scope, id, bucket, startDate, endDate, movingAvg)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeReport(ctx, accounting.ReportTrend, resp)
+}
+
+func (h *HttpHandler) generateDisciplineReport(ctx *fasthttp.RequestCtx) {
+	disciplineID, err := ctx.QueryArgs().GetUint("discipline")
+	if err != nil {
+		writeError(ctx, "'discipline' must be a discipline number", fasthttp.StatusBadRequest)
+		return
+	}
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	if _, ok := fieldsArg(ctx, accounting.ReportDiscipline); !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateDisciplineReport(ctx, scopeOf(ctx), disciplineID, startDate, endDate)
+	if errors.Is(err, accounting.ErrDisciplineNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeReport(ctx, accounting.ReportDiscipline, resp)
+}
+
+func (h *HttpHandler) findAtRiskStudents(ctx *fasthttp.RequestCtx) {
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	if _, ok := fieldsArg(ctx, accounting.ReportAtRisk); !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.FindAtRiskStudents(scopeOf(ctx), startDate, endDate)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeReport(ctx, accounting.ReportAtRisk, resp)
+}
+
+func (h *HttpHandler) notifyLowAttendance(ctx *fasthttp.RequestCtx) {
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	locale := string(ctx.QueryArgs().Peek("locale"))
+	switch locale {
+	case "", notify.LocaleRu, notify.LocaleEn:
+	default:
+		writeError(ctx, "'locale' must be ru or en", fasthttp.StatusBadRequest)
+		return
+	}
+	dryRun := ctx.QueryArgs().GetBool("dryRun")
+
+	resp, err := h.notifier.NotifyLowAttendance(startDate, endDate, locale, dryRun)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeRedacted(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) getScheduledJobs(ctx *fasthttp.RequestCtx) {
+	resp, err := h.scheduler.Jobs()
 	if err != nil {
 		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
 		return
@@ -164,14 +670,95 @@ func (h *HttpHandler) generateGroupReport(ctx *fasthttp.RequestCtx) {
 	writeObject(ctx, resp, fasthttp.StatusOK)
 }
 
+func (h *HttpHandler) getScheduledJobResult(ctx *fasthttp.RequestCtx) {
+	nameByte := ctx.QueryArgs().Peek("name")
+	if nameByte == nil {
+		writeError(ctx, "name", fasthttp.StatusBadRequest)
+		return
+	}
+
+	result, err := h.scheduler.LastResult(string(nameByte))
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	if result == nil {
+		writeError(ctx, "job has no result yet", fasthttp.StatusNotFound)
+		return
+	}
+
+	h.writeRedacted(ctx, json.RawMessage(result), fasthttp.StatusOK)
+}
+
 func (h *HttpHandler) getGroups(ctx *fasthttp.RequestCtx) {
-	resp, err := h.accountingClient.GetAllGroups()
+	if _, ok := fieldsArg(ctx, accounting.ReportGroupList); !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GetAllGroups(scopeOf(ctx))
 	if err != nil {
 		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
 		return
 	}
 
-	writeObject(ctx, resp, fasthttp.StatusOK)
+	h.writeReport(ctx, accounting.ReportGroupList, resp)
+}
+
+// matchPatternRoute finds the pattern route for the path and stores the values
+// of its {name} segments for pathParam.
+func matchPatternRoute(ctx *fasthttp.RequestCtx) (route, bool) {
+	segments := strings.Split(cast.ByteArrayToString(ctx.Path()), "/")
+	for _, r := range patternRoutes {
+		pattern := strings.Split(r.path, "/")
+		if len(pattern) != len(segments) {
+			continue
+		}
+		params := make(map[string]string)
+		for i, part := range pattern {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") && segments[i] != "" {
+				params[part[1:len(part)-1]] = segments[i]
+			} else if part != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params == nil {
+			continue
+		}
+		for name, value := range params {
+			ctx.SetUserValue(pathParamKey+name, value)
+		}
+		return r, true
+	}
+	return route{}, false
+}
+
+const pathParamKey = "path:"
+
+// pathParam returns the value of a {name} segment of the matched route.
+func pathParam(ctx *fasthttp.RequestCtx, name string) string {
+	value, _ := ctx.UserValue(pathParamKey + name).(string)
+	return value
+}
+
+// requiredDateArg reads a YYYY-MM-DD query argument and writes a 400
+// response when it is missing or malformed.
+func requiredDateArg(ctx *fasthttp.RequestCtx, name string) (string, bool) {
+	dateByte := ctx.QueryArgs().Peek(name)
+	if dateByte == nil {
+		writeError(ctx, name, fasthttp.StatusBadRequest)
+		return "", false
+	}
+	date := string(dateByte)
+	if !isValidDate(date) {
+		writeError(ctx, fmt.Sprintf("'%s' must be in the format YYYY-MM-DD", name), fasthttp.StatusBadRequest)
+		return "", false
+	}
+	return date, true
 }
 
 func isValidDate(dateStr string) bool {
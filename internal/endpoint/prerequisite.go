@@ -0,0 +1,146 @@
+package endpoint
+
+import (
+	"errors"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/valyala/fasthttp"
+)
+
+type cyclesResponse struct {
+	Error  string  `json:"error,omitempty"`
+	Cycles [][]int `json:"cycles"`
+}
+
+func (h *HttpHandler) listPrerequisites(ctx *fasthttp.RequestCtx) {
+	resp, err := h.accountingClient.ListPrerequisites(ctx)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) addPrerequisite(ctx *fasthttp.RequestCtx) {
+	discipline, requires, ok := prerequisiteArgs(ctx)
+	if !ok {
+		return
+	}
+
+	err := h.accountingClient.AddPrerequisite(ctx, discipline, requires)
+	if writeCycleConflict(ctx, err) {
+		return
+	}
+	if errors.Is(err, accounting.ErrDisciplineNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, accounting.Prerequisite{DisciplineID: discipline, RequiresID: requires}, fasthttp.StatusCreated)
+}
+
+func (h *HttpHandler) removePrerequisite(ctx *fasthttp.RequestCtx) {
+	discipline, requires, ok := prerequisiteArgs(ctx)
+	if !ok {
+		return
+	}
+
+	err := h.accountingClient.RemovePrerequisite(ctx, discipline, requires)
+	if errors.Is(err, accounting.ErrPrerequisiteNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+func (h *HttpHandler) findPrerequisiteCycles(ctx *fasthttp.RequestCtx) {
+	cycles, err := h.accountingClient.PrerequisiteCycles(ctx)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, cyclesResponse{Cycles: cycles}, fasthttp.StatusOK)
+}
+
+// getCurriculum answers 409 with the cycles when the prerequisites have no
+// order.
+func (h *HttpHandler) getCurriculum(ctx *fasthttp.RequestCtx) {
+	resp, err := h.accountingClient.GetCurriculum(ctx)
+	if writeCycleConflict(ctx, err) {
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) checkStudentPrerequisites(ctx *fasthttp.RequestCtx) {
+	studentByte := ctx.QueryArgs().Peek("student")
+	if len(studentByte) == 0 {
+		writeError(ctx, "student", fasthttp.StatusBadRequest)
+		return
+	}
+
+	minPercent := float64(accounting.DefaultPrerequisiteMinPercent)
+	if ctx.QueryArgs().Has("minPercent") {
+		var err error
+		if minPercent, err = ctx.QueryArgs().GetUfloat("minPercent"); err != nil || minPercent > 100 {
+			writeError(ctx, "'minPercent' must be a number from 0 to 100", fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := h.accountingClient.CheckStudentPrerequisites(ctx, scopeOf(ctx), string(studentByte), minPercent)
+	if errors.Is(err, accounting.ErrStudentNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if errors.Is(err, accounting.ErrOutOfScope) {
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeRedacted(ctx, resp, fasthttp.StatusOK)
+}
+
+func prerequisiteArgs(ctx *fasthttp.RequestCtx) (int, int, bool) {
+	discipline, err := ctx.QueryArgs().GetUint("discipline")
+	if err != nil {
+		writeError(ctx, "'discipline' must be a discipline number", fasthttp.StatusBadRequest)
+		return 0, 0, false
+	}
+	requires, err := ctx.QueryArgs().GetUint("requires")
+	if err != nil {
+		writeError(ctx, "'requires' must be a discipline number", fasthttp.StatusBadRequest)
+		return 0, 0, false
+	}
+	return discipline, requires, true
+}
+
+// writeCycleConflict answers 409 with the cycles when err is a
+// PrerequisiteCycleError.
+func writeCycleConflict(ctx *fasthttp.RequestCtx, err error) bool {
+	var cycleErr *accounting.PrerequisiteCycleError
+	if !errors.As(err, &cycleErr) {
+		return false
+	}
+	writeObject(ctx, cyclesResponse{Error: err.Error(), Cycles: cycleErr.Cycles}, fasthttp.StatusConflict)
+	return true
+}
@@ -0,0 +1,61 @@
+package endpoint
+
+import (
+	"github.com/AlanMute/university-accounting/internal/ratelimit"
+	"github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"math"
+	"strconv"
+	"time"
+)
+
+// rateLimit takes a token for the caller from the route class bucket and sets
+// the RateLimit-* headers. It writes 429 and returns false when the bucket is
+// empty. Callers are told apart by principal, or by IP when authentication is
+// disabled or the route is public.
+func (h *HttpHandler) rateLimit(ctx *fasthttp.RequestCtx, class string) bool {
+	if class == "" {
+		class = ratelimit.ClassDefault
+	}
+	client := "ip:" + ctx.RemoteIP().String()
+	if p := principal(ctx); p != nil {
+		client = "sub:" + p.Subject
+	}
+	return h.take(ctx, class, client)
+}
+
+// rateLimitIP takes a token from the IP bucket before the request is
+// authenticated.
+func (h *HttpHandler) rateLimitIP(ctx *fasthttp.RequestCtx) bool {
+	return h.take(ctx, ratelimit.ClassIP, "ip:"+ctx.RemoteIP().String())
+}
+
+func (h *HttpHandler) take(ctx *fasthttp.RequestCtx, class, client string) bool {
+	if !h.limiter.Enabled() {
+		return true
+	}
+
+	result, err := h.limiter.Allow(ctx, class, client)
+	if err != nil {
+		// Redis trouble should not take the API down with it.
+		logrus.Errorf("Rate limiter failed, letting the request through: %v", err)
+		return true
+	}
+
+	header := &ctx.Response.Header
+	header.Set("RateLimit-Policy", strconv.Itoa(result.Limit.Requests)+";w="+strconv.Itoa(result.Limit.PeriodSec))
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit.Requests))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if result.Allowed {
+		return true
+	}
+
+	header.Set(fasthttp.HeaderRetryAfter, strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	writeError(ctx, "rate limit exceeded", fasthttp.StatusTooManyRequests)
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,102 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/AlanMute/university-accounting/internal/reportjob"
+	"github.com/valyala/fasthttp"
+)
+
+type reportJobRequest struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params"`
+}
+
+func (h *HttpHandler) submitReportJob(ctx *fasthttp.RequestCtx) {
+	var req reportJobRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		writeError(ctx, "body must be a JSON object with 'type' and 'params'", fasthttp.StatusBadRequest)
+		return
+	}
+	if req.Params == nil {
+		req.Params = make(map[string]string)
+	}
+	if err := accounting.ValidateReportParams(req.Type, req.Params); err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+
+	var owner string
+	if p := principal(ctx); p != nil {
+		owner = p.Subject
+	}
+
+	job, err := h.reportJobs.Submit(owner, scopeOf(ctx), req.Type, req.Params)
+	if errors.Is(err, reportjob.ErrQueueFull) {
+		writeError(ctx, err.Error(), fasthttp.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, job, fasthttp.StatusAccepted)
+}
+
+func (h *HttpHandler) getReportJob(ctx *fasthttp.RequestCtx) {
+	idByte := ctx.QueryArgs().Peek("id")
+	if idByte == nil {
+		writeError(ctx, "id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	job, err := h.reportJobs.Get(string(idByte))
+	if err == nil && !ownsJob(ctx, job) {
+		err = reportjob.ErrJobNotFound
+	}
+	if errors.Is(err, reportjob.ErrJobNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeRedacted(ctx, job, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) cancelReportJob(ctx *fasthttp.RequestCtx) {
+	idByte := ctx.QueryArgs().Peek("id")
+	if idByte == nil {
+		writeError(ctx, "id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	job, err := h.reportJobs.Get(string(idByte))
+	if err == nil && !ownsJob(ctx, job) {
+		err = reportjob.ErrJobNotFound
+	}
+	if err == nil {
+		job, err = h.reportJobs.Cancel(job.ID)
+	}
+	switch {
+	case errors.Is(err, reportjob.ErrJobNotFound):
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+	case errors.Is(err, reportjob.ErrJobFinished):
+		writeError(ctx, err.Error(), fasthttp.StatusConflict)
+	case err != nil:
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+	default:
+		h.writeRedacted(ctx, job, fasthttp.StatusAccepted)
+	}
+}
+
+// ownsJob hides jobs of other callers. Admins see every job.
+func ownsJob(ctx *fasthttp.RequestCtx, job *reportjob.Job) bool {
+	p := principal(ctx)
+	return p == nil || p.Role == auth.RoleAdmin || p.Subject == job.Owner
+}
@@ -0,0 +1,118 @@
+package endpoint
+
+import (
+	"errors"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	snapshotIDHeader     = "X-Snapshot-Id"
+	defaultSnapshotLimit = 50
+)
+
+// writeReport writes a generated report projected to the fields argument and
+// with the caller's redaction policy applied. When the request has
+// snapshot=true, the projected report is archived first, without redaction,
+// which only admins may do. The snapshot ID is returned in the X-Snapshot-Id
+// header so the response body keeps its shape.
+func (h *HttpHandler) writeReport(ctx *fasthttp.RequestCtx, reportType string, report any) {
+	fields, ok := fieldsArg(ctx, reportType)
+	if !ok {
+		return
+	}
+	report, err := fields.Project(report)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	if ctx.QueryArgs().GetBool("snapshot") {
+		owner := accounting.SnapshotOwner{Scope: scopeOf(ctx)}
+		if p := principal(ctx); p != nil {
+			if p.Role != auth.RoleAdmin {
+				writeError(ctx, "only admin may archive snapshots", fasthttp.StatusForbidden)
+				return
+			}
+			owner.Subject, owner.Role = p.Subject, p.Role
+		}
+
+		params := make(map[string]string)
+		ctx.QueryArgs().VisitAll(func(key, value []byte) {
+			if name := string(key); name != "snapshot" {
+				params[name] = string(value)
+			}
+		})
+
+		snapshot, err := h.accountingClient.SaveSnapshot(reportType, params, "api", owner, report)
+		if err != nil {
+			writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		ctx.Response.Header.Set(snapshotIDHeader, snapshot.ID.Hex())
+	}
+
+	h.writeRedacted(ctx, report, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) listSnapshots(ctx *fasthttp.RequestCtx) {
+	reportType := string(ctx.QueryArgs().Peek("type"))
+
+	limit := defaultSnapshotLimit
+	if ctx.QueryArgs().Has("limit") {
+		var err error
+		if limit, err = ctx.QueryArgs().GetUint("limit"); err != nil || limit == 0 {
+			writeError(ctx, "'limit' must be a positive integer", fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := h.accountingClient.ListSnapshots(reportType, int64(limit))
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) getSnapshot(ctx *fasthttp.RequestCtx) {
+	idByte := ctx.QueryArgs().Peek("id")
+	if idByte == nil {
+		writeError(ctx, "id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.accountingClient.GetSnapshot(string(idByte))
+	if errors.Is(err, accounting.ErrSnapshotNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeRedacted(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) deleteSnapshot(ctx *fasthttp.RequestCtx) {
+	idByte := ctx.QueryArgs().Peek("id")
+	if idByte == nil {
+		writeError(ctx, "id", fasthttp.StatusBadRequest)
+		return
+	}
+
+	err := h.accountingClient.DeleteSnapshot(string(idByte))
+	if errors.Is(err, accounting.ErrSnapshotNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package endpoint
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/valyala/fasthttp"
+	"strconv"
+)
+
+func (h *HttpHandler) getMissedLessons(ctx *fasthttp.RequestCtx) {
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	limit := accounting.DefaultCatchUpMaterials
+	if ctx.QueryArgs().Has("materials") {
+		var err error
+		if limit, err = ctx.QueryArgs().GetUint("materials"); err != nil || limit > accounting.MaxCatchUpMaterials {
+			writeError(ctx, "'materials' must be an integer from 0 to 50", fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, err := h.accountingClient.GetMissedLessons(ctx, scopeOf(ctx), pathParam(ctx, "card_id"), startDate, endDate, limit)
+	if errors.Is(err, accounting.ErrStudentNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if errors.Is(err, accounting.ErrOutOfScope) {
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	h.writeRedacted(ctx, resp, fasthttp.StatusOK)
+}
+
+// getStudentDossier answers with JSON, or with the lesson history as CSV
+// when format=csv.
+func (h *HttpHandler) getStudentDossier(ctx *fasthttp.RequestCtx) {
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	format := string(ctx.QueryArgs().Peek("format"))
+	switch format {
+	case "", "json", "csv":
+	default:
+		writeError(ctx, "'format' must be json or csv", fasthttp.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.accountingClient.GetStudentDossier(ctx, scopeOf(ctx), pathParam(ctx, "card_id"), startDate, endDate)
+	if errors.Is(err, accounting.ErrStudentNotFound) {
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+		return
+	}
+	if errors.Is(err, accounting.ErrOutOfScope) {
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		writeDossierCSV(ctx, resp)
+		return
+	}
+	h.writeRedacted(ctx, resp, fasthttp.StatusOK)
+}
+
+// writeDossierCSV writes a row per lesson with the discipline totals of the
+// period repeated on each row, so the file stands on its own in a
+// spreadsheet.
+func writeDossierCSV(ctx *fasthttp.RequestCtx, dossier *accounting.StudentDossier) {
+	disciplines := make(map[int]accounting.DossierDiscipline, len(dossier.Disciplines))
+	for _, discipline := range dossier.Disciplines {
+		disciplines[discipline.DisciplineID] = discipline
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.Header.Set(fasthttp.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="dossier-%s-%s-%s.csv"`, dossier.StudentID, dossier.StartDate, dossier.EndDate))
+
+	w := csv.NewWriter(ctx)
+	_ = w.Write([]string{"date", "discipline_id", "discipline", "is_special", "topic", "type", "attended", "discipline_attendance_rate"})
+	for _, lesson := range dossier.Lessons {
+		discipline := disciplines[lesson.DisciplineID]
+		_ = w.Write([]string{
+			lesson.Date,
+			strconv.Itoa(lesson.DisciplineID),
+			lesson.Discipline,
+			strconv.FormatBool(discipline.IsSpecial),
+			lesson.Topic,
+			lesson.Type,
+			strconv.FormatBool(lesson.Attended),
+			strconv.FormatFloat(discipline.AttendanceRate, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+}
@@ -0,0 +1,195 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/valyala/fasthttp"
+	"strconv"
+)
+
+func (h *HttpHandler) listTeachers(ctx *fasthttp.RequestCtx) {
+	resp, err := h.accountingClient.ListTeachers(ctx)
+	if err != nil {
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) getTeacher(ctx *fasthttp.RequestCtx) {
+	id, ok := teacherIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GetTeacher(ctx, id)
+	if writeTeacherError(ctx, err) {
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) createTeacher(ctx *fasthttp.RequestCtx) {
+	teacher, ok := teacherBody(ctx)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.CreateTeacher(ctx, teacher)
+	if writeTeacherError(ctx, err) {
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusCreated)
+}
+
+func (h *HttpHandler) updateTeacher(ctx *fasthttp.RequestCtx) {
+	id, ok := teacherIDParam(ctx)
+	if !ok {
+		return
+	}
+	teacher, ok := teacherBody(ctx)
+	if !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.UpdateTeacher(ctx, id, teacher)
+	if writeTeacherError(ctx, err) {
+		return
+	}
+
+	writeObject(ctx, resp, fasthttp.StatusOK)
+}
+
+func (h *HttpHandler) deleteTeacher(ctx *fasthttp.RequestCtx) {
+	id, ok := teacherIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if writeTeacherError(ctx, h.accountingClient.DeleteTeacher(ctx, id)) {
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// assignLessonTeacher sets the lesson's teacher from the teacher argument on
+// PUT and clears it on DELETE.
+func (h *HttpHandler) assignLessonTeacher(ctx *fasthttp.RequestCtx) {
+	lessonID, err := strconv.ParseInt(pathParam(ctx, "lesson_id"), 10, 64)
+	if err != nil {
+		writeError(ctx, "lesson id must be a number", fasthttp.StatusBadRequest)
+		return
+	}
+	teacherID, ok := assignedTeacherArg(ctx)
+	if !ok {
+		return
+	}
+
+	if writeTeacherError(ctx, h.accountingClient.AssignLessonTeacher(ctx, lessonID, teacherID)) {
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// assignScheduleTeacher overrides the teacher of one session on PUT and
+// falls back to the lesson's teacher on DELETE.
+func (h *HttpHandler) assignScheduleTeacher(ctx *fasthttp.RequestCtx) {
+	scheduleID, err := strconv.ParseInt(pathParam(ctx, "schedule_id"), 10, 64)
+	if err != nil {
+		writeError(ctx, "schedule id must be a number", fasthttp.StatusBadRequest)
+		return
+	}
+	teacherID, ok := assignedTeacherArg(ctx)
+	if !ok {
+		return
+	}
+
+	if writeTeacherError(ctx, h.accountingClient.AssignScheduleTeacher(ctx, scheduleID, teacherID)) {
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+func (h *HttpHandler) generateWorkloadReport(ctx *fasthttp.RequestCtx) {
+	teacherID, err := ctx.QueryArgs().GetUint("teacher")
+	if err != nil {
+		writeError(ctx, "'teacher' must be a teacher id", fasthttp.StatusBadRequest)
+		return
+	}
+	startDate, ok := requiredDateArg(ctx, "startDate")
+	if !ok {
+		return
+	}
+	endDate, ok := requiredDateArg(ctx, "endDate")
+	if !ok {
+		return
+	}
+
+	if _, ok := fieldsArg(ctx, accounting.ReportWorkload); !ok {
+		return
+	}
+
+	resp, err := h.accountingClient.GenerateWorkloadReport(ctx, scopeOf(ctx), teacherID, startDate, endDate)
+	if writeTeacherError(ctx, err) {
+		return
+	}
+
+	h.writeReport(ctx, accounting.ReportWorkload, resp)
+}
+
+func teacherIDParam(ctx *fasthttp.RequestCtx) (int, bool) {
+	id, err := strconv.Atoi(pathParam(ctx, "teacher_id"))
+	if err != nil {
+		writeError(ctx, "teacher id must be a number", fasthttp.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func teacherBody(ctx *fasthttp.RequestCtx) (accounting.Teacher, bool) {
+	var teacher accounting.Teacher
+	if err := json.Unmarshal(ctx.PostBody(), &teacher); err != nil {
+		writeError(ctx, "body must be a JSON object with 'name'", fasthttp.StatusBadRequest)
+		return teacher, false
+	}
+	return teacher, true
+}
+
+// assignedTeacherArg is the teacher argument of a PUT, and 0 for a DELETE.
+func assignedTeacherArg(ctx *fasthttp.RequestCtx) (int, bool) {
+	if ctx.IsDelete() {
+		return 0, true
+	}
+	teacherID, err := ctx.QueryArgs().GetUint("teacher")
+	if err != nil || teacherID == 0 {
+		writeError(ctx, "'teacher' must be a teacher id", fasthttp.StatusBadRequest)
+		return 0, false
+	}
+	return teacherID, true
+}
+
+// writeTeacherError writes the response for a non-nil err and reports
+// whether it did.
+func writeTeacherError(ctx *fasthttp.RequestCtx, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, accounting.ErrTeacherNotFound), errors.Is(err, accounting.ErrLessonNotFound),
+		errors.Is(err, accounting.ErrScheduleNotFound):
+		writeError(ctx, err.Error(), fasthttp.StatusNotFound)
+	case errors.Is(err, accounting.ErrInvalidTeacher):
+		writeError(ctx, err.Error(), fasthttp.StatusBadRequest)
+	case errors.Is(err, accounting.ErrOutOfScope):
+		writeError(ctx, err.Error(), fasthttp.StatusForbidden)
+	default:
+		writeError(ctx, err.Error(), fasthttp.StatusInternalServerError)
+	}
+	return true
+}
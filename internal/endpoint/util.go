@@ -33,3 +33,9 @@ func writeObject(ctx *fasthttp.RequestCtx, obj any, status int) {
 	ctx.Response.Header.Add(fasthttp.HeaderContentType, "application/json")
 	_, _ = ctx.Write(raw)
 }
+
+func writeRaw(ctx *fasthttp.RequestCtx, raw []byte, status int) {
+	ctx.SetStatusCode(status)
+	ctx.Response.Header.Add(fasthttp.HeaderContentType, "application/json")
+	_, _ = ctx.Write(raw)
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/redact"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Config struct {
+	MaxDepth      int `json:"max_depth"`
+	MaxComplexity int `json:"max_complexity"`
+	// ListFactor is the assumed length of every list when estimating a
+	// query's complexity.
+	ListFactor int `json:"list_factor"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxDepth:      8,
+		MaxComplexity: 2000,
+		ListFactor:    10,
+	}
+}
+
+func ValidateConfig(cfg Config) error {
+	if cfg.MaxDepth <= 0 || cfg.MaxComplexity <= 0 {
+		return fmt.Errorf("max_depth and max_complexity must be positive")
+	}
+	if cfg.ListFactor < 1 {
+		return fmt.Errorf("list_factor must be at least 1")
+	}
+	return nil
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+type Service struct {
+	acc      *accounting.Client
+	redactor *redact.Redactor
+	cfg      Config
+	schema   graphql.Schema
+}
+
+func NewService(acc *accounting.Client, redactor *redact.Redactor, cfg Config) (*Service, error) {
+	schema, err := newSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graphql schema: %v", err)
+	}
+	return &Service{acc: acc, redactor: redactor, cfg: cfg, schema: schema}, nil
+}
+
+// Execute runs the request within the caller's scope, redacting student
+// fields with the given policy. Queries over the depth or complexity limits
+// are rejected before anything is fetched.
+func (s *Service) Execute(ctx context.Context, req Request, scope accounting.Scope, policy string) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	c, err := queryCost(s.schema, doc, req.OperationName, s.cfg.ListFactor)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if c.depth > s.cfg.MaxDepth {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(fmt.Errorf("query depth %d exceeds the limit of %d", c.depth, s.cfg.MaxDepth))}
+	}
+	if c.complexity > s.cfg.MaxComplexity {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(fmt.Errorf("query complexity %d exceeds the limit of %d", c.complexity, s.cfg.MaxComplexity))}
+	}
+
+	l := newLoaders(ctx, s.acc, scope, s.redactor, policy)
+	return graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(ctx, contextKey{}, l),
+	})
+}
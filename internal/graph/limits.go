@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"strings"
+)
+
+// cost is the static size of a selection: how deep it nests and how many
+// fields it may resolve.
+type cost struct {
+	depth      int
+	complexity int
+}
+
+// queryCost estimates the operation before it runs. Every field costs one,
+// and a list field multiplies the cost of its selection by listFactor, since
+// the real list length is not known up front. Introspection fields are free.
+func queryCost(schema graphql.Schema, doc *ast.Document, operationName string, listFactor int) (cost, error) {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				if operation != nil && operationName == "" {
+					return cost{}, fmt.Errorf("operationName is required when the document has several operations")
+				}
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return cost{}, fmt.Errorf("unknown operation %q", operationName)
+	}
+
+	w := costWalker{schema: schema, fragments: fragments, listFactor: listFactor, visiting: make(map[string]bool)}
+	return w.selection(schema.QueryType(), operation.SelectionSet), nil
+}
+
+type costWalker struct {
+	schema     graphql.Schema
+	fragments  map[string]*ast.FragmentDefinition
+	listFactor int
+	// visiting guards against fragment cycles, which validation rejects later.
+	visiting map[string]bool
+}
+
+func (w costWalker) selection(parent *graphql.Object, set *ast.SelectionSet) cost {
+	var total cost
+	if parent == nil || set == nil {
+		return total
+	}
+	add := func(c cost) {
+		total.complexity += c.complexity
+		if c.depth > total.depth {
+			total.depth = c.depth
+		}
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			add(w.field(parent, selection))
+		case *ast.InlineFragment:
+			add(w.selection(w.condition(parent, selection.TypeCondition), selection.SelectionSet))
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || w.visiting[name] {
+				continue
+			}
+			w.visiting[name] = true
+			add(w.selection(w.condition(parent, fragment.TypeCondition), fragment.SelectionSet))
+			delete(w.visiting, name)
+		}
+	}
+	return total
+}
+
+func (w costWalker) field(parent *graphql.Object, field *ast.Field) cost {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return cost{}
+	}
+	def, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		return cost{depth: 1, complexity: 1}
+	}
+
+	factor := 1
+	fieldType := def.Type
+	for {
+		if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+			fieldType = nonNull.OfType
+			continue
+		}
+		if list, ok := fieldType.(*graphql.List); ok {
+			factor *= w.listFactor
+			fieldType = list.OfType
+			continue
+		}
+		break
+	}
+
+	object, _ := fieldType.(*graphql.Object)
+	child := w.selection(object, field.SelectionSet)
+	return cost{depth: child.depth + 1, complexity: 1 + factor*child.complexity}
+}
+
+func (w costWalker) condition(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	object, _ := w.schema.Type(condition.Name.Value).(*graphql.Object)
+	return object
+}
@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"testing"
+)
+
+func TestQueryCost(t *testing.T) {
+	schema, err := newSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		operation  string
+		listFactor int
+		want       cost
+	}{
+		{"list", `{ groups { id } }`, "", 10, cost{depth: 2, complexity: 11}},
+		{"list factor one", `{ groups { id } }`, "", 1, cost{depth: 2, complexity: 2}},
+		{"object", `{ group(name: "x") { name students { name } } }`, "", 10, cost{depth: 3, complexity: 13}},
+		{"nested lists", `{ groups { students { attendance(startDate: "2024-09-01", endDate: "2024-12-31") { attended } } } }`, "", 10, cost{depth: 4, complexity: 1111}},
+		{"introspection is free", `{ __schema { types { name } } }`, "", 10, cost{}},
+		{"typename is free", `{ __typename groups { id } }`, "", 10, cost{depth: 2, complexity: 11}},
+		{"fragment spread", `query { group(name: "x") { ...G } } fragment G on Group { name department }`, "", 10, cost{depth: 2, complexity: 3}},
+		{"inline fragment", `{ group(name: "x") { ... on Group { name } } }`, "", 10, cost{depth: 2, complexity: 2}},
+		{"fragment cycle", `{ group(name: "x") { ...A } } fragment A on Group { ...B } fragment B on Group { ...A name }`, "", 10, cost{depth: 2, complexity: 2}},
+		{"unknown field", `{ nope }`, "", 10, cost{depth: 1, complexity: 1}},
+		{"named operation", `query A { groups { id } } query B { group(name: "x") { id } }`, "B", 10, cost{depth: 2, complexity: 2}},
+	}
+	for _, tt := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(tt.query)})})
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tt.name, err)
+		}
+		got, err := queryCost(schema, doc, tt.operation, tt.listFactor)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryCostOperationErrors(t *testing.T) {
+	schema, err := newSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		query     string
+		operation string
+	}{
+		{`query A { groups { id } } query B { groups { id } }`, ""},
+		{`query A { groups { id } }`, "B"},
+	} {
+		doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(tt.query)})})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := queryCost(schema, doc, tt.operation, 10); err == nil {
+			t.Errorf("queryCost(%q, %q) succeeded, want an error", tt.query, tt.operation)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package graph
+
+import "sync"
+
+// loader batches lookups by key for one request. Resolvers call load while
+// the executor walks a level of the query and get back a thunk; the first
+// thunk the executor runs fetches every key collected so far in one call.
+// Results are cached for the rest of the request.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]struct{}
+	cache   map[K]V
+	// fetched holds every key fetched so far, including those with no value.
+	fetched map[K]struct{}
+	errs    map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		queued:  make(map[K]struct{}),
+		cache:   make(map[K]V),
+		fetched: make(map[K]struct{}),
+		errs:    make(map[K]error),
+	}
+}
+
+// load queues the key and returns a graphql-go thunk resolving to the value,
+// passed through resolve. A key with no value resolves to nil.
+func (l *loader[K, V]) load(key K, resolve func(V) (interface{}, error)) func() (interface{}, error) {
+	l.mu.Lock()
+	_, fetched := l.fetched[key]
+	_, queued := l.queued[key]
+	if !fetched && !queued {
+		l.pending = append(l.pending, key)
+		l.queued[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		value, ok, err := l.get(key)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return resolve(value)
+	}
+}
+
+// get fetches the pending batch if needed and returns the key's value.
+func (l *loader[K, V]) get(key K) (V, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) > 0 {
+		keys := l.pending
+		l.pending = nil
+		l.queued = make(map[K]struct{})
+
+		values, err := l.fetch(keys)
+		for _, k := range keys {
+			l.fetched[k] = struct{}{}
+			if err != nil {
+				l.errs[k] = err
+				continue
+			}
+			if v, ok := values[k]; ok {
+				l.cache[k] = v
+			}
+		}
+	}
+
+	if err, ok := l.errs[key]; ok {
+		var zero V
+		return zero, false, err
+	}
+	value, ok := l.cache[key]
+	return value, ok, nil
+}
+
+// prime stores values that another lookup already returned.
+func (l *loader[K, V]) prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.fetched[key]; !ok {
+		l.cache[key] = value
+		l.fetched[key] = struct{}{}
+	}
+}
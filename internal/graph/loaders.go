@@ -0,0 +1,270 @@
+package graph
+
+import (
+	"context"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/redact"
+)
+
+type dateRange struct {
+	start, end string
+}
+
+type groupScheduleKey struct {
+	groupID int
+	dateRange
+}
+
+type studentAttendanceKey struct {
+	cardID string
+	dateRange
+}
+
+// loaders are the batched lookups of one request, bound to the caller's scope
+// and redaction policy.
+type loaders struct {
+	acc      *accounting.Client
+	scope    accounting.Scope
+	redactor *redact.Redactor
+	policy   string
+
+	groups             *loader[int, accounting.GroupRecord]
+	students           *loader[string, accounting.StudentRecord]
+	profiles           *loader[string, accounting.StudentProfile]
+	groupStudents      *loader[int, []string]
+	groupDisciplines   *loader[int, []int]
+	groupSchedule      *loader[groupScheduleKey, []int64]
+	disciplines        *loader[int, accounting.DisciplineRecord]
+	disciplineLessons  *loader[int, []int64]
+	lessons            *loader[int64, accounting.LessonRecord]
+	lessonMaterials    *loader[int64, []int]
+	materials          *loader[int, accounting.MaterialRecord]
+	materialLessons    *loader[int, []int64]
+	schedules          *loader[int64, accounting.ScheduleRecord]
+	scheduleAttendance *loader[int64, []accounting.AttendanceRecord]
+	studentAttendance  *loader[studentAttendanceKey, []accounting.AttendanceRecord]
+}
+
+func newLoaders(ctx context.Context, acc *accounting.Client, scope accounting.Scope, redactor *redact.Redactor, policy string) *loaders {
+	l := &loaders{acc: acc, scope: scope, redactor: redactor, policy: policy}
+
+	l.groups = newLoader(func(ids []int) (map[int]accounting.GroupRecord, error) {
+		records, err := acc.GroupRecords(scope, ids, nil)
+		if err != nil {
+			return nil, err
+		}
+		groups := make(map[int]accounting.GroupRecord, len(records))
+		for _, record := range records {
+			groups[record.ID] = record
+		}
+		return groups, nil
+	})
+
+	l.students = newLoader(func(cardIDs []string) (map[string]accounting.StudentRecord, error) {
+		records, err := acc.StudentRecords(scope, nil, cardIDs)
+		if err != nil {
+			return nil, err
+		}
+		students := make(map[string]accounting.StudentRecord, len(records))
+		for _, record := range records {
+			students[record.CardID] = record
+		}
+		return students, nil
+	})
+
+	l.profiles = newLoader(func(cardIDs []string) (map[string]accounting.StudentProfile, error) {
+		return acc.StudentProfiles(ctx, cardIDs)
+	})
+
+	l.groupStudents = newLoader(func(groupIDs []int) (map[int][]string, error) {
+		records, err := acc.StudentRecords(scope, groupIDs, nil)
+		if err != nil {
+			return nil, err
+		}
+		students := make(map[int][]string, len(groupIDs))
+		for _, id := range groupIDs {
+			students[id] = []string{}
+		}
+		for _, record := range records {
+			students[record.GroupID] = append(students[record.GroupID], record.CardID)
+			l.students.prime(record.CardID, record)
+		}
+		return students, nil
+	})
+
+	l.groupDisciplines = newLoader(func(groupIDs []int) (map[int][]int, error) {
+		disciplines, err := acc.GroupDisciplines(scope, groupIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range groupIDs {
+			if disciplines[id] == nil {
+				disciplines[id] = []int{}
+			}
+		}
+		return disciplines, nil
+	})
+
+	l.groupSchedule = newLoader(func(keys []groupScheduleKey) (map[groupScheduleKey][]int64, error) {
+		schedule := make(map[groupScheduleKey][]int64, len(keys))
+		for period, groupIDs := range groupKeysByRange(keys, func(k groupScheduleKey) (dateRange, int) { return k.dateRange, k.groupID }) {
+			records, err := acc.ScheduleRecords(scope, nil, groupIDs, period.start, period.end)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range groupIDs {
+				schedule[groupScheduleKey{groupID: id, dateRange: period}] = []int64{}
+			}
+			for _, record := range records {
+				key := groupScheduleKey{groupID: record.GroupID, dateRange: period}
+				schedule[key] = append(schedule[key], record.ID)
+				l.schedules.prime(record.ID, record)
+			}
+		}
+		return schedule, nil
+	})
+
+	l.disciplines = newLoader(func(ids []int) (map[int]accounting.DisciplineRecord, error) {
+		return acc.DisciplineRecords(ctx, ids)
+	})
+
+	l.disciplineLessons = newLoader(func(disciplineIDs []int) (map[int][]int64, error) {
+		records, err := acc.LessonRecords(ctx, nil, disciplineIDs)
+		if err != nil {
+			return nil, err
+		}
+		lessons := make(map[int][]int64, len(disciplineIDs))
+		for _, id := range disciplineIDs {
+			lessons[id] = []int64{}
+		}
+		for _, record := range records {
+			lessons[record.DisciplineID] = append(lessons[record.DisciplineID], record.ID)
+			l.lessons.prime(record.ID, record)
+		}
+		return lessons, nil
+	})
+
+	l.lessons = newLoader(func(ids []int64) (map[int64]accounting.LessonRecord, error) {
+		records, err := acc.LessonRecords(ctx, ids, nil)
+		if err != nil {
+			return nil, err
+		}
+		lessons := make(map[int64]accounting.LessonRecord, len(records))
+		for _, record := range records {
+			lessons[record.ID] = record
+		}
+		return lessons, nil
+	})
+
+	l.lessonMaterials = newLoader(func(lessonIDs []int64) (map[int64][]int, error) {
+		materials, err := acc.LessonMaterials(lessonIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range lessonIDs {
+			if materials[id] == nil {
+				materials[id] = []int{}
+			}
+		}
+		return materials, nil
+	})
+
+	l.materials = newLoader(func(ids []int) (map[int]accounting.MaterialRecord, error) {
+		return acc.MaterialRecords(ctx, ids)
+	})
+
+	l.materialLessons = newLoader(func(materialIDs []int) (map[int][]int64, error) {
+		lessons, err := acc.MaterialLessons(materialIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range materialIDs {
+			if lessons[id] == nil {
+				lessons[id] = []int64{}
+			}
+		}
+		return lessons, nil
+	})
+
+	l.schedules = newLoader(func(ids []int64) (map[int64]accounting.ScheduleRecord, error) {
+		records, err := acc.ScheduleRecords(scope, ids, nil, "", "")
+		if err != nil {
+			return nil, err
+		}
+		schedules := make(map[int64]accounting.ScheduleRecord, len(records))
+		for _, record := range records {
+			schedules[record.ID] = record
+		}
+		return schedules, nil
+	})
+
+	l.scheduleAttendance = newLoader(func(scheduleIDs []int64) (map[int64][]accounting.AttendanceRecord, error) {
+		records, err := acc.AttendanceRecords(scope, scheduleIDs, nil, "", "")
+		if err != nil {
+			return nil, err
+		}
+		attendance := make(map[int64][]accounting.AttendanceRecord, len(scheduleIDs))
+		for _, id := range scheduleIDs {
+			attendance[id] = []accounting.AttendanceRecord{}
+		}
+		for _, record := range records {
+			attendance[record.ScheduleID] = append(attendance[record.ScheduleID], record)
+		}
+		return attendance, nil
+	})
+
+	l.studentAttendance = newLoader(func(keys []studentAttendanceKey) (map[studentAttendanceKey][]accounting.AttendanceRecord, error) {
+		attendance := make(map[studentAttendanceKey][]accounting.AttendanceRecord, len(keys))
+		for period, cardIDs := range groupKeysByRange(keys, func(k studentAttendanceKey) (dateRange, string) { return k.dateRange, k.cardID }) {
+			records, err := acc.AttendanceRecords(scope, nil, cardIDs, period.start, period.end)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range cardIDs {
+				attendance[studentAttendanceKey{cardID: id, dateRange: period}] = []accounting.AttendanceRecord{}
+			}
+			for _, record := range records {
+				key := studentAttendanceKey{cardID: record.CardID, dateRange: period}
+				attendance[key] = append(attendance[key], record)
+			}
+		}
+		return attendance, nil
+	})
+
+	return l
+}
+
+// groupKeysByRange splits a batch of keys by their date range, since each
+// range needs its own query.
+func groupKeysByRange[K any, ID any](keys []K, split func(K) (dateRange, ID)) map[dateRange][]ID {
+	byRange := make(map[dateRange][]ID)
+	for _, key := range keys {
+		period, id := split(key)
+		byRange[period] = append(byRange[period], id)
+	}
+	return byRange
+}
+
+// groupRecords looks up the groups in scope by name, or all of them when no
+// names are given, and primes the group loader with the result.
+func (l *loaders) groupRecords(names []string) ([]accounting.GroupRecord, error) {
+	records, err := l.acc.GroupRecords(l.scope, nil, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		l.groups.prime(record.ID, record)
+	}
+	return records, nil
+}
+
+// redacted applies the request's redaction policy to a student or group
+// string field, named as in the REST responses. An omitted field resolves to
+// null.
+func (l *loaders) redacted(field, value string) interface{} {
+	value, ok := l.redactor.Value(l.policy, field, value)
+	if !ok {
+		return nil
+	}
+	return value
+}
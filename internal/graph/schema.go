@@ -0,0 +1,389 @@
+package graph
+
+import (
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/graphql-go/graphql"
+	"time"
+)
+
+type contextKey struct{}
+
+func loadersFrom(p graphql.ResolveParams) *loaders {
+	return p.Context.Value(contextKey{}).(*loaders)
+}
+
+// Entities are passed between resolvers by key: a Group is its group_id, a
+// Student its card_id and so on. Fields beyond the key are resolved through
+// the request loaders, so siblings on one level share a single fetch.
+
+func groupField(get func(l *loaders, g accounting.GroupRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		l := loadersFrom(p)
+		return l.groups.load(p.Source.(int), func(g accounting.GroupRecord) (interface{}, error) {
+			return get(l, g), nil
+		}), nil
+	}
+}
+
+func studentField(get func(l *loaders, s accounting.StudentRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		l := loadersFrom(p)
+		return l.students.load(p.Source.(string), func(s accounting.StudentRecord) (interface{}, error) {
+			return get(l, s), nil
+		}), nil
+	}
+}
+
+func profileField(get func(l *loaders, s accounting.StudentProfile) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		l := loadersFrom(p)
+		return l.profiles.load(p.Source.(string), func(s accounting.StudentProfile) (interface{}, error) {
+			return get(l, s), nil
+		}), nil
+	}
+}
+
+func disciplineField(get func(d accounting.DisciplineRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return loadersFrom(p).disciplines.load(p.Source.(int), func(d accounting.DisciplineRecord) (interface{}, error) {
+			return get(d), nil
+		}), nil
+	}
+}
+
+func lessonField(get func(l accounting.LessonRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return loadersFrom(p).lessons.load(p.Source.(int64), func(l accounting.LessonRecord) (interface{}, error) {
+			return get(l), nil
+		}), nil
+	}
+}
+
+func materialField(get func(m accounting.MaterialRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return loadersFrom(p).materials.load(p.Source.(int), func(m accounting.MaterialRecord) (interface{}, error) {
+			return get(m), nil
+		}), nil
+	}
+}
+
+func scheduleField(get func(s accounting.ScheduleRecord) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return loadersFrom(p).schedules.load(p.Source.(int64), func(s accounting.ScheduleRecord) (interface{}, error) {
+			return get(s), nil
+		}), nil
+	}
+}
+
+// passThrough resolves a loaded value as is.
+func passThrough[V any](v V) (interface{}, error) {
+	return v, nil
+}
+
+func sourceKey(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
+
+var periodArgs = graphql.FieldConfigArgument{
+	"startDate": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	"endDate":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+}
+
+func periodFromArgs(p graphql.ResolveParams) (dateRange, error) {
+	period := dateRange{start: p.Args["startDate"].(string), end: p.Args["endDate"].(string)}
+	for _, date := range []string{period.start, period.end} {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return dateRange{}, fmt.Errorf("dates must be in the format YYYY-MM-DD, got %q", date)
+		}
+	}
+	return period, nil
+}
+
+func newSchema() (graphql.Schema, error) {
+	var groupType, studentType, disciplineType, lessonType, materialType, scheduleType, attendanceType *graphql.Object
+
+	groupType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Group",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.Int), Resolve: sourceKey},
+				"name": {Type: graphql.String, Resolve: groupField(func(l *loaders, g accounting.GroupRecord) interface{} {
+					return l.redacted("name", g.Name)
+				})},
+				"department": {Type: graphql.String, Resolve: groupField(func(l *loaders, g accounting.GroupRecord) interface{} {
+					return l.redacted("department", g.Department)
+				})},
+				"curatorEmail": {Type: graphql.String, Resolve: groupField(func(l *loaders, g accounting.GroupRecord) interface{} {
+					return l.redacted("curator_email", g.CuratorEmail)
+				})},
+				"students": {
+					Type:        graphql.NewList(graphql.NewNonNull(studentType)),
+					Description: "Students of the group visible to the caller.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).groupStudents.load(p.Source.(int), passThrough[[]string]), nil
+					},
+				},
+				"disciplines": {
+					Type:        graphql.NewList(graphql.NewNonNull(disciplineType)),
+					Description: "Disciplines with lessons scheduled for the group.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).groupDisciplines.load(p.Source.(int), passThrough[[]int]), nil
+					},
+				},
+				"schedule": {
+					Type: graphql.NewList(graphql.NewNonNull(scheduleType)),
+					Args: periodArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						period, err := periodFromArgs(p)
+						if err != nil {
+							return nil, err
+						}
+						key := groupScheduleKey{groupID: p.Source.(int), dateRange: period}
+						return loadersFrom(p).groupSchedule.load(key, passThrough[[]int64]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	studentType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Student",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				// The card ID stays the source of the nested fields, only its
+				// rendering is redacted.
+				"cardId": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).redacted("card_id", p.Source.(string)), nil
+				}},
+				"name": {Type: graphql.String, Resolve: profileField(func(l *loaders, s accounting.StudentProfile) interface{} {
+					return l.redacted("name", s.Name)
+				})},
+				"course": {Type: graphql.Int, Resolve: profileField(func(_ *loaders, s accounting.StudentProfile) interface{} {
+					return s.Course
+				})},
+				"department": {Type: graphql.String, Resolve: profileField(func(l *loaders, s accounting.StudentProfile) interface{} {
+					return l.redacted("department-name", s.Department)
+				})},
+				"email": {Type: graphql.String, Resolve: profileField(func(l *loaders, s accounting.StudentProfile) interface{} {
+					return l.redacted("email", s.Email)
+				})},
+				"birth": {Type: graphql.String, Resolve: profileField(func(l *loaders, s accounting.StudentProfile) interface{} {
+					return l.redacted("birth", s.Birth)
+				})},
+				"group": {Type: groupType, Resolve: studentField(func(_ *loaders, s accounting.StudentRecord) interface{} {
+					return s.GroupID
+				})},
+				"attendance": {
+					Type: graphql.NewList(graphql.NewNonNull(attendanceType)),
+					Args: periodArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						period, err := periodFromArgs(p)
+						if err != nil {
+							return nil, err
+						}
+						key := studentAttendanceKey{cardID: p.Source.(string), dateRange: period}
+						return loadersFrom(p).studentAttendance.load(key, passThrough[[]accounting.AttendanceRecord]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	disciplineType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discipline",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.Int), Resolve: sourceKey},
+				"name": {Type: graphql.String, Resolve: disciplineField(func(d accounting.DisciplineRecord) interface{} {
+					return d.Name
+				})},
+				"description": {Type: graphql.String, Resolve: disciplineField(func(d accounting.DisciplineRecord) interface{} {
+					return d.Description
+				})},
+				"isSpecial": {Type: graphql.Boolean, Resolve: disciplineField(func(d accounting.DisciplineRecord) interface{} {
+					return d.IsSpecial
+				})},
+				"lessons": {
+					Type: graphql.NewList(graphql.NewNonNull(lessonType)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).disciplineLessons.load(p.Source.(int), passThrough[[]int64]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	lessonType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Lesson",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.Int), Resolve: sourceKey},
+				"topic": {Type: graphql.String, Resolve: lessonField(func(l accounting.LessonRecord) interface{} {
+					return l.Topic
+				})},
+				"type": {Type: graphql.String, Resolve: lessonField(func(l accounting.LessonRecord) interface{} {
+					return l.Type
+				})},
+				"equipment": {Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Resolve: lessonField(func(l accounting.LessonRecord) interface{} {
+					return l.Equipment
+				})},
+				"discipline": {Type: disciplineType, Resolve: lessonField(func(l accounting.LessonRecord) interface{} {
+					return l.DisciplineID
+				})},
+				"materials": {
+					Type: graphql.NewList(graphql.NewNonNull(materialType)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).lessonMaterials.load(p.Source.(int64), passThrough[[]int]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	materialType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Material",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.Int), Resolve: sourceKey},
+				"title": {Type: graphql.String, Resolve: materialField(func(m accounting.MaterialRecord) interface{} {
+					return m.Title
+				})},
+				"content": {Type: graphql.String, Resolve: materialField(func(m accounting.MaterialRecord) interface{} {
+					return m.Content
+				})},
+				"tags": {Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Resolve: materialField(func(m accounting.MaterialRecord) interface{} {
+					return m.Tags
+				})},
+				"lessons": {
+					Type: graphql.NewList(graphql.NewNonNull(lessonType)),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).materialLessons.load(p.Source.(int), passThrough[[]int64]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	scheduleType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Schedule",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.Int), Resolve: sourceKey},
+				"date": {Type: graphql.String, Resolve: scheduleField(func(s accounting.ScheduleRecord) interface{} {
+					return s.Date
+				})},
+				"group": {Type: groupType, Resolve: scheduleField(func(s accounting.ScheduleRecord) interface{} {
+					return s.GroupID
+				})},
+				"lesson": {Type: lessonType, Resolve: scheduleField(func(s accounting.ScheduleRecord) interface{} {
+					return s.LessonID
+				})},
+				"attendance": {
+					Type:        graphql.NewList(graphql.NewNonNull(attendanceType)),
+					Description: "Attendance marks of the students visible to the caller.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return loadersFrom(p).scheduleAttendance.load(p.Source.(int64), passThrough[[]accounting.AttendanceRecord]), nil
+					},
+				},
+			}
+		}),
+	})
+
+	attendanceType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Attendance",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"student": {Type: graphql.NewNonNull(studentType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(accounting.AttendanceRecord).CardID, nil
+				}},
+				"schedule": {Type: graphql.NewNonNull(scheduleType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(accounting.AttendanceRecord).ScheduleID, nil
+				}},
+				"attended": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(accounting.AttendanceRecord).Attended, nil
+				}},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"groups": {
+				Type: graphql.NewList(graphql.NewNonNull(groupType)),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					l := loadersFrom(p)
+					records, err := l.groupRecords(nil)
+					if err != nil {
+						return nil, err
+					}
+					ids := make([]int, 0, len(records))
+					for _, record := range records {
+						ids = append(ids, record.ID)
+					}
+					return ids, nil
+				},
+			},
+			"group": {
+				Type: groupType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					records, err := loadersFrom(p).groupRecords([]string{p.Args["name"].(string)})
+					if err != nil || len(records) == 0 {
+						return nil, err
+					}
+					return records[0].ID, nil
+				},
+			},
+			"student": {
+				Type: studentType,
+				Args: graphql.FieldConfigArgument{
+					"cardId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).students.load(p.Args["cardId"].(string), func(s accounting.StudentRecord) (interface{}, error) {
+						return s.CardID, nil
+					}), nil
+				},
+			},
+			"discipline": {
+				Type: disciplineType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).disciplines.load(p.Args["id"].(int), func(d accounting.DisciplineRecord) (interface{}, error) {
+						return d.ID, nil
+					}), nil
+				},
+			},
+			"lesson": {
+				Type: lessonType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).lessons.load(int64(p.Args["id"].(int)), func(l accounting.LessonRecord) (interface{}, error) {
+						return l.ID, nil
+					}), nil
+				},
+			},
+			"material": {
+				Type: materialType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).materials.load(p.Args["id"].(int), func(m accounting.MaterialRecord) (interface{}, error) {
+						return m.ID, nil
+					}), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
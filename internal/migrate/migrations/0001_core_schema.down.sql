@@ -0,0 +1,7 @@
+-- 0001 adopts the tables of an existing database, so reverting it would drop
+-- data that predates the migrations.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'migration 0001_core_schema is irreversible';
+END
+$$;
@@ -0,0 +1,69 @@
+-- The core schema used to live in another repository. Every statement is
+-- guarded with IF NOT EXISTS so databases created from there are adopted as
+-- they are.
+
+CREATE TABLE IF NOT EXISTS department (
+    department_id SERIAL PRIMARY KEY,
+    name          TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS "group" (
+    group_id      SERIAL PRIMARY KEY,
+    name          TEXT NOT NULL UNIQUE,
+    department_id INT REFERENCES department (department_id)
+);
+-- The "group" table of the old schema has no department_id.
+ALTER TABLE "group" ADD COLUMN IF NOT EXISTS department_id INT REFERENCES department (department_id);
+
+CREATE TABLE IF NOT EXISTS student (
+    student_id SERIAL PRIMARY KEY,
+    card_id    TEXT NOT NULL UNIQUE,
+    group_id   INT NOT NULL REFERENCES "group" (group_id)
+);
+CREATE INDEX IF NOT EXISTS student_group_id_idx ON student (group_id);
+
+-- Discipline names and descriptions live in the Elasticsearch catalog;
+-- Postgres only knows which disciplines are special.
+CREATE TABLE IF NOT EXISTS course (
+    course_id     SERIAL PRIMARY KEY,
+    discipline_id INT NOT NULL,
+    is_special    BOOLEAN NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS course_discipline_id_idx ON course (discipline_id);
+
+-- type: 1 lecture, 2 practice, 3 laboratory.
+CREATE TABLE IF NOT EXISTS lesson (
+    lesson_id     BIGSERIAL PRIMARY KEY,
+    discipline_id INT NOT NULL,
+    topic         TEXT NOT NULL,
+    type          SMALLINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lesson_discipline_id_idx ON lesson (discipline_id);
+
+CREATE TABLE IF NOT EXISTS equipment (
+    id   SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS equipment_requirements (
+    lesson_id BIGINT NOT NULL REFERENCES lesson (lesson_id) ON DELETE CASCADE,
+    equipment INT NOT NULL REFERENCES equipment (id),
+    PRIMARY KEY (lesson_id, equipment)
+);
+
+CREATE TABLE IF NOT EXISTS schedule (
+    schedule_id BIGSERIAL PRIMARY KEY,
+    lesson_id   BIGINT NOT NULL REFERENCES lesson (lesson_id),
+    group_id    INT NOT NULL REFERENCES "group" (group_id),
+    date        DATE NOT NULL
+);
+CREATE INDEX IF NOT EXISTS schedule_group_id_date_idx ON schedule (group_id, date);
+CREATE INDEX IF NOT EXISTS schedule_lesson_id_idx ON schedule (lesson_id);
+
+CREATE TABLE IF NOT EXISTS attendance (
+    student_id  INT NOT NULL REFERENCES student (student_id),
+    schedule_id BIGINT NOT NULL REFERENCES schedule (schedule_id) ON DELETE CASCADE,
+    status      BOOLEAN NOT NULL,
+    PRIMARY KEY (student_id, schedule_id)
+);
+CREATE INDEX IF NOT EXISTS attendance_schedule_id_idx ON attendance (schedule_id);
@@ -0,0 +1 @@
+ALTER TABLE "group" DROP COLUMN IF EXISTS curator_email;
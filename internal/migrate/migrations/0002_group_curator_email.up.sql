@@ -0,0 +1,2 @@
+-- Low attendance notifications send the group summary to its curator.
+ALTER TABLE "group" ADD COLUMN IF NOT EXISTS curator_email TEXT;
@@ -0,0 +1,13 @@
+-- Only the SHA-256 hash of an API key is stored.
+CREATE TABLE IF NOT EXISTS api_key (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    prefix     TEXT NOT NULL,
+    key_hash   TEXT NOT NULL UNIQUE,
+    role       TEXT NOT NULL,
+    card_id    TEXT NOT NULL DEFAULT '',
+    groups     TEXT[] NOT NULL DEFAULT '{}',
+    department TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
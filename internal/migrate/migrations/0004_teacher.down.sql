@@ -0,0 +1,3 @@
+ALTER TABLE schedule DROP COLUMN IF EXISTS teacher_id;
+ALTER TABLE lesson DROP COLUMN IF EXISTS teacher_id;
+DROP TABLE IF EXISTS teacher;
@@ -0,0 +1,17 @@
+-- weekly_load_hours is the teacher's planned load, compared with the hours
+-- actually delivered by the workload report.
+CREATE TABLE IF NOT EXISTS teacher (
+    teacher_id        SERIAL PRIMARY KEY,
+    name              TEXT NOT NULL,
+    email             TEXT UNIQUE,
+    department_id     INT REFERENCES department (department_id),
+    weekly_load_hours INT NOT NULL DEFAULT 0 CHECK (weekly_load_hours >= 0)
+);
+
+-- A lesson has its usual teacher; a scheduled session may override it for a
+-- substitution.
+ALTER TABLE lesson ADD COLUMN IF NOT EXISTS teacher_id INT REFERENCES teacher (teacher_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS lesson_teacher_id_idx ON lesson (teacher_id);
+
+ALTER TABLE schedule ADD COLUMN IF NOT EXISTS teacher_id INT REFERENCES teacher (teacher_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS schedule_teacher_id_idx ON schedule (teacher_id);
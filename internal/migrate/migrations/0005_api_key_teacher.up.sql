@@ -0,0 +1,2 @@
+-- A teacher key sees the groups its teacher teaches.
+ALTER TABLE api_key ADD COLUMN IF NOT EXISTS teacher_id INT NOT NULL DEFAULT 0;
@@ -0,0 +1,249 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+)
+
+type Config struct {
+	SMTP SMTPConfig `json:"smtp"`
+	// Locale of the rendered emails, "ru" or "en".
+	Locale string `json:"locale"`
+	// NotifyCurators sends each group curator a digest of flagged students.
+	NotifyCurators bool `json:"notify_curators"`
+	BatchSize      int  `json:"batch_size"`
+	MaxRetries     int  `json:"max_retries"`
+	RetryDelaySec  int  `json:"retry_delay_sec"`
+	// DryRun renders the messages without sending or logging them.
+	DryRun bool `json:"dry_run"`
+}
+
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+	StartTLS bool   `json:"start_tls"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SMTP: SMTPConfig{
+			Host: "localhost",
+			Port: 1025,
+			From: "accounting@localhost",
+		},
+		Locale:         LocaleRu,
+		NotifyCurators: true,
+		BatchSize:      20,
+		MaxRetries:     3,
+		RetryDelaySec:  2,
+	}
+}
+
+type Notifier struct {
+	accountingClient *accounting.Client
+	sendLog          *sendLog
+	sender           *smtpSender
+	cfg              Config
+}
+
+func NewNotifier(accountingClient *accounting.Client, logCollection *mongo.Collection, cfg Config) *Notifier {
+	return &Notifier{
+		accountingClient: accountingClient,
+		sendLog:          &sendLog{collection: logCollection},
+		sender:           &smtpSender{cfg: cfg},
+		cfg:              cfg,
+	}
+}
+
+// EnsureIndexes creates the unique index the send log relies on to prevent
+// duplicate emails.
+func (n *Notifier) EnsureIndexes(ctx context.Context) error {
+	return n.sendLog.ensureIndexes(ctx)
+}
+
+type Report struct {
+	DryRun     bool             `json:"dry_run"`
+	Sent       int              `json:"sent"`
+	Duplicates int              `json:"duplicates"`
+	Failed     []FailedMessage  `json:"failed"`
+	Messages   []MessagePreview `json:"messages,omitempty"`
+}
+
+type FailedMessage struct {
+	Recipient string `json:"recipient"`
+	Error     string `json:"error"`
+}
+
+type MessagePreview struct {
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+type message struct {
+	key       string
+	kind      string
+	recipient string
+	subject   string
+	body      string
+}
+
+// NotifyLowAttendance emails every student flagged by the at-risk rules over
+// the period and, when enabled, a digest to the curator of each affected
+// group. Messages already sent for the same period are skipped. An empty
+// locale uses the configured one.
+func (n *Notifier) NotifyLowAttendance(startDate, endDate, locale string, dryRun bool) (*Report, error) {
+	ctx := context.Background()
+	dryRun = dryRun || n.cfg.DryRun
+	if locale == "" {
+		locale = n.cfg.Locale
+	}
+	if _, ok := templates[locale]; !ok {
+		return nil, fmt.Errorf("unsupported locale %q", locale)
+	}
+
+	flagged, err := n.accountingClient.FindAtRiskStudents(accounting.Scope{}, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find at-risk students: %v", err)
+	}
+
+	messages, err := n.buildMessages(flagged, startDate, endDate, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun, Failed: make([]FailedMessage, 0)}
+
+	if dryRun {
+		for _, msg := range messages {
+			sent, err := n.sendLog.wasSent(ctx, msg.key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check send log: %v", err)
+			}
+			if sent {
+				report.Duplicates++
+				continue
+			}
+			report.Messages = append(report.Messages, MessagePreview{
+				Recipient: msg.recipient,
+				Subject:   msg.subject,
+				Body:      msg.body,
+			})
+		}
+		return report, nil
+	}
+
+	var pending []message
+	for _, msg := range messages {
+		reserved, err := n.sendLog.reserve(ctx, msg, startDate, endDate)
+		if err != nil {
+			n.releaseAll(ctx, pending)
+			return nil, fmt.Errorf("failed to reserve send log entry: %v", err)
+		}
+		if !reserved {
+			report.Duplicates++
+			continue
+		}
+		pending = append(pending, msg)
+	}
+
+	for _, result := range n.sender.send(pending) {
+		if result.err != nil {
+			logrus.Errorf("failed to send %s notification to %s: %v", result.msg.kind, result.msg.recipient, result.err)
+			report.Failed = append(report.Failed, FailedMessage{Recipient: result.msg.recipient, Error: result.err.Error()})
+			n.releaseAll(ctx, []message{result.msg})
+			continue
+		}
+		report.Sent++
+		// The reservation already keeps the message from being sent again.
+		if err := n.sendLog.markSent(ctx, result.msg.key); err != nil {
+			logrus.Errorf("failed to mark notification to %s as sent: %v", result.msg.recipient, err)
+		}
+	}
+
+	return report, nil
+}
+
+// releaseAll drops the reservations of unsent messages. One left behind keeps
+// its message from being sent until the entry is removed.
+func (n *Notifier) releaseAll(ctx context.Context, messages []message) {
+	for _, msg := range messages {
+		if err := n.sendLog.release(ctx, msg.key); err != nil {
+			logrus.Errorf("failed to release send log entry of notification to %s: %v", msg.recipient, err)
+		}
+	}
+}
+
+func (n *Notifier) buildMessages(flagged []accounting.FlaggedStudent, startDate, endDate, locale string) ([]message, error) {
+	tmpl := templates[locale]
+	period := fmt.Sprintf("%s - %s", startDate, endDate)
+
+	var messages []message
+	byGroup := make(map[string][]studentData)
+	for _, student := range flagged {
+		data := studentData{
+			Name:    student.Name,
+			Group:   student.Group,
+			Period:  period,
+			Reasons: describeTriggers(locale, student.Triggers),
+		}
+		byGroup[student.Group] = append(byGroup[student.Group], data)
+
+		if student.Email == "" {
+			logrus.Warnf("student %s has no email, skipping notification", student.StudentID)
+			continue
+		}
+		subject, body, err := tmpl.student.render(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render student email: %v", err)
+		}
+		messages = append(messages, message{
+			key:       fmt.Sprintf("student|%s|%s|%s|%s", student.StudentID, student.Email, startDate, endDate),
+			kind:      "student",
+			recipient: student.Email,
+			subject:   subject,
+			body:      body,
+		})
+	}
+
+	if !n.cfg.NotifyCurators {
+		return messages, nil
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for group := range byGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		curator, err := n.accountingClient.GetGroupCurator(group)
+		if err != nil {
+			return nil, err
+		}
+		if curator == "" {
+			logrus.Warnf("group %s has no curator, skipping digest", group)
+			continue
+		}
+		subject, body, err := tmpl.curator.render(curatorData{Group: group, Period: period, Students: byGroup[group]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render curator email: %v", err)
+		}
+		messages = append(messages, message{
+			key:       fmt.Sprintf("curator|%s|%s|%s|%s", group, curator, startDate, endDate),
+			kind:      "curator",
+			recipient: curator,
+			subject:   subject,
+			body:      body,
+		})
+	}
+
+	return messages, nil
+}
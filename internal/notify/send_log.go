@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type sendLog struct {
+	collection *mongo.Collection
+}
+
+// A message is reserved in the log before it is sent, so concurrent runs
+// cannot both send it. The unique key index decides which run wins.
+const (
+	statusSending = "sending"
+	statusSent    = "sent"
+)
+
+type sendLogEntry struct {
+	Key        string     `bson:"key"`
+	Kind       string     `bson:"kind"`
+	Recipient  string     `bson:"recipient"`
+	Subject    string     `bson:"subject"`
+	StartDate  string     `bson:"start_date"`
+	EndDate    string     `bson:"end_date"`
+	Status     string     `bson:"status"`
+	ReservedAt time.Time  `bson:"reserved_at"`
+	SentAt     *time.Time `bson:"sent_at,omitempty"`
+}
+
+func (l *sendLog) ensureIndexes(ctx context.Context) error {
+	_, err := l.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (l *sendLog) wasSent(ctx context.Context, key string) (bool, error) {
+	err := l.collection.FindOne(ctx, bson.M{"key": key}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reserve inserts the message's entry and reports whether this call did, and
+// so may send it. An existing entry means it was sent or is being sent.
+func (l *sendLog) reserve(ctx context.Context, msg message, startDate, endDate string) (bool, error) {
+	_, err := l.collection.InsertOne(ctx, sendLogEntry{
+		Key:        msg.key,
+		Kind:       msg.kind,
+		Recipient:  msg.recipient,
+		Subject:    msg.subject,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Status:     statusSending,
+		ReservedAt: time.Now().UTC(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *sendLog) markSent(ctx context.Context, key string) error {
+	_, err := l.collection.UpdateOne(ctx, bson.M{"key": key},
+		bson.M{"$set": bson.M{"status": statusSent, "sent_at": time.Now().UTC()}})
+	return err
+}
+
+// release drops the reservation of a message that failed to send, so the
+// next run retries it.
+func (l *sendLog) release(ctx context.Context, key string) error {
+	_, err := l.collection.DeleteOne(ctx, bson.M{"key": key, "status": statusSending})
+	return err
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type smtpSender struct {
+	cfg Config
+}
+
+type sendResult struct {
+	msg message
+	err error
+}
+
+// send delivers messages in batches, one SMTP connection per batch. Messages
+// that fail are retried with a growing delay up to MaxRetries times.
+func (s *smtpSender) send(messages []message) []sendResult {
+	batchSize := s.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(messages)
+	}
+
+	var results []sendResult
+	for start := 0; start < len(messages); start += batchSize {
+		end := start + batchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		pending := messages[start:end]
+		var failed []sendResult
+		for attempt := 0; attempt <= s.cfg.MaxRetries && len(pending) > 0; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt*s.cfg.RetryDelaySec) * time.Second)
+			}
+
+			failed = failed[:0]
+			for _, result := range s.sendBatch(pending) {
+				if result.err != nil {
+					failed = append(failed, result)
+				} else {
+					results = append(results, result)
+				}
+			}
+
+			pending = pending[:0:0]
+			for _, result := range failed {
+				pending = append(pending, result.msg)
+			}
+		}
+		results = append(results, failed...)
+	}
+
+	return results
+}
+
+func (s *smtpSender) sendBatch(messages []message) []sendResult {
+	results := make([]sendResult, 0, len(messages))
+	fail := func(from int, err error) []sendResult {
+		for _, msg := range messages[from:] {
+			results = append(results, sendResult{msg: msg, err: err})
+		}
+		return results
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return fail(0, err)
+	}
+	defer client.Close()
+
+	for i, msg := range messages {
+		if err := s.write(client, msg); err != nil {
+			results = append(results, sendResult{msg: msg, err: err})
+			if resetErr := client.Reset(); resetErr != nil {
+				return fail(i+1, resetErr)
+			}
+			continue
+		}
+		results = append(results, sendResult{msg: msg})
+	}
+	_ = client.Quit()
+
+	return results
+}
+
+func (s *smtpSender) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.cfg.SMTP.Host, strconv.Itoa(s.cfg.SMTP.Port))
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+
+	if s.cfg.SMTP.StartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.SMTP.Host}); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %v", err)
+		}
+	}
+	if s.cfg.SMTP.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.SMTP.Username, s.cfg.SMTP.Password, s.cfg.SMTP.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+func (s *smtpSender) write(client *smtp.Client, msg message) error {
+	if err := client.Mail(s.cfg.SMTP.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.recipient); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.format(msg)); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *smtpSender) format(msg message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + s.cfg.SMTP.From + "\r\n")
+	b.WriteString("To: " + msg.recipient + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	b.WriteString("\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.body))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server. rcpt decides the reply to RCPT TO for a
+// recipient given how many times it was offered before.
+type fakeSMTP struct {
+	listener net.Listener
+	rcpt     func(recipient string, attempt int) string
+
+	mu          sync.Mutex
+	connections int
+	attempts    map[string]int
+	delivered   []string
+}
+
+func newFakeSMTP(t *testing.T, rcpt func(recipient string, attempt int) string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{listener: listener, rcpt: rcpt, attempts: make(map[string]int)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTP) config(batchSize, maxRetries int) Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	cfg := DefaultConfig()
+	cfg.SMTP.Host = addr.IP.String()
+	cfg.SMTP.Port = addr.Port
+	cfg.BatchSize = batchSize
+	cfg.MaxRetries = maxRetries
+	cfg.RetryDelaySec = 0
+	return cfg
+}
+
+// stats reads the counters under the lock, the handlers write them from
+// their own goroutines.
+func (s *fakeSMTP) stats() (connections int, attempts map[string]int, delivered []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts = make(map[string]int, len(s.attempts))
+	for recipient, n := range s.attempts {
+		attempts[recipient] = n
+	}
+	return s.connections, attempts, append([]string(nil), s.delivered...)
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 fake ESMTP")
+	var recipient string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250 fake")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			recipient = strings.Trim(line[len("RCPT TO:"):], "<> ")
+			s.mu.Lock()
+			attempt := s.attempts[recipient]
+			s.attempts[recipient]++
+			s.mu.Unlock()
+			reply(s.rcpt(recipient, attempt))
+		case verb == "DATA":
+			reply("354 go ahead")
+			for {
+				data, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if data == ".\r\n" {
+					break
+				}
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, recipient)
+			s.mu.Unlock()
+			reply("250 OK")
+		case verb == "RSET":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func accept(string, int) string { return "250 OK" }
+
+func testMessages(recipients ...string) []message {
+	messages := make([]message, 0, len(recipients))
+	for _, recipient := range recipients {
+		messages = append(messages, message{recipient: recipient, subject: "Посещаемость", body: "text"})
+	}
+	return messages
+}
+
+func failedRecipients(results []sendResult) []string {
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.msg.recipient)
+		}
+	}
+	return failed
+}
+
+func TestSMTPSenderBatches(t *testing.T) {
+	server := newFakeSMTP(t, accept)
+	sender := &smtpSender{cfg: server.config(2, 0)}
+
+	results := sender.send(testMessages("a@x", "b@x", "c@x", "d@x", "e@x"))
+
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+	if failed := failedRecipients(results); len(failed) > 0 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	connections, _, delivered := server.stats()
+	if connections != 3 {
+		t.Errorf("got %d connections, want 3 for batches of 2", connections)
+	}
+	if len(delivered) != 5 {
+		t.Errorf("delivered %v, want all 5 messages", delivered)
+	}
+}
+
+func TestSMTPSenderRetriesTemporaryFailure(t *testing.T) {
+	server := newFakeSMTP(t, func(recipient string, attempt int) string {
+		if recipient == "b@x" && attempt == 0 {
+			return "451 try again later"
+		}
+		return "250 OK"
+	})
+	sender := &smtpSender{cfg: server.config(10, 2)}
+
+	results := sender.send(testMessages("a@x", "b@x", "c@x"))
+
+	if failed := failedRecipients(results); len(failed) > 0 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	connections, _, delivered := server.stats()
+	if connections != 2 {
+		t.Errorf("got %d connections, want 2", connections)
+	}
+	if got := strings.Join(delivered, ","); got != "a@x,c@x,b@x" {
+		t.Errorf("delivered %s, want a@x,c@x,b@x", got)
+	}
+}
+
+func TestSMTPSenderGivesUp(t *testing.T) {
+	server := newFakeSMTP(t, func(recipient string, attempt int) string {
+		if recipient == "b@x" {
+			return "450 mailbox busy"
+		}
+		return "250 OK"
+	})
+	sender := &smtpSender{cfg: server.config(10, 2)}
+
+	results := sender.send(testMessages("a@x", "b@x"))
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	failed := failedRecipients(results)
+	if len(failed) != 1 || failed[0] != "b@x" {
+		t.Fatalf("failed %v, want only b@x", failed)
+	}
+	_, attempts, _ := server.stats()
+	if attempts["b@x"] != 3 {
+		t.Errorf("b@x offered %d times, want 1 try and 2 retries", attempts["b@x"])
+	}
+	if attempts["a@x"] != 1 {
+		t.Errorf("a@x offered %d times, want 1", attempts["a@x"])
+	}
+}
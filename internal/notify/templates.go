@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"text/template"
+)
+
+const (
+	LocaleRu = "ru"
+	LocaleEn = "en"
+)
+
+type studentData struct {
+	Name    string
+	Group   string
+	Period  string
+	Reasons []string
+}
+
+type curatorData struct {
+	Group    string
+	Period   string
+	Students []studentData
+}
+
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func (t emailTemplate) render(data interface{}) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
+
+func newEmailTemplate(name, subject, body string) emailTemplate {
+	return emailTemplate{
+		subject: template.Must(template.New(name + "-subject").Parse(subject)),
+		body:    template.Must(template.New(name + "-body").Parse(body)),
+	}
+}
+
+type localeTemplates struct {
+	student emailTemplate
+	curator emailTemplate
+}
+
+var templates = map[string]localeTemplates{
+	LocaleRu: {
+		student: newEmailTemplate("student-ru",
+			`Низкая посещаемость за период {{.Period}}`,
+			`Здравствуйте, {{.Name}}!
+
+За период {{.Period}} у вас обнаружены проблемы с посещаемостью:
+{{range .Reasons}}
+ - {{.}}{{end}}
+
+Пожалуйста, свяжитесь с куратором группы {{.Group}}, чтобы наверстать пропущенные занятия.
+`),
+		curator: newEmailTemplate("curator-ru",
+			`Группа {{.Group}}: студенты с низкой посещаемостью за {{.Period}}`,
+			`Здравствуйте!
+
+В группе {{.Group}} за период {{.Period}} у следующих студентов обнаружены проблемы с посещаемостью:
+{{range .Students}}
+{{.Name}}:{{range .Reasons}}
+ - {{.}}{{end}}
+{{end}}`),
+	},
+	LocaleEn: {
+		student: newEmailTemplate("student-en",
+			`Low attendance for {{.Period}}`,
+			`Hello {{.Name}},
+
+We noticed attendance issues for the period {{.Period}}:
+{{range .Reasons}}
+ - {{.}}{{end}}
+
+Please contact the curator of group {{.Group}} to catch up on missed lessons.
+`),
+		curator: newEmailTemplate("curator-en",
+			`Group {{.Group}}: students with low attendance for {{.Period}}`,
+			`Hello,
+
+The following students of group {{.Group}} have attendance issues for the period {{.Period}}:
+{{range .Students}}
+{{.Name}}:{{range .Reasons}}
+ - {{.}}{{end}}
+{{end}}`),
+	},
+}
+
+func describeTriggers(locale string, triggers []accounting.RuleTrigger) []string {
+	reasons := make([]string, 0, len(triggers))
+	for _, trigger := range triggers {
+		reasons = append(reasons, describeTrigger(locale, trigger))
+	}
+	return reasons
+}
+
+func describeTrigger(locale string, trigger accounting.RuleTrigger) string {
+	e := trigger.Evidence
+	switch trigger.Type {
+	case accounting.RiskRuleSpecialHours:
+		if locale == LocaleEn {
+			return fmt.Sprintf("attended %v of %v hours of special disciplines (%.0f%%, minimum %.0f%%)",
+				e["attended_hours"], e["planned_hours"], e["percent"], e["min_percent"])
+		}
+		return fmt.Sprintf("посещено %v из %v часов специальных дисциплин (%.0f%%, минимум %.0f%%)",
+			e["attended_hours"], e["planned_hours"], e["percent"], e["min_percent"])
+	case accounting.RiskRuleConsecutiveAbsences:
+		if locale == LocaleEn {
+			return fmt.Sprintf("%v lessons missed in a row from %v to %v", e["absences"], e["from"], e["to"])
+		}
+		return fmt.Sprintf("%v пропусков подряд с %v по %v", e["absences"], e["from"], e["to"])
+	case accounting.RiskRuleRateDrop:
+		if locale == LocaleEn {
+			return fmt.Sprintf("attendance dropped from %.0f%% to %.0f%% over the last %v days",
+				e["previous_rate"], e["recent_rate"], e["window_days"])
+		}
+		return fmt.Sprintf("посещаемость снизилась с %.0f%% до %.0f%% за последние %v дней",
+			e["previous_rate"], e["recent_rate"], e["window_days"])
+	}
+	return trigger.Rule
+}
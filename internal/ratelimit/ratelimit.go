@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const (
+	ClassDefault = "default"
+	// ClassReport covers the endpoints that generate reports synchronously
+	// and hit every store on each call.
+	ClassReport = "report"
+	// ClassIP is checked per client IP before authentication, so callers
+	// with bad credentials are limited too. It is looser than the route
+	// classes since users behind one NAT share it.
+	ClassIP = "ip"
+)
+
+type Config struct {
+	Enabled bool             `json:"enabled"`
+	Classes map[string]Limit `json:"classes"`
+}
+
+// Limit is a token bucket holding Requests tokens that refills completely
+// over PeriodSec, so a client may burst Requests calls and then sustain
+// Requests per PeriodSec.
+type Limit struct {
+	Requests  int `json:"requests"`
+	PeriodSec int `json:"period_sec"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled: true,
+		Classes: map[string]Limit{
+			ClassDefault: {Requests: 120, PeriodSec: 60},
+			ClassReport:  {Requests: 10, PeriodSec: 60},
+			ClassIP:      {Requests: 600, PeriodSec: 60},
+		},
+	}
+}
+
+func ValidateConfig(cfg Config) error {
+	if _, ok := cfg.Classes[ClassDefault]; !ok {
+		return fmt.Errorf("class %q must be configured", ClassDefault)
+	}
+	for name, limit := range cfg.Classes {
+		if limit.Requests <= 0 || limit.PeriodSec <= 0 {
+			return fmt.Errorf("class %q needs positive requests and period_sec", name)
+		}
+	}
+	return nil
+}
+
+// tokenBucketScript refills and takes one token atomically. The clock is the
+// Redis server's, so replicas with skewed clocks share one bucket correctly.
+// It returns whether the call is allowed, the tokens left, the milliseconds
+// until a token is available and until the bucket is full again.
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local period_ms = tonumber(ARGV[2])
+local rate = capacity / period_ms
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry_ms = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_ms = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period_ms)
+
+return {allowed, math.floor(tokens), retry_ms, math.ceil((capacity - tokens) / rate)}
+`)
+
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// RetryAfter is how long to wait for the next token, zero when allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type Limiter struct {
+	redisClient *redis.Client
+	cfg         Config
+}
+
+func NewLimiter(redisClient *redis.Client, cfg Config) *Limiter {
+	return &Limiter{
+		redisClient: redisClient,
+		cfg:         cfg,
+	}
+}
+
+func (l *Limiter) Enabled() bool {
+	return l.cfg.Enabled
+}
+
+// Allow takes a token from the client's bucket of the class. Unknown classes
+// fall back to the default one.
+func (l *Limiter) Allow(ctx context.Context, class, client string) (*Result, error) {
+	limit, ok := l.cfg.Classes[class]
+	if !ok {
+		class = ClassDefault
+		limit = l.cfg.Classes[ClassDefault]
+	}
+
+	key := fmt.Sprintf("ratelimit:%s:%s", class, client)
+	periodMs := int64(limit.PeriodSec) * 1000
+	values, err := tokenBucketScript.Run(ctx, l.redisClient, []string{key}, limit.Requests, periodMs).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %v", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		n, ok := v.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected rate limit script result %v", values)
+		}
+		ints[i] = n
+	}
+
+	return &Result{
+		Allowed:    ints[0] == 1,
+		Limit:      limit,
+		Remaining:  int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Millisecond,
+		Reset:      time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Actions applied to a field value.
+const (
+	ActionKeep = "keep"
+	// ActionMask keeps the first character, and the domain of an email.
+	ActionMask = "mask"
+	// ActionHash replaces the value with a salted hash, so equal values can
+	// still be matched across reports.
+	ActionHash = "hash"
+	ActionOmit = "omit"
+	// ActionYear truncates a YYYY-MM-DD date to its year.
+	ActionYear = "year"
+)
+
+var actions = map[string]struct{}{
+	ActionKeep: {},
+	ActionMask: {},
+	ActionHash: {},
+	ActionOmit: {},
+	ActionYear: {},
+}
+
+// Policy maps a JSON field name to the action applied to it wherever it
+// appears in a response. Fields not listed are kept.
+type Policy map[string]string
+
+type Config struct {
+	Policies map[string]Policy `json:"policies"`
+	// Roles selects the policy of each caller role.
+	Roles map[string]string `json:"roles"`
+	// Default is the policy of roles not listed in Roles.
+	Default  string `json:"default"`
+	HashSalt string `json:"hash_salt"`
+}
+
+const (
+	PolicyNone       = "none"
+	PolicyContacts   = "contacts-hidden"
+	PolicyAnonymized = "anonymized"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Policies: map[string]Policy{
+			PolicyNone:       {},
+			PolicyContacts:   {"email": ActionMask, "birth": ActionYear},
+			PolicyAnonymized: {"email": ActionOmit, "birth": ActionOmit},
+		},
+		Roles: map[string]string{
+			"admin":           PolicyNone,
+			"department_head": PolicyNone,
+			"curator":         PolicyNone,
+			"student":         PolicyNone,
+			"teacher":         PolicyContacts,
+		},
+		Default: PolicyAnonymized,
+	}
+}
+
+// placeholderSalt is the value older example configs shipped with.
+const placeholderSalt = "change-me"
+
+func ValidateConfig(cfg Config) error {
+	if _, ok := cfg.Policies[cfg.Default]; !ok {
+		return fmt.Errorf("default policy %q is not defined", cfg.Default)
+	}
+	for role, name := range cfg.Roles {
+		if _, ok := cfg.Policies[name]; !ok {
+			return fmt.Errorf("role %q uses undefined policy %q", role, name)
+		}
+	}
+	for name, policy := range cfg.Policies {
+		for field, action := range policy {
+			if _, ok := actions[action]; !ok {
+				return fmt.Errorf("policy %q: unknown action %q for field %q", name, action, field)
+			}
+			// A known salt lets anyone hash candidate values and match
+			// them against the report.
+			if action == ActionHash && (cfg.HashSalt == "" || cfg.HashSalt == placeholderSalt) {
+				return fmt.Errorf("policy %q hashes field %q, hash_salt must be set to a secret value", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+type Redactor struct {
+	cfg Config
+}
+
+func NewRedactor(cfg Config) *Redactor {
+	return &Redactor{cfg: cfg}
+}
+
+// PolicyFor returns the name of the policy applied to the role.
+func (r *Redactor) PolicyFor(role string) string {
+	if name, ok := r.cfg.Roles[role]; ok {
+		return name
+	}
+	return r.cfg.Default
+}
+
+// Marshal encodes v as JSON with the named policy applied to every object in
+// it, however deeply nested.
+func (r *Redactor) Marshal(policyName string, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := r.cfg.Policies[policyName]
+	if !policy.redacts() {
+		return raw, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	r.walk(policy, tree)
+	return json.Marshal(tree)
+}
+
+// Value applies the named policy to a single field value, for callers that
+// build responses field by field. It returns false when the field is omitted.
+func (r *Redactor) Value(policyName, field, value string) (string, bool) {
+	action := r.cfg.Policies[policyName][field]
+	switch action {
+	case "", ActionKeep:
+		return value, true
+	case ActionOmit:
+		return "", false
+	default:
+		return r.apply(action, value), true
+	}
+}
+
+func (p Policy) redacts() bool {
+	for _, action := range p {
+		if action != ActionKeep {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) walk(policy Policy, node interface{}) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			action, ok := policy[key]
+			if !ok {
+				r.walk(policy, value)
+				continue
+			}
+			s, isString := value.(string)
+			if !isString {
+				// Only scalar string fields are redacted, an object that
+				// happens to share the name is searched instead.
+				if action == ActionOmit && value == nil {
+					delete(n, key)
+				} else {
+					r.walk(policy, value)
+				}
+				continue
+			}
+			if action == ActionOmit {
+				delete(n, key)
+			} else {
+				n[key] = r.apply(action, s)
+			}
+		}
+	case []interface{}:
+		for _, value := range n {
+			r.walk(policy, value)
+		}
+	}
+}
+
+func (r *Redactor) apply(action, value string) string {
+	if value == "" {
+		return value
+	}
+
+	switch action {
+	case ActionMask:
+		return mask(value)
+	case ActionHash:
+		mac := hmac.New(sha256.New, []byte(r.cfg.HashSalt))
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	case ActionYear:
+		if len(value) >= 4 {
+			return value[:4]
+		}
+		return ""
+	default:
+		return value
+	}
+}
+
+func mask(value string) string {
+	local, domain, isEmail := strings.Cut(value, "@")
+	first := []rune(local)
+	if len(first) == 0 {
+		return "***"
+	}
+	if isEmail {
+		return string(first[0]) + "***@" + domain
+	}
+	return string(first[0]) + "***"
+}
@@ -0,0 +1,527 @@
+package reportjob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Workers   int `json:"workers"`
+	QueueSize int `json:"queue_size"`
+	// ResultTTLSec is how long a finished job and its result stay available.
+	// Unfinished jobs are kept for the same time after their last update.
+	ResultTTLSec int `json:"result_ttl_sec"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:      4,
+		QueueSize:    100,
+		ResultTTLSec: 24 * 60 * 60,
+	}
+}
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	ErrJobNotFound = errors.New("report job not found")
+	ErrJobFinished = errors.New("report job already finished")
+	ErrQueueFull   = errors.New("report job queue is full")
+
+	// errStatusChanged stops an update of a job whose status another worker
+	// or replica changed meanwhile.
+	errStatusChanged = errors.New("report job status changed")
+)
+
+type Job struct {
+	ID         string            `json:"id"`
+	ReportType string            `json:"type"`
+	Params     map[string]string `json:"params"`
+	// Owner is the subject that submitted the job. The report is limited to
+	// the submitter's Scope.
+	Owner      string           `json:"owner,omitempty"`
+	Scope      accounting.Scope `json:"scope"`
+	Status     string           `json:"status"`
+	Progress   float64          `json:"progress"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	ExpiresAt  time.Time        `json:"expires_at"`
+	Result     json.RawMessage  `json:"result,omitempty"`
+}
+
+func (j *Job) finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// Manager runs report jobs on a bounded pool of workers. Job state lives in
+// Redis so any replica can answer status queries and cancel a job, while the
+// job itself runs on the replica that accepted it.
+type Manager struct {
+	accountingClient *accounting.Client
+	redisClient      *redis.Client
+	cfg              Config
+	queue            chan string
+	workers          sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+func NewManager(accountingClient *accounting.Client, redisClient *redis.Client, cfg Config) *Manager {
+	return &Manager{
+		accountingClient: accountingClient,
+		redisClient:      redisClient,
+		cfg:              cfg,
+		queue:            make(chan string, cfg.QueueSize),
+		running:          make(map[string]context.CancelFunc),
+	}
+}
+
+// Start recovers the jobs left unfinished by stopped replicas and launches
+// the workers. They stop when ctx is cancelled, interrupting the jobs in
+// progress.
+func (m *Manager) Start(ctx context.Context) {
+	if err := m.recover(ctx); err != nil {
+		logrus.Errorf("failed to recover report jobs: %v", err)
+	}
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.workers.Add(1)
+		go func() {
+			defer m.workers.Done()
+			m.work(ctx)
+		}()
+	}
+}
+
+// Wait blocks until the workers have stopped and saved the state of the jobs
+// they interrupted.
+func (m *Manager) Wait() {
+	m.workers.Wait()
+}
+
+// Submit queues a report on behalf of owner. The parameters must already be
+// valid for the report type.
+func (m *Manager) Submit(owner string, scope accounting.Scope, reportType string, params map[string]string) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:         id,
+		ReportType: reportType,
+		Params:     params,
+		Owner:      owner,
+		Scope:      scope,
+		Status:     StatusQueued,
+		CreatedAt:  now,
+	}
+	if err := m.save(context.Background(), job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case m.queue <- id:
+		return job, nil
+	default:
+		_ = m.redisClient.Del(context.Background(), jobKey(id)).Err()
+		return nil, ErrQueueFull
+	}
+}
+
+func (m *Manager) Get(id string) (*Job, error) {
+	return m.load(context.Background(), id)
+}
+
+// Cancel marks the job for cancellation. A queued job is cancelled right away,
+// a running one stops at its next progress step on whichever replica runs it.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	ctx := context.Background()
+
+	// A worker may start the job concurrently, the transaction makes sure
+	// exactly one of the two status changes wins.
+	job, err := m.update(ctx, id, func(job *Job) error {
+		if job.finished() {
+			return ErrJobFinished
+		}
+		if job.Status == StatusQueued {
+			m.finish(job, StatusCancelled, nil, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == StatusCancelled {
+		return job, nil
+	}
+
+	if err := m.redisClient.Set(ctx, cancelKey(id), 1, m.ttl()).Err(); err != nil {
+		return nil, fmt.Errorf("failed to cancel report job: %v", err)
+	}
+
+	m.mu.Lock()
+	cancel, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return job, nil
+}
+
+// recover handles the jobs a stopped replica left behind. Queued jobs are
+// queued again here, running jobs whose replica no longer holds their lease
+// are failed, since the partial report is lost.
+func (m *Manager) recover(ctx context.Context) error {
+	var recovered, failed int
+	iter := m.redisClient.Scan(ctx, 0, jobKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		id := strings.TrimPrefix(iter.Val(), jobKey(""))
+		if strings.Contains(id, ":") {
+			// The cancel and lease keys of a job.
+			continue
+		}
+		job, err := m.load(ctx, id)
+		if err != nil {
+			if !errors.Is(err, ErrJobNotFound) {
+				logrus.Errorf("failed to load report job %s: %v", id, err)
+			}
+			continue
+		}
+
+		switch job.Status {
+		case StatusQueued:
+			select {
+			case m.queue <- id:
+				recovered++
+				continue
+			default:
+			}
+		case StatusRunning:
+			leased, err := m.redisClient.Exists(ctx, leaseKey(id)).Result()
+			if err != nil {
+				logrus.Errorf("failed to check the lease of report job %s: %v", id, err)
+				continue
+			}
+			if leased > 0 {
+				continue
+			}
+		default:
+			continue
+		}
+
+		// The job changes status only if no replica touched it meanwhile.
+		status := job.Status
+		_, err = m.update(ctx, id, func(job *Job) error {
+			if job.Status != status {
+				return errStatusChanged
+			}
+			if status == StatusQueued {
+				m.finish(job, StatusFailed, nil, ErrQueueFull)
+			} else {
+				m.finish(job, StatusFailed, nil, errors.New("service stopped before the report finished"))
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			failed++
+		case !errors.Is(err, errStatusChanged):
+			logrus.Errorf("failed to fail report job %s: %v", id, err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan report jobs: %v", err)
+	}
+
+	if recovered > 0 || failed > 0 {
+		logrus.Infof("Recovered report jobs: %d queued again, %d failed", recovered, failed)
+	}
+	return nil
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(ctx, id)
+		}
+	}
+}
+
+func (m *Manager) run(ctx context.Context, id string) {
+	if m.cancelRequested(ctx, id) {
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The lease is taken before the job is marked running, so a replica
+	// recovering jobs never sees it running without one.
+	stopLease, err := m.lease(jobCtx, id)
+	if err != nil {
+		logrus.Errorf("failed to lease report job %s: %v", id, err)
+		return
+	}
+	defer stopLease()
+
+	job, err := m.update(ctx, id, func(job *Job) error {
+		if job.Status != StatusQueued {
+			return errStatusChanged
+		}
+		startedAt := time.Now().UTC()
+		job.Status = StatusRunning
+		job.StartedAt = &startedAt
+		return nil
+	})
+	if errors.Is(err, errStatusChanged) || errors.Is(err, ErrJobNotFound) {
+		return
+	}
+	if err != nil {
+		logrus.Errorf("failed to start report job %s: %v", id, err)
+		return
+	}
+
+	m.mu.Lock()
+	m.running[id] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, id)
+		m.mu.Unlock()
+	}()
+
+	var lastUpdate time.Time
+	progress := func(done, total int) {
+		if total <= 0 || time.Since(lastUpdate) < progressUpdateInterval {
+			return
+		}
+		lastUpdate = time.Now()
+		if m.cancelRequested(ctx, id) {
+			cancel()
+			return
+		}
+		job.Progress = float64(done) / float64(total)
+		if err := m.save(ctx, job); err != nil {
+			logrus.Errorf("failed to update report job %s: %v", id, err)
+		}
+	}
+
+	result, err := m.accountingClient.RunReportContext(jobCtx, job.Scope, job.ReportType, job.Params, progress)
+	switch {
+	case jobCtx.Err() != nil && m.cancelRequested(ctx, id):
+		m.finish(job, StatusCancelled, nil, nil)
+	case ctx.Err() != nil:
+		m.finish(job, StatusFailed, nil, errors.New("service stopped before the report finished"))
+	case err != nil:
+		m.finish(job, StatusFailed, nil, err)
+	default:
+		m.finish(job, StatusSucceeded, result, nil)
+	}
+
+	// The service context may be done already, the final state still has to
+	// reach Redis.
+	if err := m.save(context.Background(), job); err != nil {
+		logrus.Errorf("failed to save report job %s: %v", id, err)
+	}
+}
+
+const progressUpdateInterval = 500 * time.Millisecond
+
+// leaseTTL is how long a running job survives the replica running it before
+// another replica fails it on start.
+const leaseTTL = 30 * time.Second
+
+// lease marks the job as owned by this replica until the returned function is
+// called, refreshing the mark while the job runs.
+func (m *Manager) lease(ctx context.Context, id string) (func(), error) {
+	if err := m.redisClient.Set(ctx, leaseKey(id), 1, leaseTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.redisClient.Expire(context.Background(), leaseKey(id), leaseTTL).Err(); err != nil {
+					logrus.Errorf("failed to renew the lease of report job %s: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		_ = m.redisClient.Del(context.Background(), leaseKey(id)).Err()
+	}, nil
+}
+
+func (m *Manager) finish(job *Job, status string, result interface{}, err error) {
+	finishedAt := time.Now().UTC()
+	job.Status = status
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if result != nil {
+		raw, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			job.Status = StatusFailed
+			job.Error = fmt.Sprintf("failed to marshal report: %v", marshalErr)
+		} else {
+			job.Result = raw
+		}
+	}
+	if status == StatusSucceeded && job.Error == "" {
+		job.Progress = 1
+	}
+}
+
+func (m *Manager) cancelRequested(ctx context.Context, id string) bool {
+	n, err := m.redisClient.Exists(ctx, cancelKey(id)).Result()
+	if err != nil {
+		logrus.Errorf("failed to check cancellation of report job %s: %v", id, err)
+		return false
+	}
+	return n > 0
+}
+
+func (m *Manager) save(ctx context.Context, job *Job) error {
+	data, err := m.marshal(job)
+	if err != nil {
+		return err
+	}
+	if err := m.redisClient.Set(ctx, jobKey(job.ID), data, m.ttl()).Err(); err != nil {
+		return fmt.Errorf("failed to save report job: %v", err)
+	}
+	return nil
+}
+
+// maxUpdateAttempts bounds the retries of an update that keeps losing to
+// concurrent writers.
+const maxUpdateAttempts = 10
+
+// update applies fn to the stored job and saves it in one transaction. It is
+// retried when the job changes between the read and the write, and saves
+// nothing when fn fails.
+func (m *Manager) update(ctx context.Context, id string, fn func(job *Job) error) (*Job, error) {
+	var job *Job
+	txf := func(tx *redis.Tx) error {
+		var err error
+		job, err = m.loadFrom(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(job); err != nil {
+			return err
+		}
+		data, err := m.marshal(job)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, jobKey(id), data, m.ttl())
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := m.redisClient.Watch(ctx, txf, jobKey(id))
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	return nil, fmt.Errorf("failed to update report job %s: too many concurrent updates", id)
+}
+
+func (m *Manager) marshal(job *Job) ([]byte, error) {
+	job.ExpiresAt = time.Now().UTC().Add(m.ttl())
+	data, err := json.Marshal(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report job: %v", err)
+	}
+	return data, nil
+}
+
+func (m *Manager) load(ctx context.Context, id string) (*Job, error) {
+	return m.loadFrom(ctx, m.redisClient, id)
+}
+
+type getter interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+}
+
+func (m *Manager) loadFrom(ctx context.Context, r getter, id string) (*Job, error) {
+	data, err := r.Get(ctx, jobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report job: %v", err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report job: %v", err)
+	}
+	return &job, nil
+}
+
+func (m *Manager) ttl() time.Duration {
+	return time.Duration(m.cfg.ResultTTLSec) * time.Second
+}
+
+func jobKey(id string) string {
+	return fmt.Sprintf("report-job:%s", id)
+}
+
+func cancelKey(id string) string {
+	return fmt.Sprintf("report-job:%s:cancel", id)
+}
+
+func leaseKey(id string) string {
+	return fmt.Sprintf("report-job:%s:lease", id)
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@every",
+	}
+	for _, expr := range tests {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	date := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"*/15 * * * *", "2024-01-01 10:07", "2024-01-01 10:15"},
+		{"*/15 * * * *", "2024-01-01 10:45", "2024-01-01 11:00"},
+		{"5-59/20 * * * *", "2024-01-01 10:00", "2024-01-01 10:05"},
+		{"5/20 * * * *", "2024-01-01 10:26", "2024-01-01 10:45"},
+		{"0 9 * * 1-5", "2024-01-06 12:00", "2024-01-08 09:00"},
+		{"30 8 1,15 * *", "2024-01-02 00:00", "2024-01-15 08:30"},
+		{"0 0 1 */3 *", "2024-02-10 00:00", "2024-04-01 00:00"},
+		// Sunday as 7.
+		{"0 0 * * 7", "2024-01-01 00:00", "2024-01-07 00:00"},
+		// Both day fields restricted: either one matches.
+		{"0 0 13 * 5", "2024-01-01 00:00", "2024-01-05 00:00"},
+		// A day field starting with * is unrestricted, so both must match:
+		// the first odd day that is a Monday.
+		{"0 0 */2 * 1", "2024-01-01 00:00", "2024-01-15 00:00"},
+		{"0 0 1 * */2", "2024-01-01 00:00", "2024-02-01 00:00"},
+		{"@daily", "2024-01-01 10:00", "2024-01-02 00:00"},
+		{"@hourly", "2024-01-01 10:00", "2024-01-01 11:00"},
+		{"@weekly", "2024-01-01 10:00", "2024-01-07 00:00"},
+		{"@monthly", "2024-01-31 23:59", "2024-02-01 00:00"},
+		{"@yearly", "2024-06-01 00:00", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.next(date(tt.from)); !got.Equal(date(tt.want)) {
+			t.Errorf("%q after %s: got %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestCronNextNone(t *testing.T) {
+	schedule, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schedule.next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("got %s, want no match", got)
+	}
+}
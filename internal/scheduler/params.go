@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Job parameters are text/template strings evaluated at run time, so a
+// recurring job can ask for a moving period:
+//
+//	{"startDate": "{{daysAgo 7}}", "endDate": "{{today}}"}
+//	{"year": "{{academicYear}}", "sem": "{{semester}}"}
+//
+// Dates are formatted as YYYY-MM-DD. The academic year and semester follow
+// the course report: semester 1 runs September-December of the academic
+// year, semester 2 runs the following March-August.
+func paramFuncs(now time.Time) template.FuncMap {
+	date := func(t time.Time) string { return t.Format("2006-01-02") }
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	academicYear, semester := now.Year(), 1
+	if now.Month() < time.September {
+		academicYear, semester = now.Year()-1, 2
+	}
+
+	return template.FuncMap{
+		"today":   func() string { return date(day) },
+		"daysAgo": func(n int) string { return date(day.AddDate(0, 0, -n)) },
+		"weekStart": func() string {
+			offset := (int(day.Weekday()) + 6) % 7
+			return date(day.AddDate(0, 0, -offset))
+		},
+		"monthStart":   func() string { return date(day.AddDate(0, 0, 1-day.Day())) },
+		"academicYear": func() int { return academicYear },
+		"semester":     func() int { return semester },
+		"semesterStart": func() string {
+			if semester == 1 {
+				return fmt.Sprintf("%d-09-01", academicYear)
+			}
+			return fmt.Sprintf("%d-03-01", academicYear+1)
+		},
+	}
+}
+
+func renderParams(params map[string]string, now time.Time) (map[string]string, error) {
+	funcs := paramFuncs(now)
+	rendered := make(map[string]string, len(params))
+	for name, value := range params {
+		if !strings.Contains(value, "{{") {
+			rendered[name] = value
+			continue
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q: %v", name, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, nil); err != nil {
+			return nil, fmt.Errorf("invalid parameter %q: %v", name, err)
+		}
+		rendered[name] = buf.String()
+	}
+	return rendered, nil
+}
@@ -0,0 +1,307 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Enabled bool        `json:"enabled"`
+	Jobs    []JobConfig `json:"jobs"`
+	// LockTTLSec bounds how long a replica holds a job run lock, it should
+	// exceed the longest report run.
+	LockTTLSec int `json:"lock_ttl_sec"`
+	// ResultTTLSec is how long the last outcome and result of a job are kept.
+	ResultTTLSec int `json:"result_ttl_sec"`
+}
+
+type JobConfig struct {
+	Name     string            `json:"name"`
+	Schedule string            `json:"schedule"`
+	Report   string            `json:"report"`
+	Params   map[string]string `json:"params"`
+	// Snapshot also archives every successful result as a report snapshot.
+	Snapshot bool `json:"snapshot"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		LockTTLSec:   30 * 60,
+		ResultTTLSec: 30 * 24 * 60 * 60,
+	}
+}
+
+func ValidateConfig(cfg Config) error {
+	names := make(map[string]struct{}, len(cfg.Jobs))
+	for _, job := range cfg.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("job name must not be empty")
+		}
+		if _, ok := names[job.Name]; ok {
+			return fmt.Errorf("duplicate job %q", job.Name)
+		}
+		names[job.Name] = struct{}{}
+
+		if _, err := parseCron(job.Schedule); err != nil {
+			return fmt.Errorf("job %q: %v", job.Name, err)
+		}
+		params, err := renderParams(job.Params, time.Now())
+		if err != nil {
+			return fmt.Errorf("job %q: %v", job.Name, err)
+		}
+		if err := accounting.ValidateReportParams(job.Report, params); err != nil {
+			return fmt.Errorf("job %q: %v", job.Name, err)
+		}
+	}
+	if cfg.LockTTLSec <= 0 {
+		return fmt.Errorf("lock_ttl_sec must be positive")
+	}
+	return nil
+}
+
+type job struct {
+	cfg      JobConfig
+	schedule *cronSchedule
+	next     time.Time
+}
+
+type Scheduler struct {
+	accountingClient *accounting.Client
+	redisClient      *redis.Client
+	cfg              Config
+	replica          string
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+func NewScheduler(accountingClient *accounting.Client, redisClient *redis.Client, cfg Config) (*Scheduler, error) {
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	replica, _ := os.Hostname()
+	s := &Scheduler{
+		accountingClient: accountingClient,
+		redisClient:      redisClient,
+		cfg:              cfg,
+		replica:          replica,
+	}
+
+	now := time.Now()
+	for _, jobCfg := range cfg.Jobs {
+		schedule, _ := parseCron(jobCfg.Schedule)
+		s.jobs = append(s.jobs, &job{cfg: jobCfg, schedule: schedule, next: schedule.next(now)})
+	}
+
+	return s, nil
+}
+
+// Run fires jobs at their scheduled times until ctx is cancelled. Every
+// replica runs the loop, a Redis lock per job and fire time lets only one of
+// them execute the report.
+func (s *Scheduler) Run(ctx context.Context) {
+	if !s.cfg.Enabled || len(s.jobs) == 0 {
+		return
+	}
+	logrus.Infof("Scheduler was started with %d jobs", len(s.jobs))
+
+	for {
+		s.mu.Lock()
+		var wakeAt time.Time
+		for _, j := range s.jobs {
+			if !j.next.IsZero() && (wakeAt.IsZero() || j.next.Before(wakeAt)) {
+				wakeAt = j.next
+			}
+		}
+		s.mu.Unlock()
+		if wakeAt.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(wakeAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for _, j := range s.jobs {
+			if j.next.IsZero() || j.next.After(now) {
+				continue
+			}
+			go s.fire(ctx, j.cfg, j.next)
+			j.next = j.schedule.next(now)
+		}
+		s.mu.Unlock()
+	}
+}
+
+type RunOutcome struct {
+	ScheduledAt time.Time         `json:"scheduled_at"`
+	StartedAt   time.Time         `json:"started_at"`
+	FinishedAt  time.Time         `json:"finished_at"`
+	Status      string            `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Params      map[string]string `json:"params"`
+	Replica     string            `json:"replica"`
+	SnapshotID  string            `json:"snapshot_id,omitempty"`
+}
+
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+func (s *Scheduler) fire(ctx context.Context, jobCfg JobConfig, scheduledAt time.Time) {
+	lockKey := fmt.Sprintf("scheduler:lock:%s:%d", jobCfg.Name, scheduledAt.Unix())
+	acquired, err := s.redisClient.SetNX(ctx, lockKey, s.replica, time.Duration(s.cfg.LockTTLSec)*time.Second).Result()
+	if err != nil {
+		logrus.Errorf("failed to acquire lock for job %s: %v", jobCfg.Name, err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	outcome := RunOutcome{
+		ScheduledAt: scheduledAt,
+		StartedAt:   time.Now(),
+		Replica:     s.replica,
+	}
+
+	var result interface{}
+	params, err := renderParams(jobCfg.Params, scheduledAt)
+	if err == nil {
+		outcome.Params = params
+		result, err = s.accountingClient.RunReport(jobCfg.Report, params)
+	}
+	outcome.FinishedAt = time.Now()
+
+	if err != nil {
+		logrus.Errorf("scheduled job %s failed: %v", jobCfg.Name, err)
+		outcome.Status = RunStatusFailed
+		outcome.Error = err.Error()
+	} else {
+		logrus.Infof("scheduled job %s finished in %s", jobCfg.Name, outcome.FinishedAt.Sub(outcome.StartedAt))
+		outcome.Status = RunStatusSucceeded
+		if err := s.store(ctx, resultKey(jobCfg.Name), result); err != nil {
+			logrus.Errorf("failed to store result of job %s: %v", jobCfg.Name, err)
+		}
+		if jobCfg.Snapshot {
+			snapshot, err := s.accountingClient.SaveSnapshot(jobCfg.Report, params, "scheduler:"+jobCfg.Name, accounting.SnapshotOwner{}, result)
+			if err != nil {
+				logrus.Errorf("failed to save snapshot of job %s: %v", jobCfg.Name, err)
+			} else {
+				outcome.SnapshotID = snapshot.ID.Hex()
+			}
+		}
+	}
+
+	if err := s.store(ctx, outcomeKey(jobCfg.Name), outcome); err != nil {
+		logrus.Errorf("failed to store outcome of job %s: %v", jobCfg.Name, err)
+	}
+}
+
+func (s *Scheduler) store(ctx context.Context, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(ctx, key, data, time.Duration(s.cfg.ResultTTLSec)*time.Second).Err()
+}
+
+func outcomeKey(name string) string {
+	return fmt.Sprintf("scheduler:outcome:%s", name)
+}
+
+func resultKey(name string) string {
+	return fmt.Sprintf("scheduler:result:%s", name)
+}
+
+type JobStatus struct {
+	JobConfig
+	NextRun *time.Time  `json:"next_run"`
+	LastRun *RunOutcome `json:"last_run"`
+}
+
+// Jobs lists the configured jobs with their next run time and the outcome of
+// the last run on any replica.
+func (s *Scheduler) Jobs() ([]JobStatus, error) {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := JobStatus{JobConfig: j.cfg}
+		if s.cfg.Enabled && !j.next.IsZero() {
+			next := j.next
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	s.mu.Unlock()
+
+	for i := range statuses {
+		data, err := s.redisClient.Get(ctx, outcomeKey(statuses[i].Name)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get outcome of job %s: %v", statuses[i].Name, err)
+		}
+		var outcome RunOutcome
+		if err := json.Unmarshal(data, &outcome); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outcome of job %s: %v", statuses[i].Name, err)
+		}
+		statuses[i].LastRun = &outcome
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
+
+var ErrJobNotFound = errors.New("job not found")
+
+// LastResult returns the raw JSON result of the last successful run of the
+// job, or nil if there is none.
+func (s *Scheduler) LastResult(name string) ([]byte, error) {
+	if !s.hasJob(name) {
+		return nil, ErrJobNotFound
+	}
+
+	data, err := s.redisClient.Get(context.Background(), resultKey(name)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get result of job %s: %v", name, err)
+	}
+	return data, nil
+}
+
+func (s *Scheduler) hasJob(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.cfg.Name == name {
+			return true
+		}
+	}
+	return false
+}
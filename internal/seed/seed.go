@@ -0,0 +1,498 @@
+// Package seed generates a synthetic university and writes it to every store
+// the service reads, with the same IDs everywhere, for demos and load tests.
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Config struct {
+	// Seed makes the data reproducible: the same config always generates
+	// the same university.
+	Seed                int64 `json:"seed"`
+	Departments         int   `json:"departments"`
+	GroupsPerDepartment int   `json:"groups_per_department"`
+	StudentsPerGroup    int   `json:"students_per_group"`
+	Disciplines         int   `json:"disciplines"`
+	// DisciplinesPerGroup are split between the two semesters.
+	DisciplinesPerGroup  int `json:"disciplines_per_group"`
+	LessonsPerDiscipline int `json:"lessons_per_discipline"`
+	MaterialsPerLesson   int `json:"materials_per_lesson"`
+	// TeachersPerDepartment share the lessons of every discipline, one
+	// giving its lectures and another its practice and laboratory lessons.
+	TeachersPerDepartment int     `json:"teachers_per_department"`
+	SpecialShare          float64 `json:"special_share"`
+	// AtRiskShare of the students attend much less than the others.
+	AtRiskShare float64 `json:"at_risk_share"`
+	// Year is the first year of the academic year the schedule covers.
+	Year int `json:"year"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Seed:                  1,
+		Departments:           3,
+		GroupsPerDepartment:   3,
+		StudentsPerGroup:      20,
+		Disciplines:           10,
+		DisciplinesPerGroup:   6,
+		LessonsPerDiscipline:  12,
+		MaterialsPerLesson:    2,
+		TeachersPerDepartment: 4,
+		SpecialShare:          0.2,
+		AtRiskShare:           0.1,
+		Year:                  2025,
+	}
+}
+
+// maxLessonsPerDiscipline keeps weekly lessons within a semester.
+const maxLessonsPerDiscipline = 16
+
+func ValidateConfig(cfg Config) error {
+	if cfg.Departments <= 0 || cfg.Departments > len(departmentNames) {
+		return fmt.Errorf("departments must be between 1 and %d", len(departmentNames))
+	}
+	if cfg.Disciplines <= 0 || cfg.Disciplines > len(disciplineTemplates) {
+		return fmt.Errorf("disciplines must be between 1 and %d", len(disciplineTemplates))
+	}
+	if cfg.GroupsPerDepartment <= 0 || cfg.StudentsPerGroup <= 0 {
+		return fmt.Errorf("groups_per_department and students_per_group must be positive")
+	}
+	if cfg.DisciplinesPerGroup <= 0 || cfg.DisciplinesPerGroup > cfg.Disciplines {
+		return fmt.Errorf("disciplines_per_group must be between 1 and disciplines")
+	}
+	if cfg.LessonsPerDiscipline <= 0 || cfg.LessonsPerDiscipline > maxLessonsPerDiscipline {
+		return fmt.Errorf("lessons_per_discipline must be between 1 and %d", maxLessonsPerDiscipline)
+	}
+	if cfg.MaterialsPerLesson < 0 {
+		return fmt.Errorf("materials_per_lesson must not be negative")
+	}
+	if cfg.TeachersPerDepartment <= 0 {
+		return fmt.Errorf("teachers_per_department must be positive")
+	}
+	if cfg.SpecialShare < 0 || cfg.SpecialShare > 1 || cfg.AtRiskShare < 0 || cfg.AtRiskShare > 1 {
+		return fmt.Errorf("special_share and at_risk_share must be between 0 and 1")
+	}
+	if cfg.Year < 2000 {
+		return fmt.Errorf("year must be 2000 or later")
+	}
+	return nil
+}
+
+type Department struct {
+	ID   int
+	Name string
+}
+
+type Group struct {
+	ID           int
+	Name         string
+	DepartmentID int
+	Course       int
+	CuratorEmail string
+}
+
+type Student struct {
+	ID      int
+	CardID  string
+	GroupID int
+	// Profile is stored in Redis under student:<card_id>.
+	Profile StudentProfile
+}
+
+type StudentProfile struct {
+	Name       string `json:"name"`
+	Group      string `json:"group"`
+	Course     int    `json:"course"`
+	Department string `json:"department-name"`
+	Email      string `json:"email"`
+	Birth      string `json:"birth"`
+}
+
+type Teacher struct {
+	ID              int
+	Name            string
+	Email           string
+	DepartmentID    int
+	WeeklyLoadHours int
+}
+
+type Discipline struct {
+	ID          int
+	Name        string
+	Description string
+	IsSpecial   bool
+}
+
+type Equipment struct {
+	ID   int
+	Name string
+}
+
+// Lesson types as stored in lesson.type.
+const (
+	LessonLecture    = 1
+	LessonPractice   = 2
+	LessonLaboratory = 3
+)
+
+type Lesson struct {
+	ID           int64
+	DisciplineID int
+	Topic        string
+	Type         int
+	EquipmentIDs []int
+	// TeacherID is 0 for a lesson without a teacher.
+	TeacherID int
+}
+
+type Schedule struct {
+	ID       int64
+	LessonID int64
+	GroupID  int
+	Date     time.Time
+}
+
+type Attendance struct {
+	StudentID  int
+	ScheduleID int64
+	Attended   bool
+}
+
+type Material struct {
+	ID        int
+	Title     string
+	Content   string
+	Tags      []string
+	LessonIDs []int64
+}
+
+// Dataset is a generated university.
+type Dataset struct {
+	Departments []Department
+	Groups      []Group
+	Students    []Student
+	Teachers    []Teacher
+	Disciplines []Discipline
+	Equipment   []Equipment
+	Lessons     []Lesson
+	Schedule    []Schedule
+	Attendance  []Attendance
+	Materials   []Material
+}
+
+// Generate builds the dataset described by cfg. It only depends on cfg.
+func Generate(cfg Config) *Dataset {
+	g := generator{cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed)), data: &Dataset{}}
+	g.departments()
+	g.disciplines()
+	g.lessons()
+	g.materials()
+	g.schedule()
+	g.attendance()
+	// Teachers come last so that they do not change the rest of a
+	// university generated before they were added.
+	g.teachers()
+	return g.data
+}
+
+type generator struct {
+	cfg  Config
+	rng  *rand.Rand
+	data *Dataset
+
+	equipmentIDs map[string]int
+	// lessonsByDiscipline lists the lessons of each discipline in order.
+	lessonsByDiscipline map[int][]int64
+	// propensity is the chance each student attends a lesson.
+	propensity map[int]float64
+}
+
+func (g *generator) departments() {
+	g.propensity = make(map[int]float64)
+	usedCards := make(map[string]bool)
+	usedEmails := make(map[string]bool)
+
+	for d := 0; d < g.cfg.Departments; d++ {
+		department := Department{ID: d + 1, Name: departmentNames[d].name}
+		g.data.Departments = append(g.data.Departments, department)
+
+		for i := 0; i < g.cfg.GroupsPerDepartment; i++ {
+			course := i%4 + 1
+			group := Group{
+				ID:           len(g.data.Groups) + 1,
+				Name:         fmt.Sprintf("%s-%02d-%02d", departmentNames[d].prefix, i+1, (g.cfg.Year-course+1)%100),
+				DepartmentID: department.ID,
+				Course:       course,
+			}
+			group.CuratorEmail = fmt.Sprintf("curator.%s@university.example", translitString(group.Name))
+			g.data.Groups = append(g.data.Groups, group)
+
+			for s := 0; s < g.cfg.StudentsPerGroup; s++ {
+				student := g.student(group, department, usedCards, usedEmails)
+				g.data.Students = append(g.data.Students, student)
+			}
+		}
+	}
+}
+
+func (g *generator) student(group Group, department Department, usedCards, usedEmails map[string]bool) Student {
+	first, last, middle := g.name()
+
+	cardID := fmt.Sprintf("%08d", g.rng.Intn(100000000))
+	for usedCards[cardID] {
+		cardID = fmt.Sprintf("%08d", g.rng.Intn(100000000))
+	}
+	usedCards[cardID] = true
+
+	email := fmt.Sprintf("%s.%s@student.university.example", translitString(first), translitString(last))
+	for n := 2; usedEmails[email]; n++ {
+		email = fmt.Sprintf("%s.%s%d@student.university.example", translitString(first), translitString(last), n)
+	}
+	usedEmails[email] = true
+
+	birthYear := g.cfg.Year - 17 - group.Course - g.rng.Intn(2)
+	birth := time.Date(birthYear, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, g.rng.Intn(365))
+
+	id := len(g.data.Students) + 1
+	if g.rng.Float64() < g.cfg.AtRiskShare {
+		g.propensity[id] = 0.35 + g.rng.Float64()*0.3
+	} else {
+		g.propensity[id] = 0.8 + g.rng.Float64()*0.19
+	}
+
+	return Student{
+		ID:      id,
+		CardID:  cardID,
+		GroupID: group.ID,
+		Profile: StudentProfile{
+			Name:       fmt.Sprintf("%s %s %s", last, first, middle),
+			Group:      group.Name,
+			Course:     group.Course,
+			Department: department.Name,
+			Email:      strings.ToLower(email),
+			Birth:      birth.Format("2006-01-02"),
+		},
+	}
+}
+
+func (g *generator) name() (first, last, middle string) {
+	female := g.rng.Intn(2) == 0
+	first, last, middle = pick(g.rng, maleFirstNames), pick(g.rng, maleLastNames), pick(g.rng, maleMiddleNames)
+	if female {
+		first, last, middle = pick(g.rng, femaleFirstNames), pick(g.rng, maleLastNames)+"а", pick(g.rng, femaleMiddleNames)
+	}
+	return first, last, middle
+}
+
+func (g *generator) disciplines() {
+	g.equipmentIDs = make(map[string]int)
+	specials := int(float64(g.cfg.Disciplines)*g.cfg.SpecialShare + 0.5)
+	special := make(map[int]bool)
+	for _, i := range g.rng.Perm(g.cfg.Disciplines)[:specials] {
+		special[i] = true
+	}
+
+	for i := 0; i < g.cfg.Disciplines; i++ {
+		template := disciplineTemplates[i]
+		g.data.Disciplines = append(g.data.Disciplines, Discipline{
+			ID:          i + 1,
+			Name:        template.name,
+			Description: template.description,
+			IsSpecial:   special[i],
+		})
+		for _, name := range template.equipment {
+			if _, ok := g.equipmentIDs[name]; !ok {
+				g.equipmentIDs[name] = len(g.data.Equipment) + 1
+				g.data.Equipment = append(g.data.Equipment, Equipment{ID: len(g.data.Equipment) + 1, Name: name})
+			}
+		}
+	}
+}
+
+func (g *generator) lessons() {
+	g.lessonsByDiscipline = make(map[int][]int64)
+	for _, discipline := range g.data.Disciplines {
+		template := disciplineTemplates[discipline.ID-1]
+		for i := 0; i < g.cfg.LessonsPerDiscipline; i++ {
+			topic := template.topics[i%len(template.topics)]
+			if part := i/len(template.topics) + 1; part > 1 {
+				topic = fmt.Sprintf("%s (часть %d)", topic, part)
+			}
+
+			// Lectures and practice alternate; laboratory disciplines turn
+			// every third lesson into a lab.
+			lessonType := LessonLecture
+			switch {
+			case template.laboratoryUse && i%3 == 2:
+				lessonType = LessonLaboratory
+			case i%2 == 1:
+				lessonType = LessonPractice
+			}
+
+			lesson := Lesson{
+				ID:           int64(len(g.data.Lessons) + 1),
+				DisciplineID: discipline.ID,
+				Topic:        topic,
+				Type:         lessonType,
+			}
+			for _, name := range template.equipment {
+				if lessonType == LessonLecture || g.rng.Intn(2) == 0 {
+					lesson.EquipmentIDs = append(lesson.EquipmentIDs, g.equipmentIDs[name])
+				}
+			}
+			g.data.Lessons = append(g.data.Lessons, lesson)
+			g.lessonsByDiscipline[discipline.ID] = append(g.lessonsByDiscipline[discipline.ID], lesson.ID)
+		}
+	}
+}
+
+func (g *generator) materials() {
+	for _, lesson := range g.data.Lessons {
+		template := disciplineTemplates[lesson.DisciplineID-1]
+		for i := 0; i < g.cfg.MaterialsPerLesson; i++ {
+			material := g.material(template, lesson)
+			material.LessonIDs = []int64{lesson.ID}
+			// Some materials also serve the next lesson of the discipline.
+			lessons := g.lessonsByDiscipline[lesson.DisciplineID]
+			if next := int(lesson.ID-lessons[0]) + 1; next < len(lessons) && g.rng.Intn(4) == 0 {
+				material.LessonIDs = append(material.LessonIDs, lessons[next])
+			}
+			g.data.Materials = append(g.data.Materials, material)
+		}
+	}
+}
+
+func (g *generator) material(template disciplineTemplate, lesson Lesson) Material {
+	english := g.rng.Intn(10) < 3
+	kinds, sentences, keywords := materialKindsRU, sentencesRU, template.keywordsRU
+	if english {
+		kinds, sentences, keywords = materialKindsEN, sentencesEN, template.keywordsEN
+	}
+
+	var content []string
+	for n := 3 + g.rng.Intn(3); n > 0; n-- {
+		a, b := pick(g.rng, keywords), pick(g.rng, keywords)
+		for b == a && len(keywords) > 1 {
+			b = pick(g.rng, keywords)
+		}
+		content = append(content, fmt.Sprintf(pick(g.rng, sentences), a, b))
+	}
+
+	tags := []string{pick(g.rng, keywords), pick(g.rng, keywords)}
+	if tags[0] == tags[1] {
+		tags = tags[:1]
+	}
+	sort.Strings(tags)
+
+	return Material{
+		ID:      len(g.data.Materials) + 1,
+		Title:   fmt.Sprintf("%s: %s", pick(g.rng, kinds), lesson.Topic),
+		Content: strings.Join(content, " "),
+		Tags:    tags,
+	}
+}
+
+// schedule gives every group its disciplines, half in the autumn semester and
+// half in the spring one, with one lesson a week on a fixed weekday.
+func (g *generator) schedule() {
+	semesters := []time.Time{
+		time.Date(g.cfg.Year, time.September, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(g.cfg.Year+1, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	for _, group := range g.data.Groups {
+		disciplines := g.rng.Perm(g.cfg.Disciplines)[:g.cfg.DisciplinesPerGroup]
+		for i, d := range disciplines {
+			start := semesters[i%2]
+			weekday := time.Weekday(1 + g.rng.Intn(5))
+			for start.Weekday() != weekday {
+				start = start.AddDate(0, 0, 1)
+			}
+			for week, lessonID := range g.lessonsByDiscipline[d+1] {
+				g.data.Schedule = append(g.data.Schedule, Schedule{
+					ID:       int64(len(g.data.Schedule) + 1),
+					LessonID: lessonID,
+					GroupID:  group.ID,
+					Date:     start.AddDate(0, 0, 7*week),
+				})
+			}
+		}
+	}
+}
+
+func (g *generator) attendance() {
+	studentsByGroup := make(map[int][]Student)
+	for _, student := range g.data.Students {
+		studentsByGroup[student.GroupID] = append(studentsByGroup[student.GroupID], student)
+	}
+	for _, schedule := range g.data.Schedule {
+		for _, student := range studentsByGroup[schedule.GroupID] {
+			g.data.Attendance = append(g.data.Attendance, Attendance{
+				StudentID:  student.ID,
+				ScheduleID: schedule.ID,
+				Attended:   g.rng.Float64() < g.propensity[student.ID],
+			})
+		}
+	}
+}
+
+// teachers staffs every department and gives each discipline a lecturer
+// and a teacher of its other lessons, who may be the same person.
+func (g *generator) teachers() {
+	usedEmails := make(map[string]bool)
+	for _, department := range g.data.Departments {
+		for i := 0; i < g.cfg.TeachersPerDepartment; i++ {
+			first, last, middle := g.name()
+			email := fmt.Sprintf("%s.%s@university.example", translitString(first), translitString(last))
+			for n := 2; usedEmails[email]; n++ {
+				email = fmt.Sprintf("%s.%s%d@university.example", translitString(first), translitString(last), n)
+			}
+			usedEmails[email] = true
+
+			g.data.Teachers = append(g.data.Teachers, Teacher{
+				ID:              len(g.data.Teachers) + 1,
+				Name:            fmt.Sprintf("%s %s %s", last, first, middle),
+				Email:           email,
+				DepartmentID:    department.ID,
+				WeeklyLoadHours: 12 + 2*g.rng.Intn(5),
+			})
+		}
+	}
+
+	lecturers := make(map[int]int)
+	assistants := make(map[int]int)
+	for _, discipline := range g.data.Disciplines {
+		lecturers[discipline.ID] = g.data.Teachers[g.rng.Intn(len(g.data.Teachers))].ID
+		assistants[discipline.ID] = g.data.Teachers[g.rng.Intn(len(g.data.Teachers))].ID
+	}
+	for i := range g.data.Lessons {
+		lesson := &g.data.Lessons[i]
+		lesson.TeacherID = assistants[lesson.DisciplineID]
+		if lesson.Type == LessonLecture {
+			lesson.TeacherID = lecturers[lesson.DisciplineID]
+		}
+	}
+}
+
+func pick(rng *rand.Rand, list []string) string {
+	return list[rng.Intn(len(list))]
+}
+
+func translitString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+		if latin, ok := translit[lower]; ok {
+			b.WriteString(latin)
+		} else if r < unicode.MaxASCII {
+			b.WriteRune(lower)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package seed
+
+// Word banks for the generated data. Materials are written in Russian or
+// English from the discipline's own vocabulary, so term search finds them
+// by the words a student would actually look for.
+
+type disciplineTemplate struct {
+	name          string
+	description   string
+	topics        []string
+	keywordsRU    []string
+	keywordsEN    []string
+	equipment     []string
+	laboratoryUse bool
+}
+
+var disciplineTemplates = []disciplineTemplate{
+	{
+		name:        "Базы данных",
+		description: "Реляционная модель, SQL, проектирование схем и транзакции",
+		topics:      []string{"Реляционная модель данных", "Язык SQL: выборка", "Соединения таблиц", "Нормализация", "Индексы", "Транзакции и изоляция", "Хранимые процедуры", "NoSQL хранилища"},
+		keywordsRU:  []string{"таблица", "запрос", "индекс", "транзакция", "нормальная форма", "внешний ключ", "план выполнения", "блокировка"},
+		keywordsEN:  []string{"table", "query", "index", "transaction", "normal form", "foreign key", "execution plan", "lock"},
+		equipment:   []string{"Проектор", "Компьютерный класс"},
+	},
+	{
+		name:        "Операционные системы",
+		description: "Процессы, потоки, управление памятью и файловые системы",
+		topics:      []string{"Процессы и потоки", "Планирование процессов", "Синхронизация", "Виртуальная память", "Файловые системы", "Ввод-вывод", "Системные вызовы"},
+		keywordsRU:  []string{"процесс", "поток", "планировщик", "страница памяти", "мьютекс", "семафор", "ядро", "прерывание"},
+		keywordsEN:  []string{"process", "thread", "scheduler", "memory page", "mutex", "semaphore", "kernel", "interrupt"},
+		equipment:   []string{"Проектор", "Компьютерный класс"},
+	},
+	{
+		name:          "Компьютерные сети",
+		description:   "Модель OSI, стек TCP/IP, маршрутизация и сетевая безопасность",
+		topics:        []string{"Модель OSI", "Канальный уровень", "IP-адресация", "Маршрутизация", "Протокол TCP", "DNS и HTTP", "Сетевая безопасность"},
+		keywordsRU:    []string{"пакет", "маршрутизатор", "коммутатор", "протокол", "подсеть", "рукопожатие", "пропускная способность"},
+		keywordsEN:    []string{"packet", "router", "switch", "protocol", "subnet", "handshake", "bandwidth"},
+		equipment:     []string{"Проектор", "Лабораторный стенд"},
+		laboratoryUse: true,
+	},
+	{
+		name:        "Математический анализ",
+		description: "Пределы, производные, интегралы и ряды",
+		topics:      []string{"Пределы последовательностей", "Непрерывность функций", "Производная", "Исследование функций", "Неопределенный интеграл", "Определенный интеграл", "Числовые ряды", "Степенные ряды"},
+		keywordsRU:  []string{"предел", "производная", "интеграл", "ряд", "сходимость", "непрерывность", "экстремум"},
+		keywordsEN:  []string{"limit", "derivative", "integral", "series", "convergence", "continuity", "extremum"},
+		equipment:   []string{"Интерактивная доска"},
+	},
+	{
+		name:        "Линейная алгебра",
+		description: "Матрицы, определители, векторные пространства и линейные отображения",
+		topics:      []string{"Матрицы и операции", "Определители", "Системы линейных уравнений", "Векторные пространства", "Линейные отображения", "Собственные значения", "Квадратичные формы"},
+		keywordsRU:  []string{"матрица", "определитель", "вектор", "базис", "ранг", "собственное значение", "линейная оболочка"},
+		keywordsEN:  []string{"matrix", "determinant", "vector", "basis", "rank", "eigenvalue", "span"},
+		equipment:   []string{"Интерактивная доска"},
+	},
+	{
+		name:          "Физика",
+		description:   "Механика, термодинамика, электричество и магнетизм",
+		topics:        []string{"Кинематика", "Динамика", "Законы сохранения", "Термодинамика", "Электростатика", "Постоянный ток", "Магнитное поле", "Колебания и волны"},
+		keywordsRU:    []string{"сила", "энергия", "импульс", "температура", "заряд", "напряжение", "магнитное поле", "колебание"},
+		keywordsEN:    []string{"force", "energy", "momentum", "temperature", "charge", "voltage", "magnetic field", "oscillation"},
+		equipment:     []string{"Проектор", "Лабораторный стенд", "Осциллограф"},
+		laboratoryUse: true,
+	},
+	{
+		name:        "Программирование на Go",
+		description: "Синтаксис Go, конкурентность, работа с сетью и тестирование",
+		topics:      []string{"Типы и структуры", "Интерфейсы", "Обработка ошибок", "Горутины и каналы", "Пакет context", "HTTP-серверы", "Тестирование", "Профилирование"},
+		keywordsRU:  []string{"горутина", "канал", "интерфейс", "срез", "замыкание", "пакет", "модуль", "ошибка"},
+		keywordsEN:  []string{"goroutine", "channel", "interface", "slice", "closure", "package", "module", "error"},
+		equipment:   []string{"Проектор", "Компьютерный класс"},
+	},
+	{
+		name:        "Алгоритмы и структуры данных",
+		description: "Сложность алгоритмов, сортировки, деревья и графы",
+		topics:      []string{"Асимптотическая сложность", "Сортировки", "Хеш-таблицы", "Деревья поиска", "Кучи", "Обход графов", "Кратчайшие пути", "Динамическое программирование"},
+		keywordsRU:  []string{"сложность", "сортировка", "дерево", "граф", "хеш-таблица", "рекурсия", "очередь с приоритетом"},
+		keywordsEN:  []string{"complexity", "sorting", "tree", "graph", "hash table", "recursion", "priority queue"},
+		equipment:   []string{"Проектор", "Компьютерный класс"},
+	},
+	{
+		name:        "Информационная безопасность",
+		description: "Криптография, управление доступом и защита сетей",
+		topics:      []string{"Модели угроз", "Симметричное шифрование", "Асимметричное шифрование", "Хеш-функции и подписи", "Аутентификация", "Управление доступом", "Аудит безопасности"},
+		keywordsRU:  []string{"шифрование", "ключ", "подпись", "сертификат", "уязвимость", "аутентификация", "атака"},
+		keywordsEN:  []string{"encryption", "key", "signature", "certificate", "vulnerability", "authentication", "attack"},
+		equipment:   []string{"Проектор", "Компьютерный класс"},
+	},
+	{
+		name:          "Электротехника",
+		description:   "Электрические цепи, трансформаторы и электрические машины",
+		topics:        []string{"Законы Кирхгофа", "Цепи постоянного тока", "Цепи переменного тока", "Трехфазные цепи", "Трансформаторы", "Электрические машины"},
+		keywordsRU:    []string{"цепь", "резистор", "конденсатор", "индуктивность", "ток", "мощность", "трансформатор"},
+		keywordsEN:    []string{"circuit", "resistor", "capacitor", "inductance", "current", "power", "transformer"},
+		equipment:     []string{"Лабораторный стенд", "Осциллограф", "Мультиметр"},
+		laboratoryUse: true,
+	},
+	{
+		name:        "Английский язык",
+		description: "Профессиональный английский для инженеров",
+		topics:      []string{"Technical reading", "Writing emails", "Presentations", "Meetings and negotiations", "Documentation style", "Job interview"},
+		keywordsRU:  []string{"лексика", "грамматика", "презентация", "перевод", "аудирование"},
+		keywordsEN:  []string{"vocabulary", "grammar", "presentation", "translation", "listening"},
+		equipment:   []string{"Мультимедийная аудитория"},
+	},
+	{
+		name:        "Физическая культура",
+		description: "Общая физическая подготовка и спортивные секции",
+		topics:      []string{"Легкая атлетика", "Игровые виды спорта", "Гимнастика", "Плавание", "Лыжная подготовка"},
+		keywordsRU:  []string{"выносливость", "разминка", "норматив", "тренировка", "техника"},
+		keywordsEN:  []string{"endurance", "warm-up", "standard", "training", "technique"},
+		equipment:   []string{"Спортивный зал"},
+	},
+	{
+		name:        "Машинное обучение",
+		description: "Регрессия, классификация, нейронные сети и оценка моделей",
+		topics:      []string{"Линейная регрессия", "Логистическая регрессия", "Деревья решений", "Ансамбли моделей", "Нейронные сети", "Кластеризация", "Оценка качества моделей"},
+		keywordsRU:  []string{"модель", "признак", "обучающая выборка", "переобучение", "градиентный спуск", "нейрон", "метрика"},
+		keywordsEN:  []string{"model", "feature", "training set", "overfitting", "gradient descent", "neuron", "metric"},
+		equipment:   []string{"Проектор", "Компьютерный класс", "GPU-сервер"},
+	},
+	{
+		name:        "Философия",
+		description: "История философской мысли и основы логики",
+		topics:      []string{"Античная философия", "Философия Нового времени", "Теория познания", "Логика", "Философия науки", "Этика"},
+		keywordsRU:  []string{"познание", "истина", "сознание", "логика", "аргумент", "мораль"},
+		keywordsEN:  []string{"cognition", "truth", "consciousness", "logic", "argument", "morality"},
+		equipment:   []string{"Проектор"},
+	},
+}
+
+var departmentNames = []struct {
+	name, prefix string
+}{
+	{"Кафедра информатики", "ИКБО"},
+	{"Кафедра прикладной математики", "КМБО"},
+	{"Кафедра информационной безопасности", "БИСО"},
+	{"Кафедра радиоэлектроники", "РРБО"},
+	{"Кафедра автоматизации", "АДБО"},
+	{"Кафедра вычислительной техники", "ИВБО"},
+}
+
+var (
+	maleFirstNames    = []string{"Александр", "Дмитрий", "Максим", "Иван", "Артем", "Никита", "Михаил", "Егор", "Андрей", "Илья", "Кирилл", "Роман", "Павел", "Сергей"}
+	femaleFirstNames  = []string{"Анна", "Мария", "Елена", "Дарья", "Алина", "Ксения", "Полина", "Екатерина", "Виктория", "Софья", "Ольга", "Татьяна", "Юлия", "Ирина"}
+	maleLastNames     = []string{"Иванов", "Смирнов", "Кузнецов", "Попов", "Васильев", "Петров", "Соколов", "Михайлов", "Новиков", "Федоров", "Морозов", "Волков", "Алексеев", "Лебедев", "Семенов", "Егоров"}
+	maleMiddleNames   = []string{"Александрович", "Дмитриевич", "Сергеевич", "Андреевич", "Иванович", "Михайлович", "Павлович", "Олегович"}
+	femaleMiddleNames = []string{"Александровна", "Дмитриевна", "Сергеевна", "Андреевна", "Ивановна", "Михайловна", "Павловна", "Олеговна"}
+)
+
+var (
+	materialKindsRU = []string{"Конспект лекции", "Методические указания", "Презентация", "Задания для самостоятельной работы", "Вопросы к зачету"}
+	materialKindsEN = []string{"Lecture notes", "Lab guide", "Slides", "Exercises", "Reading list"}
+
+	sentencesRU = []string{
+		"В этом материале рассматривается понятие «%s» и его связь с понятием «%s».",
+		"Особое внимание уделено связи понятий «%s» и «%s» на практике.",
+		"Разобраны типичные ошибки при работе с темами «%s» и «%s» и способы их избежать.",
+		"Приведены примеры задач, в которых вместе используются «%s» и «%s».",
+		"Для самопроверки объясните, чем отличаются понятия «%s» и «%s».",
+	}
+	sentencesEN = []string{
+		"This material covers %s and how it relates to %s.",
+		"We look at how %s affects %s in real systems.",
+		"Common mistakes with %s are discussed together with %s.",
+		"Worked examples combine %s and %s step by step.",
+		"As a self-check, explain the difference between %s and %s.",
+	}
+)
+
+// translit spells a Russian name in Latin letters for email addresses.
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
@@ -0,0 +1,415 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/storage"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"go.mongodb.org/mongo-driver/bson"
+	"strconv"
+	"strings"
+)
+
+// Names of the Elasticsearch indices and the Mongo collection the seeder
+// writes besides the Postgres tables, the student:<card_id> Redis keys and
+// the Lesson and Material nodes in Neo4j.
+const (
+	materialsIndex        = "materials"
+	disciplinesIndex      = "disciplines"
+	departmentsCollection = "departments"
+)
+
+// postgresTables are truncated on reset, children first.
+var postgresTables = []string{"attendance", "schedule", "equipment_requirements", "lesson", "teacher", "equipment", "course", "student", `"group"`, "department"}
+
+const bulkBatch = 1000
+
+type Writer struct {
+	clients       *storage.Clients
+	mongoDatabase string
+}
+
+func NewWriter(clients *storage.Clients, mongoDatabase string) *Writer {
+	return &Writer{clients: clients, mongoDatabase: mongoDatabase}
+}
+
+// Reset removes the data the seeder writes from every store. Other data in
+// Redis, Mongo and Neo4j, like report jobs and snapshots, is kept.
+func (w *Writer) Reset(ctx context.Context) error {
+	if _, err := w.clients.Postgres.ExecContext(ctx, "TRUNCATE "+strings.Join(postgresTables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		return fmt.Errorf("failed to truncate Postgres tables: %v", err)
+	}
+
+	iter := w.clients.Redis.Scan(ctx, 0, "student:*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan Redis students: %v", err)
+	}
+	for start := 0; start < len(keys); start += bulkBatch {
+		end := min(start+bulkBatch, len(keys))
+		if err := w.clients.Redis.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return fmt.Errorf("failed to delete Redis students: %v", err)
+		}
+	}
+
+	// The names are aliases kept by the bootstrap, so the documents are
+	// deleted rather than the indices.
+	es := w.clients.Elastic
+	res, err := es.DeleteByQuery(
+		[]string{materialsIndex, disciplinesIndex},
+		strings.NewReader(`{"query": {"match_all": {}}}`),
+		es.DeleteByQuery.WithContext(ctx),
+		es.DeleteByQuery.WithIgnoreUnavailable(true),
+		es.DeleteByQuery.WithConflicts("proceed"),
+		es.DeleteByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete Elasticsearch documents: %v", err)
+	}
+	res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to delete Elasticsearch documents: %s", res.Status())
+	}
+
+	if err := w.neo4jWrite(`MATCH (n) WHERE n:Lesson OR n:Material DETACH DELETE n`, nil); err != nil {
+		return fmt.Errorf("failed to delete Neo4j nodes: %v", err)
+	}
+
+	if err := w.clients.Mongo.Database(w.mongoDatabase).Collection(departmentsCollection).Drop(ctx); err != nil {
+		return fmt.Errorf("failed to drop Mongo departments: %v", err)
+	}
+	return nil
+}
+
+// Write stores the dataset in every store. Postgres goes first and in one
+// transaction, since the other stores only hold what hangs off its IDs.
+func (w *Writer) Write(ctx context.Context, data *Dataset) error {
+	steps := []struct {
+		store string
+		write func(context.Context, *Dataset) error
+	}{
+		{storage.BackendPostgres, w.writePostgres},
+		{storage.BackendRedis, w.writeRedis},
+		{storage.BackendElastic, w.writeElastic},
+		{storage.BackendNeo4j, w.writeNeo4j},
+		{storage.BackendMongo, w.writeMongo},
+	}
+	for _, step := range steps {
+		if err := step.write(ctx, data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", storage.Title(step.store), err)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writePostgres(ctx context.Context, data *Dataset) error {
+	tx, err := w.clients.Postgres.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var rows [][]interface{}
+	copyRows := func(table string, columns ...string) error {
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+		if err != nil {
+			return fmt.Errorf("failed to copy into %s: %v", table, err)
+		}
+		for _, row := range rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				stmt.Close()
+				return fmt.Errorf("failed to copy into %s: %v", table, err)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy into %s: %v", table, err)
+		}
+		rows = nil
+		return stmt.Close()
+	}
+
+	for _, d := range data.Departments {
+		rows = append(rows, []interface{}{d.ID, d.Name})
+	}
+	if err := copyRows("department", "department_id", "name"); err != nil {
+		return err
+	}
+	for _, g := range data.Groups {
+		rows = append(rows, []interface{}{g.ID, g.Name, g.DepartmentID, g.CuratorEmail})
+	}
+	if err := copyRows("group", "group_id", "name", "department_id", "curator_email"); err != nil {
+		return err
+	}
+	for _, s := range data.Students {
+		rows = append(rows, []interface{}{s.ID, s.CardID, s.GroupID})
+	}
+	if err := copyRows("student", "student_id", "card_id", "group_id"); err != nil {
+		return err
+	}
+	for _, t := range data.Teachers {
+		rows = append(rows, []interface{}{t.ID, t.Name, t.Email, t.DepartmentID, t.WeeklyLoadHours})
+	}
+	if err := copyRows("teacher", "teacher_id", "name", "email", "department_id", "weekly_load_hours"); err != nil {
+		return err
+	}
+	for _, d := range data.Disciplines {
+		rows = append(rows, []interface{}{d.ID, d.IsSpecial})
+	}
+	if err := copyRows("course", "discipline_id", "is_special"); err != nil {
+		return err
+	}
+	for _, e := range data.Equipment {
+		rows = append(rows, []interface{}{e.ID, e.Name})
+	}
+	if err := copyRows("equipment", "id", "name"); err != nil {
+		return err
+	}
+	for _, l := range data.Lessons {
+		var teacherID interface{}
+		if l.TeacherID != 0 {
+			teacherID = l.TeacherID
+		}
+		rows = append(rows, []interface{}{l.ID, l.DisciplineID, l.Topic, l.Type, teacherID})
+	}
+	if err := copyRows("lesson", "lesson_id", "discipline_id", "topic", "type", "teacher_id"); err != nil {
+		return err
+	}
+	for _, l := range data.Lessons {
+		for _, equipmentID := range l.EquipmentIDs {
+			rows = append(rows, []interface{}{l.ID, equipmentID})
+		}
+	}
+	if err := copyRows("equipment_requirements", "lesson_id", "equipment"); err != nil {
+		return err
+	}
+	for _, s := range data.Schedule {
+		rows = append(rows, []interface{}{s.ID, s.LessonID, s.GroupID, s.Date.Format("2006-01-02")})
+	}
+	if err := copyRows("schedule", "schedule_id", "lesson_id", "group_id", "date"); err != nil {
+		return err
+	}
+	for _, a := range data.Attendance {
+		rows = append(rows, []interface{}{a.StudentID, a.ScheduleID, a.Attended})
+	}
+	if err := copyRows("attendance", "student_id", "schedule_id", "status"); err != nil {
+		return err
+	}
+
+	// The IDs were written explicitly, so serial columns have to catch up
+	// for rows added later through the usual inserts.
+	sequences := []struct {
+		table, column string
+		last          int64
+	}{
+		{"department", "department_id", int64(len(data.Departments))},
+		{`"group"`, "group_id", int64(len(data.Groups))},
+		{"student", "student_id", int64(len(data.Students))},
+		{"teacher", "teacher_id", int64(len(data.Teachers))},
+		{"equipment", "id", int64(len(data.Equipment))},
+		{"lesson", "lesson_id", int64(len(data.Lessons))},
+		{"schedule", "schedule_id", int64(len(data.Schedule))},
+	}
+	for _, seq := range sequences {
+		if seq.last == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence($1, $2), $3)", seq.table, seq.column, seq.last); err != nil {
+			return fmt.Errorf("failed to advance %s.%s sequence: %v", seq.table, seq.column, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (w *Writer) writeRedis(ctx context.Context, data *Dataset) error {
+	pipe := w.clients.Redis.Pipeline()
+	for i, student := range data.Students {
+		profile, err := json.Marshal(student.Profile)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, "student:"+student.CardID, profile, 0)
+		if (i+1)%bulkBatch == 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// writeElastic indexes materials and disciplines with their IDs as strings,
+// the way the accounting client reads them.
+func (w *Writer) writeElastic(ctx context.Context, data *Dataset) error {
+	var docs []bulkDoc
+	for _, d := range data.Disciplines {
+		docs = append(docs, bulkDoc{index: disciplinesIndex, id: strconv.Itoa(d.ID), source: map[string]interface{}{
+			"discipline_id": strconv.Itoa(d.ID),
+			"name":          d.Name,
+			"description":   d.Description,
+		}})
+	}
+	for _, m := range data.Materials {
+		docs = append(docs, bulkDoc{index: materialsIndex, id: strconv.Itoa(m.ID), source: map[string]interface{}{
+			"material_id": strconv.Itoa(m.ID),
+			"title":       m.Title,
+			"content":     m.Content,
+			"tags":        m.Tags,
+			"lesson_ids":  m.LessonIDs,
+		}})
+	}
+
+	for start := 0; start < len(docs); start += bulkBatch {
+		end := min(start+bulkBatch, len(docs))
+		if err := w.bulkIndex(ctx, docs[start:end], end == len(docs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type bulkDoc struct {
+	index, id string
+	source    map[string]interface{}
+}
+
+func (w *Writer) bulkIndex(ctx context.Context, docs []bulkDoc, refresh bool) error {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action := map[string]interface{}{"index": map[string]interface{}{"_index": doc.index, "_id": doc.id}}
+		if err := json.NewEncoder(&body).Encode(action); err != nil {
+			return err
+		}
+		if err := json.NewEncoder(&body).Encode(doc.source); err != nil {
+			return err
+		}
+	}
+
+	es := w.clients.Elastic
+	options := []func(*esapi.BulkRequest){es.Bulk.WithContext(ctx)}
+	if refresh {
+		options = append(options, es.Bulk.WithRefresh("true"))
+	}
+	res, err := es.Bulk(&body, options...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("bulk request failed: %s", res.Status())
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, op := range item {
+				if len(op.Error) > 0 {
+					return fmt.Errorf("bulk item failed: %s", op.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writeNeo4j(_ context.Context, data *Dataset) error {
+	lessons := make([]interface{}, len(data.Lessons))
+	for i, l := range data.Lessons {
+		lessons[i] = map[string]interface{}{"id": l.ID, "topic": l.Topic, "discipline_id": int64(l.DisciplineID)}
+	}
+	if err := w.neo4jWrite(`UNWIND $rows AS row
+	MERGE (l:Lesson {id: row.id})
+	SET l.topic = row.topic, l.discipline_id = row.discipline_id`, lessons); err != nil {
+		return err
+	}
+
+	materials := make([]interface{}, len(data.Materials))
+	var links []interface{}
+	for i, m := range data.Materials {
+		materials[i] = map[string]interface{}{"id": int64(m.ID), "title": m.Title}
+		for _, lessonID := range m.LessonIDs {
+			links = append(links, map[string]interface{}{"material": int64(m.ID), "lesson": lessonID})
+		}
+	}
+	if err := w.neo4jWrite(`UNWIND $rows AS row
+	MERGE (m:Material {id: row.id})
+	SET m.title = row.title`, materials); err != nil {
+		return err
+	}
+	return w.neo4jWrite(`UNWIND $rows AS row
+	MATCH (m:Material {id: row.material}), (l:Lesson {id: row.lesson})
+	MERGE (m)-[:MAT_LES]->(l)`, links)
+}
+
+// neo4jWrite runs the query over rows in batches, one transaction each.
+func (w *Writer) neo4jWrite(query string, rows []interface{}) error {
+	session := w.clients.Neo4j.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	run := func(batch []interface{}) error {
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			result, err := tx.Run(query, map[string]interface{}{"rows": batch})
+			if err != nil {
+				return nil, err
+			}
+			return result.Consume()
+		})
+		return err
+	}
+
+	if rows == nil {
+		return run(nil)
+	}
+	for start := 0; start < len(rows); start += bulkBatch {
+		if err := run(rows[start:min(start+bulkBatch, len(rows))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMongo stores the structure of the university: departments with their
+// groups and the card IDs of the students in each.
+func (w *Writer) writeMongo(ctx context.Context, data *Dataset) error {
+	students := make(map[int][]string)
+	for _, s := range data.Students {
+		students[s.GroupID] = append(students[s.GroupID], s.CardID)
+	}
+	groups := make(map[int][]bson.M)
+	for _, g := range data.Groups {
+		groups[g.DepartmentID] = append(groups[g.DepartmentID], bson.M{
+			"group_id":      g.ID,
+			"name":          g.Name,
+			"course":        g.Course,
+			"curator_email": g.CuratorEmail,
+			"students":      students[g.ID],
+		})
+	}
+
+	docs := make([]interface{}, len(data.Departments))
+	for i, d := range data.Departments {
+		docs[i] = bson.M{"_id": d.ID, "name": d.Name, "groups": groups[d.ID]}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := w.clients.Mongo.Database(w.mongoDatabase).Collection(departmentsCollection).InsertMany(ctx, docs)
+	return err
+}
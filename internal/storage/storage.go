@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/config"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/go-redis/redis/v8"
+	_ "github.com/lib/pq"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// Backend names, in the order the service connects to them.
+const (
+	BackendRedis    = "redis"
+	BackendMongo    = "mongo"
+	BackendNeo4j    = "neo4j"
+	BackendPostgres = "postgres"
+	BackendElastic  = "elastic"
+)
+
+var Backends = []string{BackendRedis, BackendMongo, BackendNeo4j, BackendPostgres, BackendElastic}
+
+var backendTitles = map[string]string{
+	BackendRedis:    "Redis",
+	BackendMongo:    "MongoDB",
+	BackendNeo4j:    "Neo4j",
+	BackendPostgres: "PostgreSQL",
+	BackendElastic:  "ElasticSearch",
+}
+
+const pingTimeout = 10 * time.Second
+
+// Clients holds a client for every backend.
+type Clients struct {
+	Redis    *redis.Client
+	Mongo    *mongo.Client
+	Neo4j    neo4j.Driver
+	Postgres *sql.DB
+	Elastic  *elasticsearch.Client
+}
+
+// Open creates the clients from the config. None of the drivers dial on
+// creation, so use Ping to check that a backend is reachable.
+func Open(ctx context.Context, cfg *config.Config) (*Clients, error) {
+	c := &Clients{
+		Redis: redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		}),
+	}
+
+	var err error
+	if c.Mongo, err = mongo.Connect(ctx, options.Client().ApplyURI(cfg.Mongo.URI)); err != nil {
+		c.Close(ctx)
+		return nil, fmt.Errorf("failed to create MongoDB client: %v", err)
+	}
+	if c.Neo4j, err = neo4j.NewDriver(cfg.Neo4j.URI, neo4j.BasicAuth(cfg.Neo4j.User, cfg.Neo4j.Password, "")); err != nil {
+		c.Close(ctx)
+		return nil, fmt.Errorf("failed to create Neo4j driver: %v", err)
+	}
+	if c.Postgres, err = sql.Open("postgres", cfg.Postgres.DSN); err != nil {
+		c.Close(ctx)
+		return nil, fmt.Errorf("failed to create PostgreSQL client: %v", err)
+	}
+	c.Elastic, err = elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: cfg.Elastic.Addresses,
+		Username:  cfg.Elastic.Username,
+		Password:  cfg.Elastic.Password,
+	})
+	if err != nil {
+		c.Close(ctx)
+		return nil, fmt.Errorf("failed to create ElasticSearch client: %v", err)
+	}
+	return c, nil
+}
+
+// Title is the human readable name of a backend.
+func Title(backend string) string {
+	if title, ok := backendTitles[backend]; ok {
+		return title
+	}
+	return backend
+}
+
+// Ping checks that the backend answers within ten seconds.
+func (c *Clients) Ping(ctx context.Context, backend string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	switch backend {
+	case BackendRedis:
+		return c.Redis.Ping(ctx).Err()
+	case BackendMongo:
+		return c.Mongo.Ping(ctx, nil)
+	case BackendNeo4j:
+		return c.Neo4j.VerifyConnectivity()
+	case BackendPostgres:
+		return c.Postgres.PingContext(ctx)
+	case BackendElastic:
+		res, err := c.Elastic.Info(c.Elastic.Info.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.IsError() {
+			return fmt.Errorf("unexpected response: %s", res.Status())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown backend %q", backend)
+}
+
+func (c *Clients) Close(ctx context.Context) {
+	if c.Redis != nil {
+		_ = c.Redis.Close()
+	}
+	if c.Mongo != nil {
+		_ = c.Mongo.Disconnect(ctx)
+	}
+	if c.Neo4j != nil {
+		_ = c.Neo4j.Close()
+	}
+	if c.Postgres != nil {
+		_ = c.Postgres.Close()
+	}
+}
+
+// Accounting creates the accounting client over the backends.
+func (c *Clients) Accounting(cfg *config.Config) (*accounting.Client, error) {
+	client := accounting.NewClient(c.Redis, c.Mongo, c.Neo4j, c.Postgres, c.Elastic)
+	client.SetMongoDatabase(cfg.Mongo.Database)
+	if err := client.SetRiskRules(cfg.RiskRules); err != nil {
+		return nil, fmt.Errorf("failed to set risk rules: %v", err)
+	}
+	return client, nil
+}
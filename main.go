@@ -3,41 +3,81 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"github.com/AlanMute/university-accounting/internal/accounting"
+	"github.com/AlanMute/university-accounting/internal/auth"
+	"github.com/AlanMute/university-accounting/internal/bootstrap"
+	"github.com/AlanMute/university-accounting/internal/checkin"
+	"github.com/AlanMute/university-accounting/internal/config"
 	"github.com/AlanMute/university-accounting/internal/endpoint"
-	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/AlanMute/university-accounting/internal/graph"
+	"github.com/AlanMute/university-accounting/internal/migrate"
+	"github.com/AlanMute/university-accounting/internal/notify"
+	"github.com/AlanMute/university-accounting/internal/ratelimit"
+	"github.com/AlanMute/university-accounting/internal/redact"
+	"github.com/AlanMute/university-accounting/internal/reportjob"
+	"github.com/AlanMute/university-accounting/internal/scheduler"
+	"github.com/AlanMute/university-accounting/internal/storage"
 	"github.com/go-redis/redis/v8"
-	_ "github.com/lib/pq"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/sirupsen/logrus"
 	"github.com/valyala/fasthttp"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"log"
 	"os"
 	"os/signal"
-	"time"
 )
 
 var (
+	cfg              *config.Config
 	httpHandler      *endpoint.HttpHandler
 	redisClient      *redis.Client
 	mongoClient      *mongo.Client
-	neoClient        neo4j.Driver
 	pgdbClient       *sql.DB
-	esClient         *elasticsearch.Client
+	clients          *storage.Clients
 	ctx              = context.Background()
 	accountingClient *accounting.Client
+	notifier         *notify.Notifier
+	jobScheduler     *scheduler.Scheduler
+	reportJobs       *reportjob.Manager
+	authenticator    *auth.Authenticator
+	apiKeys          *auth.KeyStore
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_PATH"), "path to the JSON config file")
+	flag.Parse()
+
+	var err error
+	cfg, err = config.Load(*configPath)
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+
 	setupDbs()
+	setupSchema()
+	setupBootstrap()
 	setupAccountingClient()
+	setupNotifier()
+	setupScheduler()
+	setupAuth()
+
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	go jobScheduler.Run(backgroundCtx)
+
+	reportJobs = reportjob.NewManager(accountingClient, redisClient, cfg.ReportJobs)
+	reportJobs.Start(backgroundCtx)
+
+	redactor := redact.NewRedactor(cfg.Redaction)
+	graphService, err := graph.NewService(accountingClient, redactor, cfg.GraphQL)
+	if err != nil {
+		logrus.Fatalf("Failed to set up GraphQL: %v", err)
+	}
 
-	httpHandler = endpoint.NewHttpHandler(accountingClient)
+	checkIns := checkin.NewManager(accountingClient, redisClient, cfg.CheckIn)
+
+	httpHandler = endpoint.NewHttpHandler(accountingClient, notifier, jobScheduler, reportJobs, authenticator, apiKeys, ratelimit.NewLimiter(redisClient, cfg.RateLimit), redactor, graphService, checkIns)
 	go func() {
 		logrus.Info("Server was started")
-		err := fasthttp.ListenAndServe("0.0.0.0:8000", httpHandler.Handle)
+		err := fasthttp.ListenAndServe(cfg.HTTP.Addr, httpHandler.Handle)
 		if err != nil {
 			logrus.Warn(err.Error())
 		}
@@ -47,72 +87,107 @@ func main() {
 	signal.Notify(sigChan, os.Interrupt)
 	<-sigChan
 
+	stopBackground()
+	reportJobs.Wait()
 	closeAll()
 }
 
 func setupDbs() {
 	var err error
-	// Redis
-	redisClient = redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-	if _, err := redisClient.Ping(ctx).Result(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	clients, err = storage.Open(ctx, cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to create clients: %v", err)
+	}
+	for _, backend := range storage.Backends {
+		if err := clients.Ping(ctx, backend); err != nil {
+			logrus.Fatalf("Failed to connect to %s: %v", storage.Title(backend), err)
+		}
+		logrus.Infof("Connected to %s!", storage.Title(backend))
 	}
-	logrus.Info("Connected to Redis!")
 
-	// MongoDB
-	mongoCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	mongoClient, err = mongo.Connect(mongoCtx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	redisClient = clients.Redis
+	mongoClient = clients.Mongo
+	pgdbClient = clients.Postgres
+}
+
+// setupSchema applies pending migrations when configured to and refuses to
+// start on an outdated schema otherwise.
+func setupSchema() {
+	migrator, err := migrate.NewMigrator(pgdbClient)
 	if err != nil {
-		logrus.Fatalf("Failed to create MongoDB client: %v", err)
+		logrus.Fatalf("Failed to load migrations: %v", err)
+	}
+	if cfg.Migrations.AutoApply {
+		applied, err := migrator.Up(ctx, 0)
+		if err != nil {
+			logrus.Fatalf("Failed to apply migrations: %v", err)
+		}
+		for _, migration := range applied {
+			logrus.Infof("Applied migration %d_%s", migration.Version, migration.Name)
+		}
 	}
-	if err = mongoClient.Ping(mongoCtx, nil); err != nil {
-		logrus.Fatalf("Failed to ping MongoDB: %v", err)
+	if err := migrator.Check(ctx); err != nil {
+		logrus.Fatalf("%v; run `accounting-cli migrate up` or set migrations.auto_apply", err)
 	}
-	logrus.Info("Connected to MongoDB!")
+}
 
-	// Neo4j
-	neoClient, err = neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "password123", ""))
-	if err != nil {
-		logrus.Fatalf("Failed to connect to Neo4j: %v", err)
+// setupBootstrap brings the Elasticsearch indices and Neo4j constraints up to
+// date when configured to and refuses to start without them otherwise.
+func setupBootstrap() {
+	bootstrapper := bootstrap.NewBootstrapper(clients.Elastic, clients.Neo4j)
+	if cfg.Bootstrap.AutoApply {
+		actions, err := bootstrapper.Apply(ctx)
+		if err != nil {
+			logrus.Fatalf("Failed to bootstrap indices and constraints: %v", err)
+		}
+		for _, action := range actions {
+			logrus.Infof("Bootstrap: %s", action)
+		}
 	}
-	if err = neoClient.VerifyConnectivity(); err != nil {
-		logrus.Fatalf("Failed to verify Neo4j connection: %v", err)
+	if err := bootstrapper.Verify(ctx); err != nil {
+		logrus.Fatalf("%v; run `accounting-cli bootstrap apply` or set bootstrap.auto_apply", err)
 	}
-	logrus.Info("Connected to Neo4j!")
+}
 
-	// PostgreSQL
-	pgdbClient, err = sql.Open("postgres", "user=admin password=password123 dbname=mydb sslmode=disable")
+func setupAccountingClient() {
+	var err error
+	accountingClient, err = clients.Accounting(cfg)
 	if err != nil {
-		logrus.Fatalf("Failed to connect to PostgreSQL: %v", err)
+		logrus.Fatalf("Failed to create accounting client: %v", err)
 	}
-	if err = pgdbClient.Ping(); err != nil {
-		logrus.Fatalf("Failed to ping PostgreSQL: %v", err)
+	if err := accountingClient.EnsureSnapshotIndexes(ctx); err != nil {
+		logrus.Fatalf("Failed to create snapshot indexes: %v", err)
 	}
-	logrus.Info("Connected to PostgreSQL!")
+}
 
-	// ElasticSearch
-	esClient, err = elasticsearch.NewDefaultClient()
-	if err != nil {
-		logrus.Fatalf("Failed to connect to ElasticSearch: %v", err)
+func setupNotifier() {
+	collection := mongoClient.Database(cfg.Mongo.Database).Collection("notification_log")
+	notifier = notify.NewNotifier(accountingClient, collection, cfg.Notify)
+	if err := notifier.EnsureIndexes(ctx); err != nil {
+		logrus.Fatalf("Failed to create notification log indexes: %v", err)
 	}
-	res, err := esClient.Info()
+}
+
+func setupScheduler() {
+	var err error
+	jobScheduler, err = scheduler.NewScheduler(accountingClient, redisClient, cfg.Scheduler)
 	if err != nil {
-		logrus.Fatalf("Failed to get ElasticSearch info: %v", err)
+		logrus.Fatalf("Failed to create scheduler: %v", err)
 	}
-	defer res.Body.Close()
-	logrus.Info("Connected to ElasticSearch!")
 }
 
-func setupAccountingClient() {
-	accountingClient = accounting.NewClient(redisClient, mongoClient, neoClient, pgdbClient, esClient)
+func setupAuth() {
+	var err error
+	apiKeys = auth.NewKeyStore(pgdbClient)
+	authenticator, err = auth.NewAuthenticator(cfg.Auth, apiKeys)
+	if err != nil {
+		logrus.Fatalf("Failed to set up authentication: %v", err)
+	}
+	if authenticator.Disabled() {
+		logrus.Warn("Authentication is disabled, every endpoint is public")
+	}
 }
 
 func closeAll() {
-	_ = redisClient.Close()
-	_ = mongoClient.Disconnect(ctx)
-	_ = neoClient.Close()
-	_ = pgdbClient.Close()
+	clients.Close(ctx)
 }